@@ -21,6 +21,8 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/operator-framework/operator-sdk/internal/cmd/ansible-operator/run"
+	"github.com/operator-framework/operator-sdk/internal/cmd/ansible-operator/runonce"
+	"github.com/operator-framework/operator-sdk/internal/cmd/ansible-operator/validate"
 	"github.com/operator-framework/operator-sdk/internal/cmd/ansible-operator/version"
 )
 
@@ -35,6 +37,8 @@ operator project's image entrypoint
 	}
 
 	root.AddCommand(run.NewCmd())
+	root.AddCommand(runonce.NewCmd())
+	root.AddCommand(validate.NewCmd())
 	root.AddCommand(version.NewCmd())
 
 	if err := root.Execute(); err != nil {
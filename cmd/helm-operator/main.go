@@ -21,6 +21,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/operator-framework/operator-sdk/internal/cmd/helm-operator/run"
+	"github.com/operator-framework/operator-sdk/internal/cmd/helm-operator/validate"
 	"github.com/operator-framework/operator-sdk/internal/cmd/helm-operator/version"
 )
 
@@ -35,6 +36,7 @@ project's image entrypoint
 	}
 
 	root.AddCommand(run.NewCmd())
+	root.AddCommand(validate.NewCmd())
 	root.AddCommand(version.NewCmd())
 
 	if err := root.Execute(); err != nil {
@@ -0,0 +1,200 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalOptions - configure a local directory Sink.
+type LocalOptions struct {
+	// Dir is the directory the artifacts are stored in, e.g. a mounted persistent volume.
+	Dir string
+	// MaxAge is how long the artifacts of a run are kept. They are kept forever if it is 0.
+	MaxAge time.Duration
+	// MaxSize is the number of bytes the stored artifacts may take up. The artifacts of
+	// the oldest runs are removed to stay below it. There is no limit if it is 0.
+	MaxSize int64
+}
+
+// localSink - stores artifacts in a local directory.
+type localSink struct {
+	opts LocalOptions
+	now  func() time.Time
+	// mu serializes the stores, and the removals of old runs that follow them.
+	mu sync.Mutex
+}
+
+// NewLocal returns a Sink that stores artifacts in a local directory.
+func NewLocal(opts LocalOptions) (Sink, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("artifact directory must be set")
+	}
+	if opts.MaxAge < 0 || opts.MaxSize < 0 {
+		return nil, errors.New("artifact retention limits must not be negative")
+	}
+	if err := os.MkdirAll(filepath.Join(opts.Dir, IndexDir), 0755); err != nil {
+		return nil, err
+	}
+	return &localSink{opts: opts, now: time.Now}, nil
+}
+
+func (s *localSink) Store(_ context.Context, run Run, dir string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dst := filepath.Join(s.opts.Dir, filepath.FromSlash(run.Path()))
+	err := walkFiles(dir, func(rel, path string, info os.FileInfo) error {
+		return copyFile(path, filepath.Join(dst, filepath.FromSlash(rel)), info.Mode())
+	})
+	if err != nil {
+		return err
+	}
+	runJSON, err := marshalRun(run)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dst, RunFile), runJSON, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(s.opts.Dir, filepath.FromSlash(run.IndexPath())), runJSON, 0644); err != nil {
+		return err
+	}
+	s.prune()
+	return nil
+}
+
+// storedRun is a run in the directory, with the number of bytes its artifacts take up.
+type storedRun struct {
+	Run
+	size int64
+}
+
+// prune removes the runs that are older than MaxAge, then the oldest runs until the
+// artifacts take up at most MaxSize bytes.
+func (s *localSink) prune() {
+	if s.opts.MaxAge == 0 && s.opts.MaxSize == 0 {
+		return
+	}
+	runs, err := s.list()
+	if err != nil {
+		log.Error(err, "Failed to list stored runs")
+		return
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Finished.Before(runs[j].Finished) })
+
+	var total int64
+	for _, r := range runs {
+		total += r.size
+	}
+	for _, r := range runs {
+		expired := s.opts.MaxAge > 0 && s.now().Sub(r.Finished) > s.opts.MaxAge
+		if !expired && (s.opts.MaxSize == 0 || total <= s.opts.MaxSize) {
+			break
+		}
+		if err := s.remove(r.Run); err != nil {
+			log.Error(err, "Failed to remove stored run", "job", r.Ident)
+			continue
+		}
+		total -= r.size
+	}
+}
+
+// list returns the runs of the index.
+func (s *localSink) list() ([]storedRun, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(s.opts.Dir, IndexDir))
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]storedRun, 0, len(entries))
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(s.opts.Dir, IndexDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		r := storedRun{}
+		if err := json.Unmarshal(b, &r.Run); err != nil {
+			log.Info("Ignoring invalid index entry", "file", e.Name(), "error", err.Error())
+			continue
+		}
+		r.size, err = dirSize(filepath.Join(s.opts.Dir, filepath.FromSlash(r.Path())))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
+// remove removes the artifacts and the index entry of run, and the directory of its custom
+// resource once it is empty.
+func (s *localSink) remove(run Run) error {
+	runDir := filepath.Join(s.opts.Dir, filepath.FromSlash(run.Path()))
+	if err := os.RemoveAll(runDir); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.opts.Dir, filepath.FromSlash(run.IndexPath()))); err != nil &&
+		!os.IsNotExist(err) {
+		return err
+	}
+	// Fails, as intended, if other runs of the custom resource are stored.
+	_ = os.Remove(filepath.Dir(runDir))
+	return nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := walkFiles(dir, func(_, _ string, info os.FileInfo) error {
+		size += info.Size()
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
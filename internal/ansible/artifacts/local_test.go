@@ -0,0 +1,197 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testRun(ident, status string, finished time.Time) Run {
+	return Run{
+		Ident:     ident,
+		Group:     "cache.example.com",
+		Version:   "v1alpha1",
+		Kind:      "Memcached",
+		Namespace: "default",
+		Name:      "example",
+		Status:    status,
+		Finished:  finished,
+	}
+}
+
+// writeArtifacts returns an artifact directory like the ones ansible-runner writes.
+func writeArtifacts(t *testing.T, status string) string {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range map[string]string{
+		"status":                                status,
+		"stdout":                                "PLAY [localhost] ****\n",
+		filepath.Join("job_events", "1-a.json"): `{"event":"playbook_on_start"}`,
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newTestLocal(t *testing.T, opts LocalOptions) *localSink {
+	dir, err := ioutil.TempDir("", "sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	opts.Dir = dir
+	sink, err := NewLocal(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink.(*localSink)
+}
+
+func TestLocalSinkStore(t *testing.T) {
+	sink := newTestLocal(t, LocalOptions{})
+	run := testRun("1", "failed", time.Now())
+	artifacts := writeArtifacts(t, "failed")
+	if status := ReadStatus(artifacts); status != "failed" {
+		t.Fatalf("Unexpected status %q", status)
+	}
+	if err := sink.Store(context.TODO(), run, artifacts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	runDir := filepath.Join(sink.opts.Dir, "cache.example.com", "v1alpha1", "Memcached", "default", "example", "1")
+	for _, name := range []string{"status", "stdout", filepath.Join("job_events", "1-a.json"), RunFile} {
+		if _, err := os.Stat(filepath.Join(runDir, name)); err != nil {
+			t.Fatalf("Expected %s to be stored: %v", name, err)
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(sink.opts.Dir, IndexDir, "1.json"))
+	if err != nil {
+		t.Fatalf("Expected the run to be indexed: %v", err)
+	}
+	indexed := Run{}
+	if err := json.Unmarshal(b, &indexed); err != nil {
+		t.Fatal(err)
+	}
+	if indexed.Path() != run.Path() || indexed.Status != "failed" {
+		t.Fatalf("Unexpected index entry %+v", indexed)
+	}
+
+	clusterRun := run
+	clusterRun.Namespace = ""
+	if clusterRun.Path() != "cache.example.com/v1alpha1/Memcached/_cluster/example/1" {
+		t.Fatalf("Unexpected path of cluster-scoped run %s", clusterRun.Path())
+	}
+}
+
+func TestLocalSinkRetention(t *testing.T) {
+	now := time.Now()
+	artifacts := writeArtifacts(t, "successful")
+	stored := func(sink *localSink, idents ...string) {
+		t.Helper()
+		runs, err := sink.list()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(runs) != len(idents) {
+			t.Fatalf("Unexpected number of stored runs %d, expected %d", len(runs), len(idents))
+		}
+		for _, ident := range idents {
+			if _, err := os.Stat(filepath.Join(sink.opts.Dir, IndexDir, ident+".json")); err != nil {
+				t.Fatalf("Expected run %s to be stored", ident)
+			}
+		}
+	}
+
+	// Runs older than MaxAge are removed.
+	sink := newTestLocal(t, LocalOptions{MaxAge: time.Hour})
+	sink.now = func() time.Time { return now }
+	for i, finished := range []time.Time{now.Add(-2 * time.Hour), now.Add(-time.Minute), now} {
+		if err := sink.Store(context.TODO(), testRun(string(rune('1'+i)), "successful", finished), artifacts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored(sink, "2", "3")
+
+	// The oldest runs are removed to stay below MaxSize, which leaves room for two runs.
+	runs, err := sink.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runSize := runs[0].size
+	sink = newTestLocal(t, LocalOptions{MaxSize: 2*runSize + runSize/2})
+	for i := 0; i < 4; i++ {
+		finished := now.Add(time.Duration(i) * time.Minute)
+		if err := sink.Store(context.TODO(), testRun(string(rune('1'+i)), "successful", finished), artifacts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored(sink, "3", "4")
+
+	// The custom resource directory is removed with its last run.
+	sink = newTestLocal(t, LocalOptions{MaxAge: time.Hour})
+	sink.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if err := sink.Store(context.TODO(), testRun("1", "successful", now), artifacts); err != nil {
+		t.Fatal(err)
+	}
+	stored(sink)
+	crDir := filepath.Join(sink.opts.Dir, "cache.example.com", "v1alpha1", "Memcached", "default", "example")
+	if _, err := os.Stat(crDir); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed", crDir)
+	}
+}
+
+func TestFailedOnly(t *testing.T) {
+	local := newTestLocal(t, LocalOptions{})
+	sink := FailedOnly(local)
+	if err := sink.Store(context.TODO(), testRun("1", "successful", time.Now()), writeArtifacts(t, "successful")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Store(context.TODO(), testRun("2", "failed", time.Now()), writeArtifacts(t, "failed")); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := local.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Ident != "2" {
+		t.Fatalf("Expected only the failed run to be stored, got %+v", runs)
+	}
+}
+
+func TestNewLocal(t *testing.T) {
+	for _, opts := range []LocalOptions{
+		{},
+		{Dir: os.TempDir(), MaxAge: -time.Second},
+		{Dir: os.TempDir(), MaxSize: -1},
+	} {
+		if _, err := NewLocal(opts); err == nil {
+			t.Fatalf("Expected an error for %+v", opts)
+		}
+	}
+}
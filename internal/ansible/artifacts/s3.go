@@ -0,0 +1,223 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options - configure an S3 Sink.
+type S3Options struct {
+	// Endpoint is the URL of the S3-compatible service, e.g. https://s3.us-east-1.amazonaws.com
+	// or http://minio.minio.svc:9000. Objects are addressed path-style.
+	Endpoint string
+	// Bucket is the bucket the artifacts are stored in. It must exist.
+	Bucket string
+	// Prefix is prepended to the keys of the objects, e.g. the name of the operator.
+	Prefix string
+	// Region is the region requests are signed for. Defaults to us-east-1.
+	Region string
+	// AccessKeyID and SecretAccessKey are the credentials requests are signed with.
+	AccessKeyID     string
+	SecretAccessKey string
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// s3Sink - stores artifacts as the objects of an S3 bucket. The objects are not removed,
+// their retention is left to the lifecycle rules of the bucket.
+type s3Sink struct {
+	opts     S3Options
+	endpoint *url.URL
+	now      func() time.Time
+}
+
+// NewS3 returns a Sink that stores artifacts in an S3 bucket.
+func NewS3(opts S3Options) (Sink, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket must be set")
+	}
+	if opts.AccessKeyID == "" || opts.SecretAccessKey == "" {
+		return nil, errors.New("S3 credentials must be set")
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: scheme must be http or https", opts.Endpoint)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &s3Sink{opts: opts, endpoint: endpoint, now: time.Now}, nil
+}
+
+func (s *s3Sink) Store(ctx context.Context, run Run, dir string) error {
+	prefix := path.Join(s.opts.Prefix, run.Path())
+	err := walkFiles(dir, func(rel, p string, _ os.FileInfo) error {
+		body, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return s.put(ctx, path.Join(prefix, rel), body)
+	})
+	if err != nil {
+		return err
+	}
+	runJSON, err := marshalRun(run)
+	if err != nil {
+		return err
+	}
+	if err := s.put(ctx, path.Join(prefix, RunFile), runJSON); err != nil {
+		return err
+	}
+	// The index entry is written last, so that it only refers to complete runs.
+	return s.put(ctx, path.Join(s.opts.Prefix, run.IndexPath()), runJSON)
+}
+
+// put uploads body as the object key.
+func (s *s3Sink) put(ctx context.Context, key string, body []byte) error {
+	u := *s.endpoint
+	u.Path = path.Join("/", s.endpoint.Path, s.opts.Bucket, key)
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	signV4(req, sha256Hex(body), s.opts.AccessKeyID, s.opts.SecretAccessKey, s.opts.Region, s.now())
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("failed to upload %s: %s: %s", key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	sigV4Service   = "s3"
+	amzDateFormat  = "20060102T150405Z"
+)
+
+// signV4 signs req for S3 with AWS Signature Version 4. The host and all headers set on req
+// are signed, and payloadHash is the hex encoded SHA-256 hash of its body.
+func signV4(req *http.Request, payloadHash, accessKeyID, secretAccessKey, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, region, sigV4Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, sigV4Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, accessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query values sorted by name, and encoded as SigV4 requires.
+func canonicalQuery(values url.Values) string {
+	var params []string
+	for k, vs := range values {
+		for _, v := range vs {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters, and the slashes
+// unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,133 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package artifacts stores the artifacts ansible-runner writes for Ansible runs beyond the
+// lifetime of the operator pod. The artifacts of a run are stored under
+// <group>/<version>/<kind>/<namespace>/<name>/<ident>/ along with a run.json file that
+// describes the run, and indexed by job ident at index/<ident>.json.
+package artifacts
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("artifacts")
+
+const (
+	// RunFile is the name of the file that describes a stored run.
+	RunFile = "run.json"
+	// IndexDir is the directory that indexes the stored runs by job ident.
+	IndexDir = "index"
+	// StatusSuccessful is the status ansible-runner reports for successful runs.
+	StatusSuccessful = "successful"
+
+	// clusterScope is the namespace directory of cluster-scoped custom resources. It
+	// cannot be the name of a namespace.
+	clusterScope = "_cluster"
+)
+
+// Sink - stores the artifacts of finished Ansible runs.
+type Sink interface {
+	// Store stores the files of dir, the artifact directory of run.
+	Store(ctx context.Context, run Run, dir string) error
+}
+
+// Run - an Ansible run whose artifacts are stored.
+type Run struct {
+	Ident     string `json:"ident"`
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Status is the status ansible-runner reports for the run, e.g. "successful" or "failed".
+	Status   string    `json:"status"`
+	Finished time.Time `json:"finished"`
+}
+
+// Path returns the slash-separated path the artifacts of r are stored under.
+func (r Run) Path() string {
+	namespace := r.Namespace
+	if namespace == "" {
+		namespace = clusterScope
+	}
+	return path.Join(r.Group, r.Version, r.Kind, namespace, r.Name, r.Ident)
+}
+
+// IndexPath returns the slash-separated path of the index entry of r.
+func (r Run) IndexPath() string {
+	return path.Join(IndexDir, r.Ident+".json")
+}
+
+// Failed returns whether the run did not succeed.
+func (r Run) Failed() bool {
+	return r.Status != StatusSuccessful
+}
+
+// ReadStatus returns the status ansible-runner wrote to the artifact directory dir.
+func ReadStatus(dir string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// FailedOnly returns a Sink that only stores the artifacts of runs that did not succeed
+// in s.
+func FailedOnly(s Sink) Sink {
+	return failedOnlySink{s}
+}
+
+type failedOnlySink struct {
+	Sink
+}
+
+func (s failedOnlySink) Store(ctx context.Context, run Run, dir string) error {
+	if !run.Failed() {
+		return nil
+	}
+	return s.Sink.Store(ctx, run, dir)
+}
+
+// walkFiles calls fn with the slash-separated path relative to dir of each regular file
+// in dir. Symbolic links are not followed.
+func walkFiles(dir string, fn func(rel, path string, info os.FileInfo) error) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), p, info)
+	})
+}
+
+func marshalRun(run Run) ([]byte, error) {
+	return json.MarshalIndent(run, "", "  ")
+}
@@ -15,8 +15,8 @@
 package controller
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -25,16 +25,25 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	crhandler "sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	ctrlpredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/operator-framework/operator-sdk/internal/ansible/events"
+	"github.com/operator-framework/operator-sdk/internal/ansible/eventstream"
 	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
 	"github.com/operator-framework/operator-sdk/internal/ansible/predicate"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/specvalidation"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
 var log = logf.Log.WithName("ansible-controller")
@@ -46,32 +55,61 @@ type Options struct {
 	Runner                      runner.Runner
 	GVK                         schema.GroupVersionKind
 	ReconcilePeriod             time.Duration
+	RunTimeout                  time.Duration
 	ManageStatus                bool
+	RecordEvents                bool
+	RunHistoryLimit             int
+	SkipUnchanged               bool
+	DependentTriggers           *handler.DependentTriggers
 	AnsibleDebugLogs            bool
 	WatchDependentResources     bool
 	WatchClusterScopedResources bool
 	MaxConcurrentReconciles     int
 	Selector                    metav1.LabelSelector
+	Kubeconfigs                 *kubeconfig.Issuer
+	EventStream                 *eventstream.Broker
+	// Dependents reference counts the dependent kinds read and watched for the CRs of
+	// the controller, if set.
+	Dependents *controllermap.Dependents
+	// VarsFrom are the varsFrom of the watch, whose Secrets and ConfigMaps are watched
+	// if they set ReconcileOnChange.
+	VarsFrom          []watches.VarsFrom
+	OperatorNamespace string
+	// SpecValidator validates the specs of the CRs before their runs, if set.
+	SpecValidator *specvalidation.Validator
 }
 
-// Add - Creates a new ansible operator controller and adds it to the manager
-func Add(mgr manager.Manager, options Options) *controller.Controller {
+// NewUnmanaged - Creates a new ansible operator controller that is not added to the
+// manager, so that the caller can start and stop it.
+func NewUnmanaged(mgr manager.Manager, options Options) (controller.Controller, error) {
 	log.Info("Watching resource", "Options.Group", options.GVK.Group, "Options.Version",
 		options.GVK.Version, "Options.Kind", options.GVK.Kind)
 	if options.EventHandlers == nil {
 		options.EventHandlers = []events.EventHandler{}
 	}
+	controllerName := fmt.Sprintf("%v-controller", strings.ToLower(options.GVK.Kind))
 	eventHandlers := append(options.EventHandlers, events.NewLoggingEventHandler(options.LoggingLevel))
+	if options.RecordEvents {
+		eventHandlers = append(eventHandlers, events.NewRecordingEventHandler(mgr.GetEventRecorderFor(controllerName)))
+	}
 
 	aor := &AnsibleOperatorReconciler{
-		Client:           mgr.GetClient(),
-		GVK:              options.GVK,
-		Runner:           options.Runner,
-		EventHandlers:    eventHandlers,
-		ReconcilePeriod:  options.ReconcilePeriod,
-		ManageStatus:     options.ManageStatus,
-		AnsibleDebugLogs: options.AnsibleDebugLogs,
-		APIReader:        mgr.GetAPIReader(),
+		Client:            mgr.GetClient(),
+		GVK:               options.GVK,
+		Runner:            options.Runner,
+		EventHandlers:     eventHandlers,
+		ReconcilePeriod:   options.ReconcilePeriod,
+		RunTimeout:        options.RunTimeout,
+		ManageStatus:      options.ManageStatus,
+		AnsibleDebugLogs:  options.AnsibleDebugLogs,
+		APIReader:         mgr.GetAPIReader(),
+		Kubeconfigs:       options.Kubeconfigs,
+		RunHistoryLimit:   options.RunHistoryLimit,
+		SkipUnchanged:     options.SkipUnchanged,
+		DependentTriggers: options.DependentTriggers,
+		EventStream:       options.EventStream,
+		Dependents:        options.Dependents,
+		SpecValidator:     options.SpecValidator,
 	}
 
 	scheme := mgr.GetScheme()
@@ -84,19 +122,17 @@ func Add(mgr manager.Manager, options Options) *controller.Controller {
 			Version: options.GVK.Version,
 		})
 	} else if err != nil {
-		log.Error(err, "")
-		os.Exit(1)
+		return nil, err
 	}
 
 	//Create new controller runtime controller and set the controller to watch GVK.
-	c, err := controller.New(fmt.Sprintf("%v-controller", strings.ToLower(options.GVK.Kind)), mgr,
+	c, err := controller.NewUnmanaged(controllerName, mgr,
 		controller.Options{
 			Reconciler:              aor,
 			MaxConcurrentReconciles: options.MaxConcurrentReconciles,
 		})
 	if err != nil {
-		log.Error(err, "")
-		os.Exit(1)
+		return nil, err
 	}
 
 	// Set up predicates.
@@ -105,8 +141,7 @@ func Add(mgr manager.Manager, options Options) *controller.Controller {
 	}
 	filterPredicate, err := predicate.NewResourceFilterPredicate(options.Selector)
 	if err != nil {
-		log.Error(err, "Error creating resource filter predicate")
-		os.Exit(1)
+		return nil, fmt.Errorf("error creating resource filter predicate: %w", err)
 	}
 	predicates = append(predicates, filterPredicate)
 
@@ -114,9 +149,44 @@ func Add(mgr manager.Manager, options Options) *controller.Controller {
 	u.SetGroupVersionKind(options.GVK)
 	err = c.Watch(&source.Kind{Type: u}, &handler.LoggingEnqueueRequestForObject{}, predicates...)
 	if err != nil {
-		log.Error(err, "")
-		os.Exit(1)
+		return nil, err
+	}
+
+	// Cancel the in-flight Ansible run of a CR once it is marked for deletion or deleted, so
+	// that the finalizer, if any, runs without waiting for the previous run to complete.
+	err = c.Watch(&source.Kind{Type: u}, crhandler.Funcs{
+		UpdateFunc: func(e event.UpdateEvent, _ workqueue.RateLimitingInterface) {
+			if e.ObjectOld.GetDeletionTimestamp() == nil && e.ObjectNew.GetDeletionTimestamp() != nil {
+				aor.cancelRun(types.NamespacedName{Name: e.ObjectNew.GetName(), Namespace: e.ObjectNew.GetNamespace()})
+			}
+		},
+		DeleteFunc: func(e event.DeleteEvent, _ workqueue.RateLimitingInterface) {
+			aor.cancelRun(types.NamespacedName{Name: e.Object.GetName(), Namespace: e.Object.GetNamespace()})
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &c
+	err = watchVarsFrom(c, mgr.GetClient(), options.GVK, options.VarsFrom, options.OperatorNamespace,
+		options.DependentTriggers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &drainingController{Controller: c, reconciler: aor}, nil
+}
+
+// drainingController - a controller whose Start only returns once its reconciles have
+// returned, so that the resources they use, like the runner, can be released after it.
+type drainingController struct {
+	controller.Controller
+	reconciler *AnsibleOperatorReconciler
+}
+
+// Start - runs the controller until ctx is done, and waits for its reconciles to return.
+func (c *drainingController) Start(ctx context.Context) error {
+	err := c.Controller.Start(ctx)
+	c.reconciler.drain()
+	return err
 }
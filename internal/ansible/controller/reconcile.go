@@ -23,8 +23,11 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -38,10 +41,16 @@ import (
 
 	ansiblestatus "github.com/operator-framework/operator-sdk/internal/ansible/controller/status"
 	"github.com/operator-framework/operator-sdk/internal/ansible/events"
+	"github.com/operator-framework/operator-sdk/internal/ansible/eventstream"
+	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
 	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/specvalidation"
+	"github.com/operator-framework/operator-sdk/internal/ansible/tracing"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
 const (
@@ -49,6 +58,11 @@ const (
 	// To use create a CR with an annotation "ansible.sdk.operatorframework.io/reconcile-period: 30s" or some other valid
 	// Duration. This will override the operators/or controllers reconcile period for that particular CR.
 	ReconcilePeriodAnnotation = "ansible.sdk.operatorframework.io/reconcile-period"
+
+	// RunTimeoutAnnotation - annotation used by a user to specify the maximum duration of an Ansible run for the CR.
+	// To use create a CR with an annotation "ansible.sdk.operatorframework.io/run-timeout: 10m" or some other valid
+	// Duration. This will override the run timeout of the watch for that particular CR.
+	RunTimeoutAnnotation = "ansible.sdk.operatorframework.io/run-timeout"
 )
 
 // AnsibleOperatorReconciler - object to reconcile runner requests
@@ -59,17 +73,89 @@ type AnsibleOperatorReconciler struct {
 	APIReader        client.Reader
 	EventHandlers    []events.EventHandler
 	ReconcilePeriod  time.Duration
+	RunTimeout       time.Duration
 	ManageStatus     bool
 	AnsibleDebugLogs bool
+	// Kubeconfigs issues the kubeconfig through which each run reaches the proxy.
+	// Defaults to the proxy at kubeconfig.DefaultProxyURL.
+	Kubeconfigs *kubeconfig.Issuer
+	// RunHistoryLimit is the number of runs recorded in the ansibleRuns status of each
+	// CR when ManageStatus is set. No runs are recorded if it is 0.
+	RunHistoryLimit int
+	// SkipUnchanged skips the Ansible run of a CR whose generation was observed by its last
+	// successful run, unless a dependent resource changed or the reconcile period elapsed.
+	// It requires ManageStatus.
+	SkipUnchanged bool
+	// DependentTriggers records the CRs enqueued for events of their dependent resources.
+	DependentTriggers *handler.DependentTriggers
+	// EventStream keeps the events of the runs of each CR to stream them, if set.
+	EventStream *eventstream.Broker
+	// Dependents, if set, records the dependent kinds the runs of each CR use, to stop
+	// watching the kinds no CR depends on anymore.
+	Dependents *controllermap.Dependents
+	// SpecValidator validates the spec of each CR that is not deleted before its Ansible
+	// run, if set.
+	SpecValidator *specvalidation.Validator
+
+	// inFlight maps the NamespacedName of each CR with a running Ansible run to the
+	// context.CancelFunc of that run.
+	inFlight sync.Map
+	// lastRuns maps the NamespacedName of each CR to the time its last successful Ansible
+	// run completed.
+	lastRuns sync.Map
+	// reconciles counts the reconciles in progress. Once reconcilesDone is set, no reconcile
+	// starts anymore, so that the controller can wait for the reconciles to return.
+	reconciles     sync.WaitGroup
+	reconcilesMu   sync.Mutex
+	reconcilesDone bool
+}
+
+// startReconcile records that a reconcile started, unless the reconciler is drained.
+func (r *AnsibleOperatorReconciler) startReconcile() bool {
+	r.reconcilesMu.Lock()
+	defer r.reconcilesMu.Unlock()
+	if r.reconcilesDone {
+		return false
+	}
+	r.reconciles.Add(1)
+	return true
+}
+
+// drain stops new reconciles from starting and waits for those in progress to return.
+func (r *AnsibleOperatorReconciler) drain() {
+	r.reconcilesMu.Lock()
+	r.reconcilesDone = true
+	r.reconcilesMu.Unlock()
+	r.reconciles.Wait()
 }
 
 // Reconcile - handle the event.
-func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) { //nolint:gocyclo
+func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request reconcile.Request) (_ reconcile.Result, reterr error) { //nolint:gocyclo
 	// TODO: Try to reduce the complexity of this last measured at 42 (failing at > 30) and remove the // nolint:gocyclo
+	if !r.startReconcile() {
+		return reconcile.Result{}, nil
+	}
+	defer r.reconciles.Done()
+	ctx, span := tracing.Tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		tracing.GVKKey.String(r.GVK.String()),
+		tracing.NamespaceKey.String(request.Namespace),
+		tracing.NameKey.String(request.Name),
+	))
+	defer func() {
+		if reterr != nil {
+			span.RecordError(reterr)
+			span.SetStatus(codes.Error, reterr.Error())
+		}
+		span.End()
+	}()
+
 	u := &unstructured.Unstructured{}
 	u.SetGroupVersionKind(r.GVK)
 	err := r.Client.Get(ctx, request.NamespacedName, u)
 	if apierrors.IsNotFound(err) {
+		r.lastRuns.Delete(request.NamespacedName)
+		r.EventStream.Forget(eventstream.Key{GVK: r.GVK, NamespacedName: request.NamespacedName})
+		r.Dependents.Forget(request.NamespacedName)
 		return reconcile.Result{}, nil
 	}
 	if err != nil {
@@ -77,6 +163,7 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 	}
 
 	ident := strconv.Itoa(rand.Int())
+	span.SetAttributes(tracing.JobKey.String(ident))
 	logger := logf.Log.WithName("reconciler").WithValues(
 		"job", ident,
 		"name", u.GetName(),
@@ -87,6 +174,7 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 	if ds, ok := u.GetAnnotations()[ReconcilePeriodAnnotation]; ok {
 		duration, err := time.ParseDuration(ds)
 		if err != nil {
+			metrics.ReconcileFailed(r.GVK.String(), metrics.FailureReasonInvalidAnnotation)
 			// Should attempt to update to a failed condition
 			errmark := r.markError(ctx, request.NamespacedName, u,
 				fmt.Sprintf("Unable to parse reconcile period annotation: %v", err))
@@ -100,15 +188,27 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 	}
 
 	deleted := u.GetDeletionTimestamp() != nil
-	finalizer, finalizerExists := r.Runner.GetFinalizer()
-	if !controllerutil.ContainsFinalizer(u, finalizer) {
-		if deleted {
-			// If the resource is being deleted we don't want to add the finalizer again
+	finalizers := r.Runner.GetFinalizers()
+	// stage is the index of the finalizer stage that runs, which is the first stage whose
+	// finalizer is still present once the resource is deleted.
+	stage := -1
+	if deleted {
+		stage = nextFinalizerStage(u, finalizers)
+		if stage < 0 {
+			// If the resource is being deleted we don't want to add the finalizers again
 			logger.Info("Resource is terminated, skipping reconciliation")
 			return reconcile.Result{}, nil
-		} else if finalizerExists {
-			logger.V(1).Info("Adding finalizer to resource", "Finalizer", finalizer)
-			controllerutil.AddFinalizer(u, finalizer)
+		}
+	} else {
+		added := false
+		for _, f := range finalizers {
+			if !controllerutil.ContainsFinalizer(u, f.Name) {
+				logger.V(1).Info("Adding finalizer to resource", "Finalizer", f.Name)
+				controllerutil.AddFinalizer(u, f.Name)
+				added = true
+			}
+		}
+		if added {
 			err := r.Client.Update(ctx, u)
 			if err != nil {
 				logger.Error(err, "Unable to update cr with finalizer")
@@ -128,7 +228,48 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 		u.Object["spec"] = map[string]interface{}{}
 	}
 
-	if r.ManageStatus {
+	// The finalizers of deleted resources run even if their spec is invalid.
+	if !deleted {
+		fieldErrs, err := r.SpecValidator.Validate(ctx, u)
+		if err != nil {
+			errmark := r.markError(ctx, request.NamespacedName, u, "Unable to validate spec")
+			if errmark != nil {
+				logger.Error(errmark, "Unable to mark error to validate spec")
+			}
+			logger.Error(err, "Unable to validate spec")
+			return reconcileResult, err
+		}
+		if len(fieldErrs) > 0 {
+			metrics.ReconcileFailed(r.GVK.String(), metrics.FailureReasonSpecInvalid)
+			if r.ManageStatus && !runner.IsCheckModeRun(u) {
+				messages := make([]string, 0, len(fieldErrs))
+				for _, fieldErr := range fieldErrs {
+					messages = append(messages, fieldErr.Error())
+				}
+				errmark := r.markFailure(ctx, request.NamespacedName, u, ansiblestatus.SpecInvalidReason,
+					strings.Join(messages, "\n"), nil)
+				if errmark != nil {
+					logger.Error(errmark, "Unable to mark invalid spec")
+					return reconcileResult, errmark
+				}
+			}
+			// A change of the spec enqueues another reconcile by itself.
+			logger.Info("Spec is invalid, skipping Ansible run", "errors", fieldErrs.ToAggregate().Error())
+			return reconcile.Result{}, nil
+		}
+	}
+
+	if r.SkipUnchanged {
+		if skip, requeueAfter := r.skipRun(request.NamespacedName, u, reconcileResult.RequeueAfter); skip {
+			logger.V(1).Info("Resource is unchanged, skipping Ansible run")
+			metrics.RunSkipped(r.GVK.String())
+			return reconcile.Result{RequeueAfter: requeueAfter}, nil
+		}
+	}
+
+	// Runs in check mode do not change the conditions, which describe the last real run.
+	checkMode := runner.IsCheckModeRun(u)
+	if r.ManageStatus && !checkMode {
 		errmark := r.markRunning(ctx, request.NamespacedName, u)
 		if errmark != nil {
 			logger.Error(errmark, "Unable to update the status to mark cr as running")
@@ -143,8 +284,18 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 		UID:        u.GetUID(),
 	}
 
-	kc, err := kubeconfig.Create(ownerRef, "http://localhost:8888", u.GetNamespace())
+	kubeconfigs := r.Kubeconfigs
+	if kubeconfigs == nil {
+		kubeconfigs = &kubeconfig.Issuer{}
+	}
+	kc, revoke, err := kubeconfigs.CreateForOwner(&kubeconfig.NamespacedOwnerReference{
+		OwnerReference: ownerRef,
+		Namespace:      u.GetNamespace(),
+		Ident:          ident,
+		CheckMode:      checkMode,
+	}, u.GetNamespace())
 	if err != nil {
+		metrics.ReconcileFailed(r.GVK.String(), metrics.FailureReasonRunnerError)
 		errmark := r.markError(ctx, request.NamespacedName, u, "Unable to run reconciliation")
 		if errmark != nil {
 			logger.Error(errmark, "Unable to mark error to run reconciliation")
@@ -152,13 +303,57 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 		logger.Error(err, "Unable to generate kubeconfig")
 		return reconcileResult, err
 	}
+	defer revoke()
 	defer func() {
 		if err := os.Remove(kc.Name()); err != nil {
 			logger.Error(err, "Failed to remove generated kubeconfig file")
 		}
 	}()
-	result, err := r.Runner.Run(ident, u, kc.Name())
+
+	runTimeout := r.RunTimeout
+	if ts, ok := u.GetAnnotations()[RunTimeoutAnnotation]; ok {
+		duration, err := time.ParseDuration(ts)
+		if err != nil {
+			logger.Info("Invalid run timeout annotation", "err", err, "value", ts)
+		} else {
+			runTimeout = duration
+		}
+	}
+	if stage >= 0 && finalizers[stage].RunTimeout.Duration > 0 {
+		runTimeout = finalizers[stage].RunTimeout.Duration
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	if runTimeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, runTimeout)
+	}
+	defer cancel()
+	r.inFlight.Store(request.NamespacedName, cancel)
+	defer r.inFlight.Delete(request.NamespacedName)
+
+	// The API requests of the run are traced as children of the reconcile.
+	defer tracing.RegisterRun(ident, span.SpanContext())()
+	run := ansiblestatus.AnsibleRun{
+		Ident:              ident,
+		StartTime:          metav1.Now(),
+		ObservedGeneration: u.GetGeneration(),
+	}
+	if stage >= 0 {
+		run.FinalizerStage = finalizers[stage].Name
+	}
+	checkResult := ansiblestatus.CheckModeResult{
+		Ident:              ident,
+		ObservedGeneration: u.GetGeneration(),
+	}
+	// Only the dependent kinds used by successful runs that are not in check mode replace
+	// the kinds the CR depends on.
+	replaceDependents := false
+	r.Dependents.RunStarted(request.NamespacedName)
+	defer func() {
+		r.Dependents.RunFinished(request.NamespacedName, replaceDependents)
+	}()
+	result, err := r.Runner.Run(runCtx, ident, u, kc.Name())
 	if err != nil {
+		metrics.ReconcileFailed(r.GVK.String(), metrics.FailureReasonRunnerError)
 		errmark := r.markError(ctx, request.NamespacedName, u, "Unable to run reconciliation")
 		if errmark != nil {
 			logger.Error(errmark, "Unable to mark error to run reconciliation")
@@ -167,24 +362,39 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 		return reconcileResult, err
 	}
 
-	// iterate events from ansible, looking for the final one
+	stream := r.EventStream.Start(eventstream.Key{GVK: r.GVK, NamespacedName: request.NamespacedName}, ident)
+	defer stream.Finish()
+
+	// iterate events from ansible, looking for the final one. Returning before the run has
+	// finished would cancel it, so the reconcile only returns early once all events have
+	// been drained.
 	statusEvent := eventapi.StatusJobEvent{}
 	failureMessages := eventapi.FailureMessages{}
+	taskMetrics := runner.NewTaskMetrics(r.GVK)
+	taskSpans := runner.NewTaskSpans(ctx)
+	returnEarly := false
+	var earlyResult reconcile.Result
+	var earlyErr error
 	for event := range result.Events() {
+		taskMetrics.Observe(event)
+		taskSpans.Observe(event)
+		stream.Publish(event)
 		for _, eHandler := range r.EventHandlers {
 			go eHandler.Handle(ident, u, event)
 		}
+		if returnEarly {
+			continue
+		}
 		if event.Event == eventapi.EventPlaybookOnStats {
 			// convert to StatusJobEvent; would love a better way to do this
 			data, err := json.Marshal(event)
-			if err != nil {
-				printEventStats(statusEvent, u)
-				return reconcile.Result{}, err
+			if err == nil {
+				err = json.Unmarshal(data, &statusEvent)
 			}
-			err = json.Unmarshal(data, &statusEvent)
 			if err != nil {
 				printEventStats(statusEvent, u)
-				return reconcile.Result{}, err
+				returnEarly, earlyResult, earlyErr = true, reconcile.Result{}, err
+				continue
 			}
 		}
 
@@ -195,18 +405,60 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 						requeueDuration, err := time.ParseDuration(fields["period"].(string))
 						if err != nil {
 							logger.Error(err, "Unable to parse time input")
-							return reconcileResult, err
+							returnEarly, earlyResult, earlyErr = true, reconcileResult, err
+							continue
 						}
 						reconcileResult.RequeueAfter = requeueDuration
 						logger.Info(fmt.Sprintf("Set the reconciliation to occur after %s", requeueDuration))
-						return reconcileResult, nil
+						returnEarly, earlyResult, earlyErr = true, reconcileResult, nil
+						continue
 					}
 				}
 			}
 		}
 		if event.Event == eventapi.EventRunnerOnFailed && !event.IgnoreError() && !event.Rescued() {
 			failureMessages = append(failureMessages, event.GetFailedPlaybookMessage())
+			run.FailedTasks = append(run.FailedTasks, event.Task())
+		}
+		if checkMode && event.Event == eventapi.EventRunnerOnOk && event.Changed() {
+			ansiblestatus.AddTaskChange(&checkResult, ansiblestatus.TaskChange{
+				Task: event.Task(),
+				Role: event.Role(),
+				Diff: event.Diff(),
+			})
+		}
+	}
+	if returnEarly {
+		return earlyResult, earlyErr
+	}
+	run.EndTime = metav1.Now()
+
+	if runCtx.Err() != nil && ctx.Err() == nil {
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			metrics.ReconcileTimedOut(r.GVK.String())
+			metrics.ReconcileFailed(r.GVK.String(), metrics.FailureReasonTimedOut)
+			timeoutErr := fmt.Errorf("ansible run timed out after %v", runTimeout)
+			if r.ManageStatus && checkMode {
+				checkResult.Outcome = ansiblestatus.RunTimedOut
+				checkResult.FailedTasks = run.FailedTasks
+				if errmark := r.markCheckModeDone(ctx, request.NamespacedName, u, checkResult); errmark != nil {
+					logger.Error(errmark, "Unable to mark run timeout")
+				}
+			} else if r.ManageStatus {
+				run.Outcome = ansiblestatus.RunTimedOut
+				errmark := r.markFailure(ctx, request.NamespacedName, u, ansiblestatus.TimedOutReason,
+					fmt.Sprintf("Ansible run timed out after %v", runTimeout), &run)
+				if errmark != nil {
+					logger.Error(errmark, "Unable to mark run timeout")
+				}
+			}
+			logger.Error(timeoutErr, "Ansible run was terminated")
+			return reconcileResult, timeoutErr
 		}
+		// The run was cancelled because the resource was deleted or marked for deletion,
+		// which enqueues another reconcile by itself.
+		logger.Info("Ansible run was cancelled")
+		return reconcile.Result{}, nil
 	}
 
 	// To print the stats of the task
@@ -216,6 +468,7 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 	r.printAnsibleResult(result, u)
 
 	if statusEvent.Event == "" {
+		metrics.ReconcileFailed(r.GVK.String(), metrics.FailureReasonIncompleteRun)
 		eventErr := errors.New("did not receive playbook_on_stats event")
 		stdout, err := result.Stdout()
 		if err != nil {
@@ -243,21 +496,51 @@ func (r *AnsibleOperatorReconciler) Reconcile(ctx context.Context, request recon
 	// We only want to update the CustomResource once, so we'll track changes
 	// and do it at the end
 	runSuccessful := len(failureMessages) == 0
+	replaceDependents = runSuccessful && !checkMode
+	if runSuccessful {
+		metrics.ReconcileSucceeded(r.GVK.String())
+	} else {
+		metrics.ReconcileFailed(r.GVK.String(), metrics.FailureReasonTaskFailed)
+	}
 
-	// The finalizer has run successfully, time to remove it
+	if checkMode {
+		if r.ManageStatus {
+			checkResult.Outcome = ansiblestatus.RunSucceeded
+			if !runSuccessful {
+				checkResult.Outcome = ansiblestatus.RunFailed
+			}
+			checkResult.FailedTasks = run.FailedTasks
+			if errmark := r.markCheckModeDone(ctx, request.NamespacedName, u, checkResult); errmark != nil {
+				logger.Error(errmark, "Failed to record check mode result")
+				return reconcileResult, errmark
+			}
+		}
+		if !runSuccessful {
+			return reconcileResult, errors.New("received failed task event in check mode")
+		}
+		return reconcileResult, nil
+	}
+
+	// The finalizer stage has run successfully, time to remove its finalizer. The next
+	// stage, if any, runs in the next reconcile.
 	deleted = u.GetDeletionTimestamp() != nil
-	if deleted && finalizerExists && runSuccessful {
-		controllerutil.RemoveFinalizer(u, finalizer)
+	if deleted && stage >= 0 && runSuccessful {
+		controllerutil.RemoveFinalizer(u, finalizers[stage].Name)
 		err := r.Client.Update(ctx, u)
 		if err != nil {
 			logger.Error(err, "Failed to remove finalizer")
 			return reconcileResult, err
 		}
+		if nextFinalizerStage(u, finalizers) >= 0 {
+			reconcileResult = reconcile.Result{Requeue: true}
+		}
 	}
 	if r.ManageStatus {
-		errmark := r.markDone(ctx, request.NamespacedName, u, statusEvent, failureMessages)
+		errmark := r.markDone(ctx, request.NamespacedName, u, statusEvent, failureMessages, run)
 		if errmark != nil {
 			logger.Error(errmark, "Failed to mark status done")
+		} else if runSuccessful {
+			r.lastRuns.Store(request.NamespacedName, time.Now())
 		}
 		// re-trigger reconcile because of failures
 		if !runSuccessful {
@@ -323,11 +606,15 @@ func (r *AnsibleOperatorReconciler) markRunning(ctx context.Context, nn types.Na
 // i.e Annotations that could be incorrect
 func (r *AnsibleOperatorReconciler) markError(ctx context.Context, nn types.NamespacedName, u *unstructured.Unstructured,
 	failureMessage string) error {
+	return r.markFailure(ctx, nn, u, ansiblestatus.FailedReason, failureMessage, nil)
+}
+
+// markFailure - sets the failure condition with the given reason and message, and records
+// run in the run history unless it is nil.
+func (r *AnsibleOperatorReconciler) markFailure(ctx context.Context, nn types.NamespacedName,
+	u *unstructured.Unstructured, reason, failureMessage string, run *ansiblestatus.AnsibleRun) error {
 
 	logger := logf.Log.WithName("markError")
-	// Immediately update metrics with failed reconciliation, since Get()
-	// may fail.
-	metrics.ReconcileFailed(r.GVK.String())
 	// Get the latest resource to prevent updating a stale status.
 	if err := r.APIReader.Get(ctx, nn, u); err != nil {
 		if apierrors.IsNotFound(err) {
@@ -348,10 +635,16 @@ func (r *AnsibleOperatorReconciler) markError(ctx context.Context, nn types.Name
 		ansiblestatus.FailureConditionType,
 		v1.ConditionTrue,
 		nil,
-		ansiblestatus.FailedReason,
+		reason,
 		failureMessage,
 	)
 	ansiblestatus.SetCondition(&crStatus, *c)
+	if run != nil {
+		setFinalizerStatus(&crStatus, *run)
+	}
+	if run != nil && r.RunHistoryLimit > 0 {
+		ansiblestatus.AddAnsibleRun(&crStatus, *run, r.RunHistoryLimit)
+	}
 	// This needs the status subresource to be enabled by default.
 	u.Object["status"] = crStatus.GetJSONMap()
 
@@ -359,7 +652,8 @@ func (r *AnsibleOperatorReconciler) markError(ctx context.Context, nn types.Name
 }
 
 func (r *AnsibleOperatorReconciler) markDone(ctx context.Context, nn types.NamespacedName, u *unstructured.Unstructured,
-	statusEvent eventapi.StatusJobEvent, failureMessages eventapi.FailureMessages) error {
+	statusEvent eventapi.StatusJobEvent, failureMessages eventapi.FailureMessages,
+	run ansiblestatus.AnsibleRun) error {
 
 	logger := logf.Log.WithName("markDone")
 	// Get the latest resource to prevent updating a stale status.
@@ -371,12 +665,12 @@ func (r *AnsibleOperatorReconciler) markDone(ctx context.Context, nn types.Names
 		return err
 	}
 	crStatus := getStatus(u)
+	crStatus.ObservedGeneration = run.ObservedGeneration
 
 	runSuccessful := len(failureMessages) == 0
 	ansibleStatus := ansiblestatus.NewAnsibleResultFromStatusJobEvent(statusEvent)
 
 	if !runSuccessful {
-		metrics.ReconcileFailed(r.GVK.String())
 		sc := ansiblestatus.GetCondition(crStatus, ansiblestatus.RunningConditionType)
 		if sc != nil {
 			sc.Status = v1.ConditionFalse
@@ -391,7 +685,6 @@ func (r *AnsibleOperatorReconciler) markDone(ctx context.Context, nn types.Names
 		)
 		ansiblestatus.SetCondition(&crStatus, *c)
 	} else {
-		metrics.ReconcileSucceeded(r.GVK.String())
 		c := ansiblestatus.NewCondition(
 			ansiblestatus.RunningConditionType,
 			v1.ConditionTrue,
@@ -403,12 +696,115 @@ func (r *AnsibleOperatorReconciler) markDone(ctx context.Context, nn types.Names
 		ansiblestatus.RemoveCondition(&crStatus, ansiblestatus.FailureConditionType)
 		ansiblestatus.SetCondition(&crStatus, *c)
 	}
+	run.Outcome = ansiblestatus.RunSucceeded
+	if !runSuccessful {
+		run.Outcome = ansiblestatus.RunFailed
+	}
+	setFinalizerStatus(&crStatus, run)
+	if r.RunHistoryLimit > 0 {
+		ansiblestatus.AddAnsibleRun(&crStatus, run, r.RunHistoryLimit)
+	}
 	// This needs the status subresource to be enabled by default.
 	u.Object["status"] = crStatus.GetJSONMap()
 
 	return r.Client.Status().Update(ctx, u)
 }
 
+// setFinalizerStatus - records run as the last run of a finalizer stage in crStatus, if it is
+// the run of a finalizer stage.
+func setFinalizerStatus(crStatus *ansiblestatus.Status, run ansiblestatus.AnsibleRun) {
+	if run.FinalizerStage == "" {
+		return
+	}
+	crStatus.Finalizer = &ansiblestatus.FinalizerStatus{
+		Stage:          run.FinalizerStage,
+		Ident:          run.Ident,
+		CompletionTime: run.EndTime,
+		Outcome:        run.Outcome,
+		FailedTasks:    run.FailedTasks,
+	}
+}
+
+// markCheckModeDone - records result as the result of the last run of u in check mode.
+func (r *AnsibleOperatorReconciler) markCheckModeDone(ctx context.Context, nn types.NamespacedName,
+	u *unstructured.Unstructured, result ansiblestatus.CheckModeResult) error {
+
+	logger := logf.Log.WithName("markCheckModeDone")
+	// Get the latest resource to prevent updating a stale status.
+	if err := r.APIReader.Get(ctx, nn, u); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("Resource not found, assuming it was deleted")
+			return nil
+		}
+		return err
+	}
+	crStatus := getStatus(u)
+	result.CompletionTime = metav1.Now()
+	crStatus.CheckMode = &result
+	// This needs the status subresource to be enabled by default.
+	u.Object["status"] = crStatus.GetJSONMap()
+
+	return r.Client.Status().Update(ctx, u)
+}
+
+// skipRun returns whether the Ansible run of u, the CR nn, may be skipped because u is
+// unchanged since its last successful run, and if so when the next run is due according
+// to period.
+func (r *AnsibleOperatorReconciler) skipRun(nn types.NamespacedName, u *unstructured.Unstructured,
+	period time.Duration) (bool, time.Duration) {
+	// Always consume the trigger, as the run happens anyway if it is not skipped.
+	if r.DependentTriggers != nil && r.DependentTriggers.Pop(nn) {
+		return false, 0
+	}
+	if u.GetDeletionTimestamp() != nil {
+		return false, 0
+	}
+	crStatus := getStatus(u)
+	if crStatus.ObservedGeneration == 0 || crStatus.ObservedGeneration != u.GetGeneration() {
+		return false, 0
+	}
+	if c := ansiblestatus.GetCondition(crStatus, ansiblestatus.FailureConditionType); c != nil &&
+		c.Status == v1.ConditionTrue {
+		return false, 0
+	}
+	if c := ansiblestatus.GetCondition(crStatus, ansiblestatus.RunningConditionType); c == nil ||
+		c.Reason != ansiblestatus.SuccessfulReason {
+		return false, 0
+	}
+	// The time of the last run is not known after a restart, so the first reconcile runs.
+	lastRun, ok := r.lastRuns.Load(nn)
+	if !ok {
+		return false, 0
+	}
+	if period <= 0 {
+		return true, 0
+	}
+	elapsed := time.Since(lastRun.(time.Time))
+	if elapsed >= period {
+		return false, 0
+	}
+	return true, period - elapsed
+}
+
+// cancelRun cancels the in-flight Ansible run of the CR nn, if there is one.
+func (r *AnsibleOperatorReconciler) cancelRun(nn types.NamespacedName) {
+	if cancel, ok := r.inFlight.Load(nn); ok {
+		log.Info("Cancelling Ansible run", "name", nn.Name, "namespace", nn.Namespace)
+		cancel.(context.CancelFunc)()
+	}
+}
+
+// nextFinalizerStage returns the index of the first of the finalizer stages whose finalizer
+// u still has, or -1 if there is none.
+func nextFinalizerStage(u *unstructured.Unstructured, finalizers []watches.Finalizer) int {
+	for i, f := range finalizers {
+		if controllerutil.ContainsFinalizer(u, f.Name) {
+			return i
+		}
+	}
+	return -1
+}
+
 // getStatus returns u's "status" block as a status.Status.
 func getStatus(u *unstructured.Unstructured) ansiblestatus.Status {
 	statusInterface := u.Object["status"]
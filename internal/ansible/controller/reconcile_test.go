@@ -16,23 +16,33 @@ package controller_test
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	crHandler "sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/operator-framework/operator-sdk/internal/ansible/controller"
 	ansiblestatus "github.com/operator-framework/operator-sdk/internal/ansible/controller/status"
 	"github.com/operator-framework/operator-sdk/internal/ansible/events"
+	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/fake"
+	"github.com/operator-framework/operator-sdk/internal/ansible/specvalidation"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
 func TestReconcile(t *testing.T) {
@@ -54,6 +64,9 @@ func TestReconcile(t *testing.T) {
 		Request         reconcile.Request
 		ShouldError     bool
 		ManageStatus    bool
+		RunHistoryLimit int
+		// ExpectedRuns are the expected runs of the status. Runs with no ident match any ident.
+		ExpectedRuns []ansiblestatus.AnsibleRun
 	}{
 		{
 			Name:            "cr not found",
@@ -206,6 +219,93 @@ func TestReconcile(t *testing.T) {
 			},
 			ShouldError: true,
 		},
+		{
+			Name:            "Failure recorded in run history",
+			GVK:             gvk,
+			ManageStatus:    true,
+			RunHistoryLimit: 2,
+			Runner: &fake.Runner{
+				JobEvents: []eventapi.JobEvent{
+					eventapi.JobEvent{
+						Event:   eventapi.EventRunnerOnFailed,
+						Created: eventapi.EventTime{Time: eventTime},
+						EventData: map[string]interface{}{
+							"task": "create deployment",
+							"res": map[string]interface{}{
+								"msg": "new failure message",
+							},
+						},
+					},
+					eventapi.JobEvent{
+						Event:   eventapi.EventPlaybookOnStats,
+						Created: eventapi.EventTime{Time: eventTime},
+					},
+				},
+			},
+			Client: fakeclient.NewClientBuilder().WithObjects(&unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":       "reconcile",
+						"namespace":  "default",
+						"generation": int64(3),
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+					"spec":       map[string]interface{}{},
+					"status": map[string]interface{}{
+						"ansibleRuns": []interface{}{
+							map[string]interface{}{"ident": "2", "outcome": "Successful", "observedGeneration": int64(2)},
+							map[string]interface{}{"ident": "1", "outcome": "Successful", "observedGeneration": int64(1)},
+						},
+					},
+				},
+			}).Build(),
+			Request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "reconcile",
+					Namespace: "default",
+				},
+			},
+			ExpectedObject: &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"name":      "reconcile",
+						"namespace": "default",
+					},
+					"apiVersion": "operator-sdk/v1beta1",
+					"kind":       "Testing",
+					"spec":       map[string]interface{}{},
+					"status": map[string]interface{}{
+						"conditions": []interface{}{
+							map[string]interface{}{
+								"status":  "False",
+								"type":    "Running",
+								"message": "Running reconciliation",
+								"reason":  "Running",
+							},
+							map[string]interface{}{
+								"status": "True",
+								"type":   "Failure",
+								"ansibleResult": map[string]interface{}{
+									"changed":    int64(0),
+									"failures":   int64(0),
+									"ok":         int64(0),
+									"skipped":    int64(0),
+									"completion": eventTime.Format("2006-01-02T15:04:05.99999999"),
+								},
+								"message": "new failure message",
+								"reason":  "Failed",
+							},
+						},
+					},
+				},
+			},
+			ExpectedRuns: []ansiblestatus.AnsibleRun{
+				{Outcome: ansiblestatus.RunFailed, FailedTasks: []string{"create deployment"}, ObservedGeneration: 3},
+				{Ident: "2", Outcome: ansiblestatus.RunSucceeded, ObservedGeneration: 2},
+			},
+			ShouldError: true,
+		},
 		{
 			Name:         "Failure event runner on failed",
 			GVK:          gvk,
@@ -544,6 +644,7 @@ func TestReconcile(t *testing.T) {
 				EventHandlers:   tc.EventHandlers,
 				ReconcilePeriod: tc.ReconcilePeriod,
 				ManageStatus:    tc.ManageStatus,
+				RunHistoryLimit: tc.RunHistoryLimit,
 			}
 			result, err := aor.Reconcile(context.TODO(), tc.Request)
 			if err != nil && !tc.ShouldError {
@@ -596,7 +697,465 @@ func TestReconcile(t *testing.T) {
 						}
 					}
 				}
+				actualRuns := ansiblestatus.GetAnsibleRuns(actualStatus)
+				if len(actualRuns) != len(tc.ExpectedRuns) {
+					t.Fatalf("Ansible runs not the same\nexpected: %v\nactual: %v", tc.ExpectedRuns, actualRuns)
+				}
+				for i, run := range tc.ExpectedRuns {
+					actualRun := actualRuns[i]
+					if (run.Ident != "" && run.Ident != actualRun.Ident) || run.Outcome != actualRun.Outcome ||
+						!reflect.DeepEqual(run.FailedTasks, actualRun.FailedTasks) ||
+						run.ObservedGeneration != actualRun.ObservedGeneration {
+						t.Fatalf("Ansible run did not match\nexpected: %#v\nactual: %#v", run, actualRun)
+					}
+				}
 			}
 		})
 	}
 }
+
+func TestReconcileSkipUnchanged(t *testing.T) {
+	cr := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":       "reconcile",
+				"namespace":  "default",
+				"generation": int64(1),
+			},
+			"apiVersion": "operator-sdk/v1beta1",
+			"kind":       "Testing",
+			"spec":       map[string]interface{}{},
+		},
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "reconcile", Namespace: "default"}}
+	c := fakeclient.NewClientBuilder().WithObjects(cr).Build()
+	r := &fake.Runner{
+		JobEvents: []eventapi.JobEvent{
+			eventapi.JobEvent{
+				Event:   eventapi.EventPlaybookOnStats,
+				Created: eventapi.EventTime{Time: time.Now()},
+			},
+		},
+	}
+	triggers := handler.NewDependentTriggers()
+	aor := &controller.AnsibleOperatorReconciler{
+		GVK:               cr.GroupVersionKind(),
+		Runner:            r,
+		Client:            c,
+		APIReader:         c,
+		ReconcilePeriod:   time.Hour,
+		ManageStatus:      true,
+		SkipUnchanged:     true,
+		DependentTriggers: triggers,
+	}
+	reconcileAndExpectRuns := func(runs int) reconcile.Result {
+		t.Helper()
+		result, err := aor.Reconcile(context.TODO(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if r.Runs != runs {
+			t.Fatalf("Unexpected number of runs %d, expected %d", r.Runs, runs)
+		}
+		return result
+	}
+
+	reconcileAndExpectRuns(1)
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(cr.GroupVersionKind())
+	if err := c.Get(context.TODO(), req.NamespacedName, actual); err != nil {
+		t.Fatalf("Failed to get object: (%v)", err)
+	}
+	sMap, _ := actual.Object["status"].(map[string]interface{})
+	if got := ansiblestatus.CreateFromMap(sMap).ObservedGeneration; got != 1 {
+		t.Fatalf("Unexpected observedGeneration %d, expected 1", got)
+	}
+
+	// The resource is unchanged, so the run is skipped until the reconcile period elapses.
+	result := reconcileAndExpectRuns(1)
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour {
+		t.Fatalf("Unexpected requeue after %v", result.RequeueAfter)
+	}
+
+	// An event of a dependent resource triggers a run.
+	queue := &controllertest.Queue{Interface: workqueue.New()}
+	triggers.Wrap(&crHandler.EnqueueRequestForObject{}).Create(event.CreateEvent{Object: actual}, queue)
+	reconcileAndExpectRuns(2)
+
+	// A change of the spec triggers a run.
+	if err := c.Get(context.TODO(), req.NamespacedName, actual); err != nil {
+		t.Fatalf("Failed to get object: (%v)", err)
+	}
+	actual.SetGeneration(2)
+	if err := c.Update(context.TODO(), actual); err != nil {
+		t.Fatalf("Failed to update object: (%v)", err)
+	}
+	reconcileAndExpectRuns(3)
+	reconcileAndExpectRuns(3)
+}
+
+func TestReconcileSpecValidation(t *testing.T) {
+	schemaFile, err := ioutil.TempFile("", "spec-schema-*.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.Remove(schemaFile.Name())
+	schema := "type: object\nrequired: [size]\nproperties:\n  size:\n    type: integer\n"
+	if _, err := schemaFile.WriteString(schema); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	schemaFile.Close()
+
+	cr := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "reconcile",
+				"namespace": "default",
+			},
+			"apiVersion": "operator-sdk/v1beta1",
+			"kind":       "Testing",
+			"spec":       map[string]interface{}{"size": "three"},
+		},
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "reconcile", Namespace: "default"}}
+	c := fakeclient.NewClientBuilder().WithObjects(cr).Build()
+	r := &fake.Runner{
+		JobEvents: []eventapi.JobEvent{
+			eventapi.JobEvent{
+				Event:   eventapi.EventPlaybookOnStats,
+				Created: eventapi.EventTime{Time: time.Now()},
+			},
+		},
+	}
+	validator, err := specvalidation.New(watches.Watch{
+		GroupVersionKind: cr.GroupVersionKind(),
+		SpecValidation:   &watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: schemaFile.Name()},
+	}, c, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	aor := &controller.AnsibleOperatorReconciler{
+		GVK:           cr.GroupVersionKind(),
+		Runner:        r,
+		Client:        c,
+		APIReader:     c,
+		ManageStatus:  true,
+		SpecValidator: validator,
+	}
+	getFailure := func() *ansiblestatus.Condition {
+		t.Helper()
+		actual := &unstructured.Unstructured{}
+		actual.SetGroupVersionKind(cr.GroupVersionKind())
+		if err := c.Get(context.TODO(), req.NamespacedName, actual); err != nil {
+			t.Fatalf("Failed to get object: (%v)", err)
+		}
+		sMap, _ := actual.Object["status"].(map[string]interface{})
+		return ansiblestatus.GetCondition(ansiblestatus.CreateFromMap(sMap), ansiblestatus.FailureConditionType)
+	}
+
+	// The invalid spec is reported without running Ansible.
+	if _, err := aor.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.Runs != 0 {
+		t.Fatalf("Unexpected number of runs %d, expected 0", r.Runs)
+	}
+	failure := getFailure()
+	expected := `spec.size: Invalid value: "string": size in body must be of type integer: "string"`
+	if failure == nil || failure.Reason != ansiblestatus.SpecInvalidReason || failure.Message != expected {
+		t.Fatalf("Unexpected failure condition %+v", failure)
+	}
+
+	// Once the spec is fixed, Ansible runs.
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(cr.GroupVersionKind())
+	if err := c.Get(context.TODO(), req.NamespacedName, actual); err != nil {
+		t.Fatalf("Failed to get object: (%v)", err)
+	}
+	actual.Object["spec"] = map[string]interface{}{"size": int64(3)}
+	if err := c.Update(context.TODO(), actual); err != nil {
+		t.Fatalf("Failed to update object: (%v)", err)
+	}
+	if _, err := aor.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.Runs != 1 {
+		t.Fatalf("Unexpected number of runs %d, expected 1", r.Runs)
+	}
+	if failure := getFailure(); failure != nil {
+		t.Fatalf("Unexpected failure condition %+v", failure)
+	}
+}
+
+func TestReconcileFinalizerStages(t *testing.T) {
+	now := time.Now()
+	stages := []watches.Finalizer{{Name: "testing.io/drain"}, {Name: "testing.io/backup"}}
+	succeeded := []eventapi.JobEvent{
+		{Event: eventapi.EventPlaybookOnStats, Created: eventapi.EventTime{Time: now}},
+	}
+	failed := []eventapi.JobEvent{
+		{
+			Event:     eventapi.EventRunnerOnFailed,
+			Created:   eventapi.EventTime{Time: now},
+			EventData: map[string]interface{}{"task": "back up", "res": map[string]interface{}{"msg": "failed"}},
+		},
+		{Event: eventapi.EventPlaybookOnStats, Created: eventapi.EventTime{Time: now}},
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "reconcile", Namespace: "default"}}
+	newCR := func(deleted bool, finalizers ...interface{}) *unstructured.Unstructured {
+		metadata := map[string]interface{}{"name": "reconcile", "namespace": "default", "finalizers": finalizers}
+		if deleted {
+			metadata["deletionTimestamp"] = now.Format(time.RFC3339)
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata":   metadata,
+			"apiVersion": "operator-sdk/v1beta1",
+			"kind":       "Testing",
+			"spec":       map[string]interface{}{},
+		}}
+	}
+	get := func(c client.Client) (*unstructured.Unstructured, ansiblestatus.Status) {
+		t.Helper()
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Group: "operator-sdk", Version: "v1beta1", Kind: "Testing"})
+		if err := c.Get(context.TODO(), req.NamespacedName, u); err != nil {
+			t.Fatalf("Failed to get object: (%v)", err)
+		}
+		sMap, _ := u.Object["status"].(map[string]interface{})
+		return u, ansiblestatus.CreateFromMap(sMap)
+	}
+	newReconciler := func(c client.Client, r runner.Runner) reconcile.Reconciler {
+		return &controller.AnsibleOperatorReconciler{
+			GVK:             schema.GroupVersionKind{Group: "operator-sdk", Version: "v1beta1", Kind: "Testing"},
+			Runner:          r,
+			Client:          c,
+			APIReader:       c,
+			ReconcilePeriod: time.Hour,
+			ManageStatus:    true,
+		}
+	}
+
+	// All finalizers are added at once.
+	c := fakeclient.NewClientBuilder().WithObjects(newCR(false, "other")).Build()
+	r := &fake.Runner{Finalizers: stages, JobEvents: succeeded}
+	if _, err := newReconciler(c, r).Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	u, _ := get(c)
+	if expected := []string{"other", "testing.io/drain", "testing.io/backup"}; !reflect.DeepEqual(u.GetFinalizers(),
+		expected) {
+		t.Fatalf("Unexpected finalizers %v, expected %v", u.GetFinalizers(), expected)
+	}
+
+	// Once deleted, the stages run in order and each finalizer is removed once its stage
+	// succeeds.
+	c = fakeclient.NewClientBuilder().WithObjects(newCR(true, "testing.io/backup", "other", "testing.io/drain")).Build()
+	aor := newReconciler(c, r)
+	testCases := []struct {
+		name        string
+		events      []eventapi.JobEvent
+		shouldError bool
+		result      reconcile.Result
+		finalizers  []string
+		status      ansiblestatus.FinalizerStatus
+	}{
+		{
+			name:       "first stage",
+			events:     succeeded,
+			result:     reconcile.Result{Requeue: true},
+			finalizers: []string{"testing.io/backup", "other"},
+			status:     ansiblestatus.FinalizerStatus{Stage: "testing.io/drain", Outcome: ansiblestatus.RunSucceeded},
+		},
+		{
+			name:        "failed stage",
+			events:      failed,
+			shouldError: true,
+			result:      reconcile.Result{RequeueAfter: time.Hour},
+			finalizers:  []string{"testing.io/backup", "other"},
+			status: ansiblestatus.FinalizerStatus{Stage: "testing.io/backup", Outcome: ansiblestatus.RunFailed,
+				FailedTasks: []string{"back up"}},
+		},
+		{
+			name:       "last stage",
+			events:     succeeded,
+			result:     reconcile.Result{RequeueAfter: time.Hour},
+			finalizers: []string{"other"},
+			status:     ansiblestatus.FinalizerStatus{Stage: "testing.io/backup", Outcome: ansiblestatus.RunSucceeded},
+		},
+		{
+			name:       "no stage left",
+			result:     reconcile.Result{},
+			finalizers: []string{"other"},
+			status:     ansiblestatus.FinalizerStatus{Stage: "testing.io/backup", Outcome: ansiblestatus.RunSucceeded},
+		},
+	}
+	for _, tc := range testCases {
+		r.JobEvents = tc.events
+		result, err := aor.Reconcile(context.TODO(), req)
+		if (err != nil) != tc.shouldError {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(result, tc.result) {
+			t.Fatalf("%s: unexpected result %#v, expected %#v", tc.name, result, tc.result)
+		}
+		u, status := get(c)
+		if !reflect.DeepEqual(u.GetFinalizers(), tc.finalizers) {
+			t.Fatalf("%s: unexpected finalizers %v, expected %v", tc.name, u.GetFinalizers(), tc.finalizers)
+		}
+		if status.Finalizer == nil || status.Finalizer.Stage != tc.status.Stage ||
+			status.Finalizer.Outcome != tc.status.Outcome ||
+			!reflect.DeepEqual(status.Finalizer.FailedTasks, tc.status.FailedTasks) {
+			t.Fatalf("%s: unexpected finalizer status %#v, expected %#v", tc.name, status.Finalizer, tc.status)
+		}
+	}
+	if r.Runs != 4 {
+		t.Fatalf("Unexpected number of runs %d, expected 4", r.Runs)
+	}
+}
+
+func TestReconcileCheckMode(t *testing.T) {
+	cr := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":        "reconcile",
+				"namespace":   "default",
+				"generation":  int64(2),
+				"annotations": map[string]interface{}{runner.CheckModeAnnotation: "true"},
+			},
+			"apiVersion": "operator-sdk/v1beta1",
+			"kind":       "Testing",
+			"spec":       map[string]interface{}{},
+		},
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "reconcile", Namespace: "default"}}
+	c := fakeclient.NewClientBuilder().WithObjects(cr).Build()
+	aor := &controller.AnsibleOperatorReconciler{
+		GVK: cr.GroupVersionKind(),
+		Runner: &fake.Runner{
+			JobEvents: []eventapi.JobEvent{
+				eventapi.JobEvent{
+					Event: eventapi.EventRunnerOnOk,
+					EventData: map[string]interface{}{
+						"task": "scale deployment",
+						"role": "memcached",
+						"res": map[string]interface{}{
+							"changed": true,
+							"diff":    map[string]interface{}{"before": "replicas: 1\n", "after": "replicas: 3\n"},
+						},
+					},
+				},
+				eventapi.JobEvent{
+					Event:     eventapi.EventRunnerOnOk,
+					EventData: map[string]interface{}{"task": "gather facts", "res": map[string]interface{}{}},
+				},
+				eventapi.JobEvent{
+					Event:   eventapi.EventPlaybookOnStats,
+					Created: eventapi.EventTime{Time: time.Now()},
+				},
+			},
+		},
+		Client:       c,
+		APIReader:    c,
+		ManageStatus: true,
+	}
+	if _, err := aor.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	actual := &unstructured.Unstructured{}
+	actual.SetGroupVersionKind(cr.GroupVersionKind())
+	if err := c.Get(context.TODO(), req.NamespacedName, actual); err != nil {
+		t.Fatalf("Failed to get object: (%v)", err)
+	}
+	sMap, _ := actual.Object["status"].(map[string]interface{})
+	crStatus := ansiblestatus.CreateFromMap(sMap)
+	if len(crStatus.Conditions) != 0 || crStatus.ObservedGeneration != 0 {
+		t.Fatalf("Expected the run in check mode not to change the conditions: %#v", crStatus)
+	}
+	result := crStatus.CheckMode
+	if result == nil {
+		t.Fatalf("Expected a check mode result")
+	}
+	expectedChanges := []ansiblestatus.TaskChange{{
+		Task: "scale deployment",
+		Role: "memcached",
+		Diff: "--- before\n+++ after\n@@ -1 +1 @@\n-replicas: 1\n+replicas: 3\n",
+	}}
+	if result.Outcome != ansiblestatus.RunSucceeded || result.ObservedGeneration != 2 ||
+		!reflect.DeepEqual(result.Changes, expectedChanges) {
+		t.Fatalf("Unexpected check mode result %#v", result)
+	}
+}
+
+// recordingEventHandler records the events of the runs.
+type recordingEventHandler struct {
+	mu     sync.Mutex
+	events []eventapi.JobEvent
+}
+
+func (h *recordingEventHandler) Handle(_ string, _ *unstructured.Unstructured, e eventapi.JobEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, e)
+}
+
+func (h *recordingEventHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.events)
+}
+
+func TestReconcileRequeueAfterDrainsRun(t *testing.T) {
+	cr := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      "reconcile",
+				"namespace": "default",
+			},
+			"apiVersion": "operator-sdk/v1beta1",
+			"kind":       "Testing",
+			"spec":       map[string]interface{}{},
+		},
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Name: "reconcile", Namespace: "default"}}
+	c := fakeclient.NewClientBuilder().WithObjects(cr).Build()
+	jobEvents := []eventapi.JobEvent{
+		{
+			Event: eventapi.EventRunnerOnOk,
+			EventData: map[string]interface{}{
+				"task_action": "operator_sdk.util.requeue_after",
+				"res":         map[string]interface{}{"period": "30s"},
+			},
+		},
+		{
+			Event:     eventapi.EventRunnerOnOk,
+			EventData: map[string]interface{}{"task": "after requeue_after", "res": map[string]interface{}{}},
+		},
+		{
+			Event:   eventapi.EventPlaybookOnStats,
+			Created: eventapi.EventTime{Time: time.Now()},
+		},
+	}
+	recorder := &recordingEventHandler{}
+	aor := &controller.AnsibleOperatorReconciler{
+		GVK:           cr.GroupVersionKind(),
+		Runner:        &fake.Runner{JobEvents: jobEvents},
+		EventHandlers: []events.EventHandler{recorder},
+		Client:        c,
+		APIReader:     c,
+	}
+	result, err := aor.Reconcile(context.TODO(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.RequeueAfter != 30*time.Second {
+		t.Fatalf("Unexpected requeue after %v, expected %v", result.RequeueAfter, 30*time.Second)
+	}
+	// The run is not cancelled by requeue_after, so all its events are handled.
+	for i := 0; i < 500 && recorder.count() < len(jobEvents); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := recorder.count(); n != len(jobEvents) {
+		t.Fatalf("Expected the run to finish after requeue_after, got %d of %d events", n, len(jobEvents))
+	}
+}
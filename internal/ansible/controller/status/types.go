@@ -149,9 +149,125 @@ func createConditionFromMap(cm map[string]interface{}) Condition {
 	}
 }
 
+// RunOutcome - outcome of an Ansible run.
+type RunOutcome string
+
+const (
+	// RunSucceeded - the run completed without failed tasks.
+	RunSucceeded RunOutcome = "Successful"
+	// RunFailed - the run completed with failed tasks.
+	RunFailed RunOutcome = "Failed"
+	// RunTimedOut - the run was terminated because it exceeded its run timeout.
+	RunTimedOut RunOutcome = "TimedOut"
+)
+
+// AnsibleRun - record of an Ansible run of a custom resource.
+type AnsibleRun struct {
+	// Ident is the job ident of the run.
+	Ident     string      `json:"ident"`
+	StartTime metav1.Time `json:"startTime"`
+	EndTime   metav1.Time `json:"endTime"`
+	Outcome   RunOutcome  `json:"outcome"`
+	// FailedTasks are the names of the tasks that failed.
+	FailedTasks []string `json:"failedTasks,omitempty"`
+	// ObservedGeneration is the generation of the custom resource the run was started for.
+	ObservedGeneration int64 `json:"observedGeneration"`
+	// FinalizerStage is the name of the finalizer whose stage the run ran, if it was the run
+	// of a finalizer stage.
+	FinalizerStage string `json:"finalizerStage,omitempty"`
+}
+
+func createAnsibleRunsFromInterface(ri interface{}) []AnsibleRun {
+	b, err := json.Marshal(ri)
+	if err != nil {
+		log.Info("Unable to marshal Ansible runs, removing them", "Error", err.Error())
+		return nil
+	}
+	runs := []AnsibleRun{}
+	if err := json.Unmarshal(b, &runs); err != nil {
+		log.Info("Unknown Ansible runs, removing them", "Error", err.Error())
+		return nil
+	}
+	return runs
+}
+
+// TaskChange - a change reported by a task of an Ansible run in check mode.
+type TaskChange struct {
+	Task string `json:"task"`
+	Role string `json:"role,omitempty"`
+	// Diff is the unified diff of the change, if the task reported one.
+	Diff string `json:"diff,omitempty"`
+}
+
+// CheckModeResult - the changes the last Ansible run in check mode would have made.
+type CheckModeResult struct {
+	// Ident is the job ident of the run.
+	Ident          string      `json:"ident"`
+	CompletionTime metav1.Time `json:"completionTime"`
+	Outcome        RunOutcome  `json:"outcome"`
+	// FailedTasks are the names of the tasks that failed.
+	FailedTasks []string `json:"failedTasks,omitempty"`
+	// Changes are the changes reported by the tasks of the run, in the order of the tasks.
+	Changes []TaskChange `json:"changes,omitempty"`
+	// ObservedGeneration is the generation of the custom resource the run was started for.
+	ObservedGeneration int64 `json:"observedGeneration"`
+}
+
+func createCheckModeResultFromInterface(ci interface{}) *CheckModeResult {
+	b, err := json.Marshal(ci)
+	if err != nil {
+		log.Info("Unable to marshal check mode result, removing it", "Error", err.Error())
+		return nil
+	}
+	result := &CheckModeResult{}
+	if err := json.Unmarshal(b, result); err != nil {
+		log.Info("Unknown check mode result, removing it", "Error", err.Error())
+		return nil
+	}
+	return result
+}
+
+// FinalizerStatus - the result of the last run of a finalizer stage of a custom resource
+// that is being deleted.
+type FinalizerStatus struct {
+	// Stage is the name of the finalizer of the stage.
+	Stage string `json:"stage"`
+	// Ident is the job ident of the run.
+	Ident          string      `json:"ident"`
+	CompletionTime metav1.Time `json:"completionTime"`
+	Outcome        RunOutcome  `json:"outcome"`
+	// FailedTasks are the names of the tasks that failed.
+	FailedTasks []string `json:"failedTasks,omitempty"`
+}
+
+func createFinalizerStatusFromInterface(fi interface{}) *FinalizerStatus {
+	b, err := json.Marshal(fi)
+	if err != nil {
+		log.Info("Unable to marshal finalizer status, removing it", "Error", err.Error())
+		return nil
+	}
+	finalizer := &FinalizerStatus{}
+	if err := json.Unmarshal(b, finalizer); err != nil {
+		log.Info("Unknown finalizer status, removing it", "Error", err.Error())
+		return nil
+	}
+	return finalizer
+}
+
 // Status - The status for custom resources managed by the operator-sdk.
 type Status struct {
-	Conditions   []Condition            `json:"conditions"`
+	Conditions []Condition `json:"conditions"`
+	// ObservedGeneration is the generation of the custom resource the last completed
+	// Ansible run was started for.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// AnsibleRuns are the most recent Ansible runs, most recent first. They are only
+	// recorded if the run history of the watch is enabled.
+	AnsibleRuns []AnsibleRun `json:"ansibleRuns,omitempty"`
+	// CheckMode is the result of the last Ansible run in check mode.
+	CheckMode *CheckModeResult `json:"checkMode,omitempty"`
+	// Finalizer is the result of the last run of a finalizer stage, once the custom resource
+	// is being deleted.
+	Finalizer    *FinalizerStatus       `json:"finalizer,omitempty"`
 	CustomStatus map[string]interface{} `json:"-"`
 }
 
@@ -159,13 +275,35 @@ type Status struct {
 func CreateFromMap(statusMap map[string]interface{}) Status {
 	customStatus := make(map[string]interface{})
 	for key, value := range statusMap {
-		if key != "conditions" {
+		if key != "conditions" && key != "ansibleRuns" && key != "observedGeneration" && key != "checkMode" &&
+			key != "finalizer" {
 			customStatus[key] = value
 		}
 	}
+	var observedGeneration int64
+	switch g := statusMap["observedGeneration"].(type) {
+	case int64:
+		observedGeneration = g
+	case float64:
+		// Set from the JSON map of a status.
+		observedGeneration = int64(g)
+	}
+	var runs []AnsibleRun
+	if ri, ok := statusMap["ansibleRuns"]; ok {
+		runs = createAnsibleRunsFromInterface(ri)
+	}
+	var checkMode *CheckModeResult
+	if ci, ok := statusMap["checkMode"]; ok {
+		checkMode = createCheckModeResultFromInterface(ci)
+	}
+	var finalizer *FinalizerStatus
+	if fi, ok := statusMap["finalizer"]; ok {
+		finalizer = createFinalizerStatusFromInterface(fi)
+	}
 	conditionsInterface, ok := statusMap["conditions"].([]interface{})
 	if !ok {
-		return Status{Conditions: []Condition{}, CustomStatus: customStatus}
+		return Status{Conditions: []Condition{}, ObservedGeneration: observedGeneration, AnsibleRuns: runs,
+			CheckMode: checkMode, Finalizer: finalizer, CustomStatus: customStatus}
 	}
 	conditions := []Condition{}
 	for _, ci := range conditionsInterface {
@@ -176,7 +314,8 @@ func CreateFromMap(statusMap map[string]interface{}) Status {
 		}
 		conditions = append(conditions, createConditionFromMap(cm))
 	}
-	return Status{Conditions: conditions, CustomStatus: customStatus}
+	return Status{Conditions: conditions, ObservedGeneration: observedGeneration, AnsibleRuns: runs,
+		CheckMode: checkMode, Finalizer: finalizer, CustomStatus: customStatus}
 }
 
 // GetJSONMap - gets the map value for the status object.
@@ -26,6 +26,10 @@ const (
 	SuccessfulReason = "Successful"
 	// FailedReason - Condition is failed due to ansible failure
 	FailedReason = "Failed"
+	// TimedOutReason - Condition is failed due to the ansible run exceeding its run timeout
+	TimedOutReason = "TimedOut"
+	// SpecInvalidReason - Condition is failed due to the spec not matching its schema
+	SpecInvalidReason = "SpecInvalid"
 	// UnknownFailedReason - Condition is unknown
 	UnknownFailedReason = "Unknown"
 )
@@ -92,3 +96,51 @@ func filterOutCondition(conditions []Condition, condType ConditionType) []Condit
 	}
 	return newConditions
 }
+
+// GetAnsibleRuns returns the recorded Ansible runs, most recent first.
+func GetAnsibleRuns(status Status) []AnsibleRun {
+	return status.AnsibleRuns
+}
+
+// GetLastAnsibleRun returns the most recent recorded Ansible run, or nil if there is none.
+func GetLastAnsibleRun(status Status) *AnsibleRun {
+	if len(status.AnsibleRuns) == 0 {
+		return nil
+	}
+	run := status.AnsibleRuns[0]
+	return &run
+}
+
+// AddAnsibleRun records run as the most recent Ansible run, keeping at most limit runs.
+// A run with the ident of a recorded run replaces it.
+func AddAnsibleRun(status *Status, run AnsibleRun, limit int) {
+	runs := []AnsibleRun{run}
+	for _, r := range status.AnsibleRuns {
+		if r.Ident != run.Ident {
+			runs = append(runs, r)
+		}
+	}
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+	status.AnsibleRuns = runs
+}
+
+const (
+	// MaxTaskChanges is the number of changes recorded in a check mode result.
+	MaxTaskChanges = 100
+	// MaxDiffLength is the length in bytes the diff of a recorded change is truncated to.
+	MaxDiffLength = 4096
+)
+
+// AddTaskChange records change in result, truncating its diff to MaxDiffLength. Changes
+// beyond MaxTaskChanges are dropped, so that the status stays small.
+func AddTaskChange(result *CheckModeResult, change TaskChange) {
+	if len(result.Changes) >= MaxTaskChanges {
+		return
+	}
+	if len(change.Diff) > MaxDiffLength {
+		change.Diff = change.Diff[:MaxDiffLength] + "\n... (truncated)\n"
+	}
+	result.Changes = append(result.Changes, change)
+}
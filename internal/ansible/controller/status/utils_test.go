@@ -16,7 +16,9 @@ package status
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -300,3 +302,141 @@ func TestSetCondition(t *testing.T) {
 		})
 	}
 }
+
+func TestAddAnsibleRun(t *testing.T) {
+	run := func(ident string) AnsibleRun {
+		return AnsibleRun{Ident: ident, Outcome: RunSucceeded}
+	}
+	testCases := []struct {
+		name           string
+		runs           []AnsibleRun
+		run            AnsibleRun
+		limit          int
+		expectedIdents []string
+	}{
+		{
+			name:           "first run",
+			run:            run("1"),
+			limit:          3,
+			expectedIdents: []string{"1"},
+		},
+		{
+			name:           "most recent first",
+			runs:           []AnsibleRun{run("2"), run("1")},
+			run:            run("3"),
+			limit:          3,
+			expectedIdents: []string{"3", "2", "1"},
+		},
+		{
+			name:           "oldest runs removed over the limit",
+			runs:           []AnsibleRun{run("3"), run("2"), run("1")},
+			run:            run("4"),
+			limit:          2,
+			expectedIdents: []string{"4", "3"},
+		},
+		{
+			name:           "run with the same ident replaced",
+			runs:           []AnsibleRun{run("2"), run("1")},
+			run:            AnsibleRun{Ident: "2", Outcome: RunFailed},
+			limit:          3,
+			expectedIdents: []string{"2", "1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status := &Status{AnsibleRuns: tc.runs}
+			AddAnsibleRun(status, tc.run, tc.limit)
+			idents := []string{}
+			for _, r := range GetAnsibleRuns(*status) {
+				idents = append(idents, r.Ident)
+			}
+			if !reflect.DeepEqual(idents, tc.expectedIdents) {
+				t.Fatalf("Unexpected runs %v, expected %v", idents, tc.expectedIdents)
+			}
+			if last := GetLastAnsibleRun(*status); !reflect.DeepEqual(*last, tc.run) {
+				t.Fatalf("Unexpected last run %#v, expected %#v", last, tc.run)
+			}
+		})
+	}
+}
+
+func TestAnsibleRunsFromMap(t *testing.T) {
+	// Times are unmarshaled in the local time zone.
+	start := metav1.NewTime(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC).Local())
+	expected := Status{
+		Conditions: []Condition{},
+		AnsibleRuns: []AnsibleRun{
+			{
+				Ident:              "42",
+				StartTime:          start,
+				EndTime:            metav1.NewTime(start.Add(time.Minute)),
+				Outcome:            RunFailed,
+				FailedTasks:        []string{"create deployment"},
+				ObservedGeneration: 3,
+			},
+		},
+		CustomStatus: map[string]interface{}{"replicas": int64(2)},
+	}
+	statusMap := expected.GetJSONMap()
+	if _, ok := statusMap["replicas"]; !ok {
+		t.Fatalf("Expected custom status to be kept: %v", statusMap)
+	}
+
+	status := CreateFromMap(statusMap)
+	if !reflect.DeepEqual(status.AnsibleRuns, expected.AnsibleRuns) {
+		t.Fatalf("Unexpected runs %#v, expected %#v", status.AnsibleRuns, expected.AnsibleRuns)
+	}
+	if _, ok := status.CustomStatus["ansibleRuns"]; ok {
+		t.Fatalf("Expected runs not to be part of the custom status")
+	}
+}
+
+func TestAddTaskChange(t *testing.T) {
+	result := &CheckModeResult{}
+	AddTaskChange(result, TaskChange{Task: "create configmap", Diff: strings.Repeat("x", MaxDiffLength+1)})
+	if len(result.Changes) != 1 || !strings.HasSuffix(result.Changes[0].Diff, "... (truncated)\n") ||
+		len(result.Changes[0].Diff) > MaxDiffLength+20 {
+		t.Fatalf("Expected the diff to be truncated: %#v", result.Changes)
+	}
+	for i := 0; i < MaxTaskChanges; i++ {
+		AddTaskChange(result, TaskChange{Task: "scale deployment"})
+	}
+	if len(result.Changes) != MaxTaskChanges {
+		t.Fatalf("Unexpected number of changes %d, expected %d", len(result.Changes), MaxTaskChanges)
+	}
+}
+
+func TestCheckModeResultFromMap(t *testing.T) {
+	expected := &CheckModeResult{
+		Ident:              "42",
+		CompletionTime:     metav1.NewTime(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC).Local()),
+		Outcome:            RunSucceeded,
+		Changes:            []TaskChange{{Task: "scale deployment", Role: "memcached", Diff: "-1\n+3\n"}},
+		ObservedGeneration: 4,
+	}
+	status := CreateFromMap((&Status{Conditions: []Condition{}, CheckMode: expected}).GetJSONMap())
+	if !reflect.DeepEqual(status.CheckMode, expected) {
+		t.Fatalf("Unexpected check mode result %#v, expected %#v", status.CheckMode, expected)
+	}
+	if _, ok := status.CustomStatus["checkMode"]; ok {
+		t.Fatalf("Expected the check mode result not to be part of the custom status")
+	}
+}
+
+func TestFinalizerStatusFromMap(t *testing.T) {
+	expected := &FinalizerStatus{
+		Stage:          "cache.example.com/backup",
+		Ident:          "42",
+		CompletionTime: metav1.NewTime(time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC).Local()),
+		Outcome:        RunFailed,
+		FailedTasks:    []string{"back up the database"},
+	}
+	status := CreateFromMap((&Status{Conditions: []Condition{}, Finalizer: expected}).GetJSONMap())
+	if !reflect.DeepEqual(status.Finalizer, expected) {
+		t.Fatalf("Unexpected finalizer status %#v, expected %#v", status.Finalizer, expected)
+	}
+	if _, ok := status.CustomStatus["finalizer"]; ok {
+		t.Fatalf("Expected the finalizer status not to be part of the custom status")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	crhandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrlpredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// watchVarsFrom makes c reconcile the CRs of gvk, which reader lists, whose extra vars are
+// read from a Secret or ConfigMap of the sources with ReconcileOnChange when it changes.
+func watchVarsFrom(c controller.Controller, reader client.Reader, gvk schema.GroupVersionKind,
+	sources []watches.VarsFrom, operatorNamespace string, triggers *handler.DependentTriggers) error {
+	for _, obj := range []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}} {
+		kind := "Secret"
+		if _, ok := obj.(*corev1.ConfigMap); ok {
+			kind = "ConfigMap"
+		}
+		var watched []watches.VarsFrom
+		for _, s := range sources {
+			if s.ReconcileOnChange && s.Kind() == kind {
+				watched = append(watched, s)
+			}
+		}
+		if len(watched) == 0 {
+			continue
+		}
+
+		var h crhandler.EventHandler = crhandler.EnqueueRequestsFromMapFunc(func(o client.Object) []reconcile.Request {
+			return varsFromRequests(context.TODO(), reader, gvk, watched, operatorNamespace, o)
+		})
+		if triggers != nil {
+			// The generations of the CRs do not change, so their runs must not be skipped.
+			h = triggers.Wrap(h)
+		}
+		// Resyncs do not change the objects.
+		if err := c.Watch(&source.Kind{Type: obj}, h, ctrlpredicate.ResourceVersionChangedPredicate{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varsFromRequests returns the requests of the CRs of gvk whose extra vars are read from
+// obj by one of sources.
+func varsFromRequests(ctx context.Context, reader client.Reader, gvk schema.GroupVersionKind,
+	sources []watches.VarsFrom, operatorNamespace string, obj client.Object) []reconcile.Request {
+	listAll := false
+	listNamespace := false
+	for _, s := range sources {
+		if s.Name() != obj.GetName() {
+			continue
+		}
+		if s.Namespace == watches.VarsFromCRNamespace {
+			listNamespace = true
+		} else if obj.GetNamespace() == operatorNamespace {
+			listAll = true
+		}
+	}
+
+	var opts []client.ListOption
+	switch {
+	case listAll:
+	case listNamespace:
+		opts = append(opts, client.InNamespace(obj.GetNamespace()))
+	default:
+		return nil
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	if err := reader.List(ctx, list, opts...); err != nil {
+		log.Error(err, "Failed to list the CRs that read vars from the object", "GVK", gvk.String(),
+			"Namespace", obj.GetNamespace(), "Name", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Namespace: item.GetNamespace(),
+			Name:      item.GetName(),
+		}})
+	}
+	return requests
+}
@@ -0,0 +1,81 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+func TestVarsFromRequests(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	cr := func(namespace, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	reader := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(
+		cr("default", "a"), cr("default", "b"), cr("other", "c"),
+	).Build()
+
+	operatorSecret := watches.VarsFrom{SecretRef: &watches.ObjectRef{Name: "credentials"},
+		Namespace: watches.VarsFromOperatorNamespace}
+	crSecret := watches.VarsFrom{SecretRef: &watches.ObjectRef{Name: "tls"},
+		Namespace: watches.VarsFromCRNamespace}
+	sources := []watches.VarsFrom{operatorSecret, crSecret}
+
+	testCases := []struct {
+		name      string
+		namespace string
+		objName   string
+		expected  []string
+	}{
+		{name: "operator namespace object", namespace: "operator", objName: "credentials",
+			expected: []string{"default/a", "default/b", "other/c"}},
+		{name: "operator object in other namespace", namespace: "default", objName: "credentials"},
+		{name: "CR namespace object", namespace: "default", objName: "tls",
+			expected: []string{"default/a", "default/b"}},
+		{name: "unrelated object", namespace: "default", objName: "unrelated"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: tc.namespace, Name: tc.objName}}
+			var got []string
+			for _, req := range varsFromRequests(context.TODO(), reader, gvk, sources, "operator", obj) {
+				got = append(got, req.String())
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("Unexpected requests %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+// Reasons of the Kubernetes Events recorded for CRs.
+const (
+	ReasonTaskFailed   = "TaskFailed"
+	ReasonRunCompleted = "RunCompleted"
+)
+
+const (
+	// maxMessageLength is the length the messages of Events are truncated to.
+	maxMessageLength = 1024
+	// limiterIdleTimeout is how long the rate limiter of a CR is kept after its last Event.
+	limiterIdleTimeout = time.Hour
+)
+
+// EventQPS and EventBurst - the rate limit of the Kubernetes Events recorded for each CR. Each CR
+// may have a burst of EventBurst Events, refilled at EventQPS per second.
+var (
+	EventQPS   float32 = 1.0 / 30
+	EventBurst         = 10
+)
+
+type recordingEventHandler struct {
+	recorder record.EventRecorder
+	mu       sync.Mutex
+	limiters map[types.UID]*objectLimiter
+	now      func() time.Time
+}
+
+type objectLimiter struct {
+	flowcontrol.RateLimiter
+	lastUsed time.Time
+}
+
+// NewRecordingEventHandler - returns an EventHandler which records Kubernetes Events on
+// the CR with recorder: a Warning for each failed task that is not ignored or rescued and a
+// Normal summary of each run.
+// Events beyond the rate limit of a CR are dropped.
+func NewRecordingEventHandler(recorder record.EventRecorder) EventHandler {
+	return &recordingEventHandler{
+		recorder: recorder,
+		limiters: map[types.UID]*objectLimiter{},
+		now:      time.Now,
+	}
+}
+
+func (r *recordingEventHandler) Handle(ident string, u *unstructured.Unstructured, e eventapi.JobEvent) {
+	var eventType, reason, message string
+	switch e.Event {
+	case eventapi.EventRunnerOnFailed:
+		// Failures that are ignored or rescued do not fail the run.
+		if e.IgnoreError() || e.Rescued() {
+			return
+		}
+		eventType, reason = corev1.EventTypeWarning, ReasonTaskFailed
+		task := fmt.Sprintf("%q", e.Task())
+		if role := e.Role(); role != "" {
+			task = fmt.Sprintf("%q of role %q", e.Task(), role)
+		}
+		message = fmt.Sprintf("Task %s failed: %s", task, e.GetFailedPlaybookMessage())
+	case eventapi.EventPlaybookOnStats:
+		eventType, reason = corev1.EventTypeNormal, ReasonRunCompleted
+		message = fmt.Sprintf("Ansible run %s completed: ok=%d changed=%d failed=%d skipped=%d", ident,
+			sumHostCounts(e.EventData["ok"]), sumHostCounts(e.EventData["changed"]),
+			sumHostCounts(e.EventData["failures"]), sumHostCounts(e.EventData["skipped"]))
+	default:
+		return
+	}
+
+	if !r.allow(u.GetUID()) {
+		logger := logf.Log.WithName("recording_event_handler")
+		logger.V(1).Info("Dropping Event over the rate limit", "name", u.GetName(), "namespace",
+			u.GetNamespace(), "gvk", u.GroupVersionKind().String(), "reason", reason, "job", ident)
+		return
+	}
+	if len(message) > maxMessageLength {
+		message = message[:maxMessageLength-3] + "..."
+	}
+	r.recorder.Event(u, eventType, reason, message)
+}
+
+// allow returns whether an Event may be recorded for the CR with uid, and prunes the
+// rate limiters of the CRs without recent Events.
+func (r *recordingEventHandler) allow(uid types.UID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for k, l := range r.limiters {
+		if now.Sub(l.lastUsed) > limiterIdleTimeout {
+			delete(r.limiters, k)
+		}
+	}
+	l, ok := r.limiters[uid]
+	if !ok {
+		l = &objectLimiter{RateLimiter: flowcontrol.NewTokenBucketRateLimiter(EventQPS, EventBurst)}
+		r.limiters[uid] = l
+	}
+	l.lastUsed = now
+	return l.TryAccept()
+}
+
+// sumHostCounts returns the sum of the per-host counts of a playbook_on_stats event.
+func sumHostCounts(v interface{}) int {
+	counts, ok := v.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	sum := 0
+	for _, c := range counts {
+		if n, ok := c.(float64); ok {
+			sum += int(n)
+		}
+	}
+	return sum
+}
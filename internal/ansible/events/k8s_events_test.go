@@ -0,0 +1,122 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+func newCR(uid string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("cache.example.com/v1")
+	u.SetKind("Memcached")
+	u.SetNamespace("default")
+	u.SetName("memcached-" + uid)
+	u.SetUID(types.UID(uid))
+	return u
+}
+
+func TestRecordingEventHandler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		event    eventapi.JobEvent
+		expected string
+	}{
+		{
+			name: "failed task",
+			event: eventapi.JobEvent{Event: eventapi.EventRunnerOnFailed, EventData: map[string]interface{}{
+				"task": "create deployment",
+				"role": "memcached",
+				"res":  map[string]interface{}{"msg": "forbidden"},
+			}},
+			expected: `Warning TaskFailed Task "create deployment" of role "memcached" failed: forbidden`,
+		},
+		{
+			name: "ignored failed task",
+			event: eventapi.JobEvent{Event: eventapi.EventRunnerOnFailed, EventData: map[string]interface{}{
+				"task":          "check",
+				"ignore_errors": true,
+			}},
+		},
+		{
+			name: "rescued failed task",
+			event: eventapi.JobEvent{Event: eventapi.EventRunnerOnFailed, EventData: map[string]interface{}{
+				"task":    "try",
+				"rescued": map[string]interface{}{"localhost": float64(1)},
+			}},
+		},
+		{
+			name: "run summary",
+			event: eventapi.JobEvent{Event: eventapi.EventPlaybookOnStats, EventData: map[string]interface{}{
+				"ok":       map[string]interface{}{"localhost": 4.0},
+				"changed":  map[string]interface{}{"localhost": 2.0},
+				"failures": map[string]interface{}{},
+				"skipped":  map[string]interface{}{"localhost": 1.0},
+			}},
+			expected: "Normal RunCompleted Ansible run 42 completed: ok=4 changed=2 failed=0 skipped=1",
+		},
+		{
+			name:  "other events",
+			event: eventapi.JobEvent{Event: eventapi.EventRunnerOnOk},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			NewRecordingEventHandler(recorder).Handle("42", newCR("a"), tc.event)
+			select {
+			case e := <-recorder.Events:
+				if e != tc.expected {
+					t.Fatalf("Unexpected event %q, expected %q", e, tc.expected)
+				}
+			default:
+				if tc.expected != "" {
+					t.Fatalf("Expected event %q", tc.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordingEventHandlerRateLimit(t *testing.T) {
+	recorder := record.NewFakeRecorder(2 * EventBurst)
+	h := NewRecordingEventHandler(recorder).(*recordingEventHandler)
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	stats := eventapi.JobEvent{Event: eventapi.EventPlaybookOnStats}
+	for i := 0; i < EventBurst+5; i++ {
+		h.Handle("1", newCR("a"), stats)
+	}
+	// Each CR has its own limit.
+	h.Handle("1", newCR("b"), stats)
+	if len(recorder.Events) != EventBurst+1 {
+		t.Fatalf("Unexpected number of events %d, expected %d", len(recorder.Events), EventBurst+1)
+	}
+
+	// The limiters of idle CRs are removed.
+	now = now.Add(2 * limiterIdleTimeout)
+	h.Handle("1", newCR("b"), stats)
+	if _, ok := h.limiters["a"]; ok || len(h.limiters) != 1 {
+		t.Fatalf("Expected the limiter of the idle CR to be removed")
+	}
+}
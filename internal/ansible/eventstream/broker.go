@@ -0,0 +1,150 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventstream keeps the job events of the current or most recent Ansible run of
+// each custom resource, and serves them as Server-Sent Events.
+package eventstream
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+// MaxEvents is the number of the most recent events kept for each run.
+const MaxEvents = 2000
+
+// Key - identifies the custom resource of a run.
+type Key struct {
+	GVK schema.GroupVersionKind
+	types.NamespacedName
+}
+
+// Broker - keeps the events of the current or most recent run of each custom resource. A
+// nil Broker keeps no events.
+type Broker struct {
+	mu   sync.Mutex
+	runs map[Key]*Run
+}
+
+// NewBroker returns an empty Broker.
+func NewBroker() *Broker {
+	return &Broker{runs: map[Key]*Run{}}
+}
+
+// Start records the start of the run ident of the custom resource key, which replaces its
+// previous run. The returned Run must be finished once the run has finished.
+func (b *Broker) Start(key Key, ident string) *Run {
+	if b == nil {
+		return nil
+	}
+	run := &Run{Ident: ident, changed: make(chan struct{})}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if previous, ok := b.runs[key]; ok {
+		previous.Finish()
+	}
+	b.runs[key] = run
+	return run
+}
+
+// Latest returns the current or most recent run of the custom resource key, if any.
+func (b *Broker) Latest(key Key) (*Run, bool) {
+	if b == nil {
+		return nil, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	run, ok := b.runs[key]
+	return run, ok
+}
+
+// Forget removes the runs of the custom resource key, e.g. once it has been deleted.
+func (b *Broker) Forget(key Key) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if run, ok := b.runs[key]; ok {
+		run.Finish()
+		delete(b.runs, key)
+	}
+}
+
+// Run - the events of an Ansible run. A nil Run keeps no events.
+type Run struct {
+	// Ident is the job ident of the run.
+	Ident string
+
+	mu     sync.Mutex
+	events []eventapi.JobEvent
+	// first is the index in the run of the first kept event.
+	first    int
+	finished bool
+	// changed is closed, and replaced, when an event is published or the run finishes.
+	changed chan struct{}
+}
+
+// Publish records event as the next event of the run.
+func (r *Run) Publish(event eventapi.JobEvent) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+	r.events = append(r.events, event)
+	if len(r.events) > MaxEvents {
+		r.events = r.events[1:]
+		r.first++
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Finish records that the run has finished.
+func (r *Run) Finish() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return
+	}
+	r.finished = true
+	close(r.changed)
+}
+
+// next returns the events of the run from the index from, or from the first kept event if
+// it has been dropped, along with the index of the first returned event, whether the run
+// has finished and a channel that is closed when the run changes.
+func (r *Run) next(from int) ([]eventapi.JobEvent, int, bool, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if from < r.first {
+		from = r.first
+	}
+	var events []eventapi.JobEvent
+	if i := from - r.first; i < len(r.events) {
+		events = append(events, r.events[i:]...)
+	}
+	return events, from, r.finished, r.changed
+}
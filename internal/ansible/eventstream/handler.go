@@ -0,0 +1,223 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("eventstream")
+
+// Path is the path the events are served at, followed by
+// <group>/<version>/<kind>/<namespace>/<name> for namespaced custom resources and
+// <group>/<version>/<kind>/<name> for cluster-scoped ones.
+const Path = "/ansible/events/"
+
+// Authorizer - decides whether the bearer token of a request may read the events of a
+// custom resource.
+type Authorizer interface {
+	Authorize(ctx context.Context, token string, key Key) (bool, error)
+}
+
+// NewHandler returns a handler that streams the events of the current or most recent run
+// of a custom resource as Server-Sent Events, until the run has finished. The "event"
+// query parameter, which may be repeated or hold a comma-separated list, limits the stream
+// to the events of the given types. Requests must carry a bearer token that auth
+// authorizes.
+func NewHandler(broker *Broker, auth Authorizer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key, err := parseKey(strings.TrimPrefix(req.URL.Path, Path))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		const prefix = "Bearer "
+		token := req.Header.Get("Authorization")
+		if !strings.HasPrefix(token, prefix) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		allowed, err := auth.Authorize(req.Context(), strings.TrimPrefix(token, prefix), key)
+		if err != nil {
+			log.Error(err, "Failed to authorize event stream request", "uri", req.RequestURI)
+			http.Error(w, "Unable to authorize request", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		run, ok := broker.Latest(key)
+		if !ok {
+			http.Error(w, fmt.Sprintf("No Ansible run of %s %s", key.GVK.Kind, key.NamespacedName), http.StatusNotFound)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		stream(req.Context(), w, flusher, run, eventTypes(req))
+	})
+}
+
+// stream writes the events of run of the types in filter, or of all types if filter is
+// empty, to w until the run has finished or ctx is done.
+func stream(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, run *Run, filter sets.String) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	next := 0
+	for {
+		events, from, finished, changed := run.next(next)
+		for i, event := range events {
+			if filter.Len() > 0 && !filter.Has(event.Event) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error(err, "Failed to marshal event", "job", run.Ident)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", from+i, event.Event, data); err != nil {
+				return
+			}
+		}
+		next = from + len(events)
+		if finished {
+			fmt.Fprintf(w, "event: end\ndata: {\"job\":%q}\n\n", run.Ident)
+			flusher.Flush()
+			return
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// eventTypes returns the event types of the "event" query parameters of req.
+func eventTypes(req *http.Request) sets.String {
+	filter := sets.NewString()
+	for _, v := range req.URL.Query()["event"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Insert(t)
+			}
+		}
+	}
+	return filter
+}
+
+// parseKey parses the key of a path of the form <group>/<version>/<kind>/<namespace>/<name>
+// or <group>/<version>/<kind>/<name>.
+func parseKey(path string) (Key, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for _, p := range parts {
+		if p == "" {
+			return Key{}, fmt.Errorf("invalid path, expected %s<group>/<version>/<kind>/[<namespace>/]<name>", Path)
+		}
+	}
+	key := Key{}
+	switch len(parts) {
+	case 4:
+		key.Name = parts[3]
+	case 5:
+		key.Namespace, key.Name = parts[3], parts[4]
+	default:
+		return Key{}, fmt.Errorf("invalid path, expected %s<group>/<version>/<kind>/[<namespace>/]<name>", Path)
+	}
+	key.GVK = schema.GroupVersionKind{Group: parts[0], Version: parts[1], Kind: parts[2]}
+	return key, nil
+}
+
+// kubeAuthorizer - authorizes the users that may get a custom resource to read its events.
+type kubeAuthorizer struct {
+	client kubernetes.Interface
+	mapper meta.RESTMapper
+}
+
+// NewKubeAuthorizer returns an Authorizer that authenticates tokens with TokenReviews and
+// allows the users that may get a custom resource, according to SubjectAccessReviews, to
+// read its events.
+func NewKubeAuthorizer(client kubernetes.Interface, mapper meta.RESTMapper) Authorizer {
+	return &kubeAuthorizer{client: client, mapper: mapper}
+}
+
+func (a *kubeAuthorizer) Authorize(ctx context.Context, token string, key Key) (bool, error) {
+	review, err := a.client.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	if !review.Status.Authenticated {
+		return false, nil
+	}
+	mapping, err := a.mapper.RESTMapping(key.GVK.GroupKind(), key.GVK.Version)
+	if err != nil {
+		// Unknown kinds have no events.
+		return false, nil
+	}
+
+	user := review.Status.User
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	access, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: key.Namespace,
+				Verb:      "get",
+				Group:     mapping.Resource.Group,
+				Version:   mapping.Resource.Version,
+				Resource:  mapping.Resource.Resource,
+				Name:      key.Name,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return access.Status.Allowed, nil
+}
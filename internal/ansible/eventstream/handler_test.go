@@ -0,0 +1,180 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventstream
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+var gvk = schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+
+type tokenAuthorizer string
+
+func (a tokenAuthorizer) Authorize(_ context.Context, token string, _ Key) (bool, error) {
+	return token == string(a), nil
+}
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	key := Key{GVK: gvk, NamespacedName: types.NamespacedName{Namespace: "default", Name: "example"}}
+	first := b.Start(key, "1")
+	first.Publish(eventapi.JobEvent{Event: eventapi.EventRunnerOnOk})
+
+	second := b.Start(key, "2")
+	if events, _, finished, _ := first.next(0); !finished || len(events) != 1 {
+		t.Fatalf("Expected the replaced run to be finished with its events")
+	}
+	for i := 0; i < MaxEvents+10; i++ {
+		second.Publish(eventapi.JobEvent{Event: eventapi.EventRunnerOnOk})
+	}
+	events, from, finished, _ := second.next(0)
+	if len(events) != MaxEvents || from != 10 || finished {
+		t.Fatalf("Unexpected events: %d from %d, finished %v", len(events), from, finished)
+	}
+	if run, ok := b.Latest(key); !ok || run.Ident != "2" {
+		t.Fatalf("Expected the latest run to be 2")
+	}
+
+	b.Forget(key)
+	if _, ok := b.Latest(key); ok {
+		t.Fatalf("Expected the runs to be forgotten")
+	}
+
+	// A nil broker keeps no events.
+	var nilBroker *Broker
+	nilBroker.Start(key, "3").Publish(eventapi.JobEvent{})
+	if _, ok := nilBroker.Latest(key); ok {
+		t.Fatalf("Expected a nil broker to keep no runs")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	b := NewBroker()
+	key := Key{GVK: gvk, NamespacedName: types.NamespacedName{Namespace: "default", Name: "example"}}
+	server := httptest.NewServer(NewHandler(b, tokenAuthorizer("secret")))
+	defer server.Close()
+	url := server.URL + Path + "cache.example.com/v1alpha1/Memcached/default/example"
+
+	get := func(url, token string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	for _, tc := range []struct {
+		url, token string
+		status     int
+	}{
+		{url: url, status: http.StatusUnauthorized},
+		{url: url, token: "wrong", status: http.StatusForbidden},
+		{url: url, token: "secret", status: http.StatusNotFound},
+		{url: server.URL + Path + "cache.example.com/Memcached", token: "secret", status: http.StatusNotFound},
+	} {
+		resp := get(tc.url, tc.token)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Fatalf("Unexpected status %d for %s, expected %d", resp.StatusCode, tc.url, tc.status)
+		}
+	}
+
+	run := b.Start(key, "42")
+	run.Publish(eventapi.JobEvent{Event: eventapi.EventPlaybookOnTaskStart, UUID: "a"})
+	resp := get(url+"?event=runner_on_ok,runner_on_failed", "secret")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected response %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	run.Publish(eventapi.JobEvent{Event: eventapi.EventRunnerOnOk, UUID: "b"})
+	run.Publish(eventapi.JobEvent{Event: eventapi.EventRunnerOnFailed, UUID: "c"})
+	run.Finish()
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "id:") || strings.HasPrefix(line, "event:") {
+			lines = append(lines, line)
+		}
+	}
+	expected := []string{"id: 1", "event: runner_on_ok", "id: 2", "event: runner_on_failed", "event: end"}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Unexpected stream\n%s\nexpected\n%s", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestKubeAuthorizer(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		review.Status.Authenticated = review.Spec.Token == "valid"
+		review.Status.User.Username = "developer"
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "developer" && attrs.Verb == "get" &&
+			attrs.Resource == "memcacheds" && attrs.Namespace == "default"
+		return true, review, nil
+	})
+	auth := NewKubeAuthorizer(client, mapper)
+
+	for _, tc := range []struct {
+		token     string
+		namespace string
+		kind      string
+		expected  bool
+	}{
+		{token: "valid", namespace: "default", kind: "Memcached", expected: true},
+		{token: "invalid", namespace: "default", kind: "Memcached"},
+		{token: "valid", namespace: "other", kind: "Memcached"},
+		{token: "valid", namespace: "default", kind: "Unknown"},
+	} {
+		key := Key{GVK: gvk.GroupVersion().WithKind(tc.kind),
+			NamespacedName: types.NamespacedName{Namespace: tc.namespace, Name: "example"}}
+		allowed, err := auth.Authorize(context.TODO(), tc.token, key)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if allowed != tc.expected {
+			t.Fatalf("Unexpected result %v for %+v, expected %v", allowed, tc, tc.expected)
+		}
+	}
+}
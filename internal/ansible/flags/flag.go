@@ -27,9 +27,14 @@ import (
 type Flags struct {
 	ReconcilePeriod         time.Duration
 	WatchesFile             string
+	WatchesConfigMap        string
+	WatchesReloadInterval   time.Duration
 	InjectOwnerRef          bool
+	ProxyPort               int
+	ProxyTokenAuth          bool
 	LeaderElection          bool
 	MaxConcurrentReconciles int
+	MaxConcurrentRuns       int
 	AnsibleVerbosity        int
 	AnsibleRolesPath        string
 	AnsibleCollectionsPath  string
@@ -39,6 +44,18 @@ type Flags struct {
 	LeaderElectionNamespace string
 	GracefulShutdownTimeout time.Duration
 	AnsibleArgs             string
+	TracingEndpoint         string
+	TracingInsecure         bool
+	EventStream             bool
+	ArtifactSink            string
+	ArtifactSinkDir         string
+	ArtifactSinkMaxAge      time.Duration
+	ArtifactSinkMaxSize     int64
+	ArtifactSinkS3Endpoint  string
+	ArtifactSinkS3Bucket    string
+	ArtifactSinkS3Region    string
+	ArtifactSinkS3Prefix    string
+	ArtifactSinkFailedOnly  bool
 
 	// Path to a controller-runtime componentconfig file.
 	// If this is empty, use default values.
@@ -64,11 +81,37 @@ func (f *Flags) AddTo(flagSet *pflag.FlagSet) {
 		"./watches.yaml",
 		"Path to the watches file to use",
 	)
+	flagSet.StringVar(&f.WatchesConfigMap,
+		"watches-configmap",
+		"",
+		"ConfigMap, in the form <namespace>/<name>, whose \"watches.yaml\" key holds the watches to use "+
+			"instead of the watches file",
+	)
+	flagSet.DurationVar(&f.WatchesReloadInterval,
+		"watches-reload-interval",
+		0,
+		"How often to check the watches file, or ConfigMap, for changes and reload it. "+
+			"Set to 0 to disable reloading.",
+	)
 	flagSet.BoolVar(&f.InjectOwnerRef,
 		"inject-owner-ref",
 		true,
 		"The ansible operator will inject owner references unless this flag is false",
 	)
+	flagSet.IntVar(&f.ProxyPort,
+		"proxy-port",
+		0,
+		"Port on localhost the proxy for the Kubernetes API of Ansible runs listens on. "+
+			"Set to 0 to listen on a random port. Defaults to a random port, or to 8888 "+
+			"with --proxy-token-auth=false.",
+	)
+	flagSet.BoolVar(&f.ProxyTokenAuth,
+		"proxy-token-auth",
+		true,
+		"Issue a bearer token to each Ansible run and reject proxy requests that do not carry "+
+			"the token of a running Ansible run. Set to false to let any process in the pod "+
+			"use the proxy.",
+	)
 	flagSet.IntVar(&f.AnsibleVerbosity,
 		"ansible-verbosity",
 		2,
@@ -91,6 +134,75 @@ func (f *Flags) AddTo(flagSet *pflag.FlagSet) {
 		"Ansible args. Allows user to specify arbitrary arguments for ansible-based operators.",
 	)
 
+	// Tracing flags.
+	flagSet.StringVar(&f.TracingEndpoint,
+		"tracing-otlp-endpoint",
+		"",
+		"Host and port of the OTLP/HTTP endpoint to export traces of reconciles, Ansible tasks and "+
+			"proxied API requests to. Tracing is disabled if empty.",
+	)
+	flagSet.BoolVar(&f.TracingInsecure,
+		"tracing-otlp-insecure",
+		false,
+		"Export traces to the OTLP endpoint without TLS.",
+	)
+	flagSet.BoolVar(&f.EventStream,
+		"event-stream",
+		false,
+		"Serve the events of the current or most recent Ansible run of each CR as Server-Sent Events "+
+			"under /ansible/events/ on the metrics server, to the users that may get the CR.",
+	)
+
+	// Artifact sink flags.
+	flagSet.StringVar(&f.ArtifactSink,
+		"artifact-sink",
+		"",
+		"Where to store the artifacts of Ansible runs beyond the lifetime of the pod, "+
+			"one of \"local\" or \"s3\". Artifacts are not stored if empty.",
+	)
+	flagSet.StringVar(&f.ArtifactSinkDir,
+		"artifact-sink-dir",
+		"",
+		"Directory the local artifact sink stores artifacts in, e.g. a mounted persistent volume.",
+	)
+	flagSet.DurationVar(&f.ArtifactSinkMaxAge,
+		"artifact-sink-max-age",
+		0,
+		"How long the local artifact sink keeps the artifacts of a run. They are kept forever if 0.",
+	)
+	flagSet.Int64Var(&f.ArtifactSinkMaxSize,
+		"artifact-sink-max-size",
+		0,
+		"Number of bytes the artifacts in the local artifact sink may take up. The artifacts of "+
+			"the oldest runs are removed to stay below it. There is no limit if 0.",
+	)
+	flagSet.StringVar(&f.ArtifactSinkS3Endpoint,
+		"artifact-sink-s3-endpoint",
+		"",
+		"URL of the S3-compatible service the s3 artifact sink stores artifacts in. The credentials "+
+			"are read from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables.",
+	)
+	flagSet.StringVar(&f.ArtifactSinkS3Bucket,
+		"artifact-sink-s3-bucket",
+		"",
+		"Bucket the s3 artifact sink stores artifacts in.",
+	)
+	flagSet.StringVar(&f.ArtifactSinkS3Region,
+		"artifact-sink-s3-region",
+		"us-east-1",
+		"Region of the bucket the s3 artifact sink stores artifacts in.",
+	)
+	flagSet.StringVar(&f.ArtifactSinkS3Prefix,
+		"artifact-sink-s3-prefix",
+		"",
+		"Prefix of the keys of the objects the s3 artifact sink stores artifacts as.",
+	)
+	flagSet.BoolVar(&f.ArtifactSinkFailedOnly,
+		"artifact-sink-failed-only",
+		false,
+		"Only store the artifacts of Ansible runs that did not succeed.",
+	)
+
 	// Controller flags.
 	flagSet.DurationVar(&f.ReconcilePeriod,
 		"reconcile-period",
@@ -102,6 +214,12 @@ func (f *Flags) AddTo(flagSet *pflag.FlagSet) {
 		runtime.NumCPU(),
 		"Maximum number of concurrent reconciles for controllers. Overridden by environment variable.",
 	)
+	flagSet.IntVar(&f.MaxConcurrentRuns,
+		"max-concurrent-runs",
+		0,
+		"Maximum number of Ansible runs of all controllers that run at the same time. Runs beyond it wait, "+
+			"and are started by the runPriorityClass of their watch and fairly across GVKs. Unlimited if 0.",
+	)
 
 	// Controller manager flags.
 	flagSet.StringVar(&f.ManagerConfigPath,
@@ -164,6 +282,19 @@ func (f *Flags) AddTo(flagSet *pflag.FlagSet) {
 	)
 }
 
+// DefaultUnauthenticatedProxyPort is the port the proxy listens on without token auth
+// unless --proxy-port is set, so that sidecar containers can find it.
+const DefaultUnauthenticatedProxyPort = 8888
+
+// ProxyListenPort - returns the port the proxy listens on: the value of --proxy-port if it
+// is set, else a random port with token auth and DefaultUnauthenticatedProxyPort without.
+func (f *Flags) ProxyListenPort() int {
+	if f.ProxyTokenAuth || (f.flagSet != nil && f.flagSet.Changed("proxy-port")) {
+		return f.ProxyPort
+	}
+	return DefaultUnauthenticatedProxyPort
+}
+
 // ToManagerOptions uses the flag set in f to configure options.
 // Values of options take precedence over flag defaults,
 // as values are assume to have been explicitly set.
@@ -65,6 +65,35 @@ var _ = Describe("Flags", func() {
 			})
 		})
 	})
+	Describe("ProxyListenPort", func() {
+		var (
+			f       *flags.Flags
+			flagSet *pflag.FlagSet
+		)
+		BeforeEach(func() {
+			f = &flags.Flags{}
+			flagSet = pflag.NewFlagSet("test", pflag.ExitOnError)
+			f.AddTo(flagSet)
+		})
+
+		It("uses a random port with token auth by default", func() {
+			parseArgs(flagSet)
+			Expect(f.ProxyTokenAuth).To(BeTrue())
+			Expect(f.ProxyListenPort()).To(Equal(0))
+		})
+		It("uses the default port without token auth", func() {
+			parseArgs(flagSet, "--proxy-token-auth=false")
+			Expect(f.ProxyListenPort()).To(Equal(flags.DefaultUnauthenticatedProxyPort))
+		})
+		It("uses the port that is set", func() {
+			parseArgs(flagSet, "--proxy-port", "9999")
+			Expect(f.ProxyListenPort()).To(Equal(9999))
+		})
+		It("uses a random port without token auth if it is set to 0", func() {
+			parseArgs(flagSet, "--proxy-token-auth=false", "--proxy-port", "0")
+			Expect(f.ProxyListenPort()).To(Equal(0))
+		})
+	})
 })
 
 func parseArgs(fs *pflag.FlagSet, extraArgs ...string) {
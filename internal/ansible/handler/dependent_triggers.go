@@ -0,0 +1,88 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	crHandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DependentTriggers - records the custom resources enqueued for the events of their
+// dependent resources, so that their reconciles can tell that a dependent resource
+// changed.
+type DependentTriggers struct {
+	triggered sync.Map
+}
+
+// NewDependentTriggers returns an empty DependentTriggers.
+func NewDependentTriggers() *DependentTriggers {
+	return &DependentTriggers{}
+}
+
+// Wrap returns an EventHandler that enqueues the requests of h and records them as
+// triggered by a dependent resource.
+func (t *DependentTriggers) Wrap(h crHandler.EventHandler) crHandler.EventHandler {
+	return &dependentTriggerHandler{EventHandler: h, triggers: t}
+}
+
+// Pop returns whether nn was enqueued for an event of a dependent resource since the
+// last call for nn.
+func (t *DependentTriggers) Pop(nn types.NamespacedName) bool {
+	_, ok := t.triggered.LoadAndDelete(nn)
+	return ok
+}
+
+type dependentTriggerHandler struct {
+	crHandler.EventHandler
+	triggers *DependentTriggers
+}
+
+func (h *dependentTriggerHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Create(e, h.queue(q))
+}
+
+func (h *dependentTriggerHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Update(e, h.queue(q))
+}
+
+func (h *dependentTriggerHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Delete(e, h.queue(q))
+}
+
+func (h *dependentTriggerHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Generic(e, h.queue(q))
+}
+
+func (h *dependentTriggerHandler) queue(q workqueue.RateLimitingInterface) workqueue.RateLimitingInterface {
+	return &triggerRecordingQueue{RateLimitingInterface: q, triggers: h.triggers}
+}
+
+// triggerRecordingQueue records the requests added to it before adding them.
+type triggerRecordingQueue struct {
+	workqueue.RateLimitingInterface
+	triggers *DependentTriggers
+}
+
+func (q *triggerRecordingQueue) Add(item interface{}) {
+	if req, ok := item.(reconcile.Request); ok {
+		q.triggers.triggered.Store(req.NamespacedName, nil)
+	}
+	q.RateLimitingInterface.Add(item)
+}
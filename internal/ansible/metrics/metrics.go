@@ -28,6 +28,22 @@ const (
 	subsystem = "ansible_operator"
 )
 
+// Reasons of failed reconciles.
+const (
+	// FailureReasonInvalidAnnotation - an annotation of the CR is invalid.
+	FailureReasonInvalidAnnotation = "InvalidAnnotation"
+	// FailureReasonRunnerError - ansible-runner could not be run.
+	FailureReasonRunnerError = "RunnerError"
+	// FailureReasonTimedOut - the Ansible run exceeded its run timeout.
+	FailureReasonTimedOut = "TimedOut"
+	// FailureReasonIncompleteRun - the Ansible run ended without reporting its stats.
+	FailureReasonIncompleteRun = "IncompleteRun"
+	// FailureReasonTaskFailed - a task of the Ansible run failed.
+	FailureReasonTaskFailed = "TaskFailed"
+	// FailureReasonSpecInvalid - the spec of the CR does not match its schema.
+	FailureReasonSpecInvalid = "SpecInvalid"
+)
+
 var (
 	buildInfo = prometheus.NewGauge(
 		prometheus.GaugeOpts{
@@ -60,11 +76,192 @@ var (
 		[]string{
 			"GVK",
 		})
+
+	reconcileFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "reconcile_failures_total",
+			Help:      "Count of failed reconciles by the reason of the failure.",
+		},
+		[]string{
+			"GVK",
+			"reason",
+		})
+
+	skippedRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "skipped_runs_total",
+			Help:      "Count of reconciles that skipped the Ansible run because the resource was unchanged.",
+		},
+		[]string{
+			"GVK",
+		})
+
+	reconcileTimeouts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "reconcile_timeouts_total",
+			Help:      "Count of reconciles whose Ansible run exceeded its run timeout.",
+		},
+		[]string{
+			"GVK",
+		})
+
+	tasks = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystem,
+			Name:      "task_duration_seconds",
+			Help:      "How long in seconds the tasks of Ansible runs take.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{
+			"GVK",
+			"role",
+			"task",
+		})
+
+	taskResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "task_results_total",
+			Help:      "Count of the tasks of Ansible runs and their results.",
+		},
+		[]string{
+			"GVK",
+			"result",
+		})
+
+	runnersInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "runners_in_flight",
+			Help:      "Number of ansible-runner processes that are running.",
+		},
+		[]string{
+			"GVK",
+		})
+
+	runsQueued = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "runs_queued",
+			Help:      "Number of Ansible runs waiting for the operator-wide budget of concurrent runs.",
+		},
+		[]string{
+			"GVK",
+			"priority_class",
+		})
+
+	runQueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystem,
+			Name:      "run_queue_wait_seconds",
+			Help:      "How long in seconds Ansible runs wait for the operator-wide budget of concurrent runs.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		},
+		[]string{
+			"GVK",
+			"priority_class",
+		})
+
+	proxyDeniedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "proxy_denied_requests_total",
+			Help:      "Count of API requests of Ansible runs denied by the API allowlist of their watch.",
+		},
+		[]string{
+			"GVK",
+			"resource_gvk",
+			"verb",
+		})
+
+	proxyCacheHits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "proxy_cache_hits_total",
+			Help:      "Count of API requests of Ansible runs served by the proxy from the informer cache.",
+		},
+		[]string{
+			"GVK",
+			"verb",
+		})
+
+	proxyCacheMisses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "proxy_cache_misses_total",
+			Help:      "Count of API requests of Ansible runs the proxy failed to serve from the informer cache.",
+		},
+		[]string{
+			"GVK",
+			"verb",
+		})
+
+	proxyCacheBypasses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "proxy_cache_bypasses_total",
+			Help:      "Count of read requests of Ansible runs the proxy did not look up in the informer cache.",
+		},
+		[]string{
+			"GVK",
+			"reason",
+		})
+
+	dependentWatches = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: subsystem,
+			Name:      "dependent_watches",
+			Help:      "Number of CRs that depend on each dependent kind watched for the controller of GVK.",
+		},
+		[]string{
+			"GVK",
+			"dependent_gvk",
+		})
+
+	dependentWatchesStopped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: subsystem,
+			Name:      "dependent_watches_stopped_total",
+			Help:      "Count of dependent kinds no longer watched because no CR depends on them.",
+		},
+		[]string{
+			"GVK",
+			"dependent_gvk",
+		})
+
+	proxyUpstreamRequests = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: subsystem,
+			Name:      "proxy_upstream_request_duration_seconds",
+			Help:      "How long in seconds the API server takes to answer the requests the proxy forwards.",
+		},
+		[]string{
+			"GVK",
+			"verb",
+		})
 )
 
 func init() {
 	metrics.Registry.MustRegister(reconcileResults)
 	metrics.Registry.MustRegister(reconciles)
+	metrics.Registry.MustRegister(reconcileFailures)
+	metrics.Registry.MustRegister(reconcileTimeouts)
+	metrics.Registry.MustRegister(skippedRuns)
+	metrics.Registry.MustRegister(tasks)
+	metrics.Registry.MustRegister(taskResults)
+	metrics.Registry.MustRegister(runnersInFlight)
+	metrics.Registry.MustRegister(runsQueued)
+	metrics.Registry.MustRegister(runQueueWait)
+	metrics.Registry.MustRegister(proxyDeniedRequests)
+	metrics.Registry.MustRegister(proxyCacheHits)
+	metrics.Registry.MustRegister(proxyCacheMisses)
+	metrics.Registry.MustRegister(proxyCacheBypasses)
+	metrics.Registry.MustRegister(proxyUpstreamRequests)
+	metrics.Registry.MustRegister(dependentWatches)
+	metrics.Registry.MustRegister(dependentWatchesStopped)
 }
 
 // We will never want to panic our app because of metric saving.
@@ -87,10 +284,20 @@ func ReconcileSucceeded(gvk string) {
 	reconcileResults.WithLabelValues(gvk, "succeeded").Inc()
 }
 
-func ReconcileFailed(gvk string) {
-	// TODO: consider taking in a failure reason
+func ReconcileFailed(gvk, reason string) {
 	defer recoverMetricPanic()
 	reconcileResults.WithLabelValues(gvk, "failed").Inc()
+	reconcileFailures.WithLabelValues(gvk, reason).Inc()
+}
+
+func ReconcileTimedOut(gvk string) {
+	defer recoverMetricPanic()
+	reconcileTimeouts.WithLabelValues(gvk).Inc()
+}
+
+func RunSkipped(gvk string) {
+	defer recoverMetricPanic()
+	skippedRuns.WithLabelValues(gvk).Inc()
 }
 
 func ReconcileTimer(gvk string) *prometheus.Timer {
@@ -99,3 +306,76 @@ func ReconcileTimer(gvk string) *prometheus.Timer {
 		reconciles.WithLabelValues(gvk).Observe(duration)
 	}))
 }
+
+func TaskFinished(gvk, result string) {
+	defer recoverMetricPanic()
+	taskResults.WithLabelValues(gvk, result).Inc()
+}
+
+func ObserveTaskDuration(gvk, role, task string, seconds float64) {
+	defer recoverMetricPanic()
+	tasks.WithLabelValues(gvk, role, task).Observe(seconds)
+}
+
+func RunnerStarted(gvk string) {
+	defer recoverMetricPanic()
+	runnersInFlight.WithLabelValues(gvk).Inc()
+}
+
+func RunnerExited(gvk string) {
+	defer recoverMetricPanic()
+	runnersInFlight.WithLabelValues(gvk).Dec()
+}
+
+func RunQueued(gvk, priorityClass string) {
+	defer recoverMetricPanic()
+	runsQueued.WithLabelValues(gvk, priorityClass).Inc()
+}
+
+func RunDequeued(gvk, priorityClass string) {
+	defer recoverMetricPanic()
+	runsQueued.WithLabelValues(gvk, priorityClass).Dec()
+}
+
+func ObserveRunQueueWait(gvk, priorityClass string, seconds float64) {
+	defer recoverMetricPanic()
+	runQueueWait.WithLabelValues(gvk, priorityClass).Observe(seconds)
+}
+
+func ProxyRequestDenied(gvk, resourceGVK, verb string) {
+	defer recoverMetricPanic()
+	proxyDeniedRequests.WithLabelValues(gvk, resourceGVK, verb).Inc()
+}
+
+func ProxyCacheHit(gvk, verb string) {
+	defer recoverMetricPanic()
+	proxyCacheHits.WithLabelValues(gvk, verb).Inc()
+}
+
+func ProxyCacheMiss(gvk, verb string) {
+	defer recoverMetricPanic()
+	proxyCacheMisses.WithLabelValues(gvk, verb).Inc()
+}
+
+func ProxyCacheBypassed(gvk, reason string) {
+	defer recoverMetricPanic()
+	proxyCacheBypasses.WithLabelValues(gvk, reason).Inc()
+}
+
+func ProxyUpstreamTimer(gvk, verb string) *prometheus.Timer {
+	defer recoverMetricPanic()
+	return prometheus.NewTimer(prometheus.ObserverFunc(func(duration float64) {
+		proxyUpstreamRequests.WithLabelValues(gvk, verb).Observe(duration)
+	}))
+}
+
+func DependentWatches(gvk, dependentGVK string, crs int) {
+	defer recoverMetricPanic()
+	dependentWatches.WithLabelValues(gvk, dependentGVK).Set(float64(crs))
+}
+
+func DependentWatchStopped(gvk, dependentGVK string) {
+	defer recoverMetricPanic()
+	dependentWatches.DeleteLabelValues(gvk, dependentGVK)
+	dependentWatchesStopped.WithLabelValues(gvk, dependentGVK).Inc()
+}
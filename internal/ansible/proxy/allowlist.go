@@ -0,0 +1,107 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	k8sRequest "github.com/operator-framework/operator-sdk/internal/ansible/proxy/requestfactory"
+)
+
+// allowlistHandler - denies the resource requests of Ansible runs that the API allowlist
+// of their watch does not allow, including the requests of webhook runs. Requests without
+// owner, of watches without allowlist and non-resource requests, e.g. for discovery, are
+// passed along. Watches with an allowlist require token auth, and the tokens of webhook runs
+// name their watch, so that only the runs of the operator can send requests without owner.
+type allowlistHandler struct {
+	next       http.Handler
+	cMap       *controllermap.ControllerMap
+	restMapper meta.RESTMapper
+	pathPrefix string
+}
+
+func (a *allowlistHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	owner, err := getRequestWatchOwnerRef(req)
+	if err != nil || owner == nil {
+		a.next.ServeHTTP(w, req)
+		return
+	}
+	ownerGVK := schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind)
+	contents, ok := a.cMap.Get(ownerGVK)
+	if !ok || len(contents.APIAllowlist) == 0 {
+		a.next.ServeHTTP(w, req)
+		return
+	}
+
+	rf := k8sRequest.RequestInfoFactory{APIPrefixes: sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"), PathPrefix: a.pathPrefix}
+	r, err := rf.NewRequestInfo(req)
+	if err != nil {
+		m := "Could not convert request"
+		log.Error(err, m)
+		http.Error(w, m, http.StatusBadRequest)
+		return
+	}
+	if !r.IsResourceRequest {
+		a.next.ServeHTTP(w, req)
+		return
+	}
+
+	logger := log.WithValues("owner", fmt.Sprintf("%s %s/%s", owner.Kind, owner.Namespace, owner.Name),
+		"verb", r.Verb, "uri", req.RequestURI)
+	if a.restMapper == nil {
+		logger.Info("Denied request, no REST mapper to determine its kind")
+		writeStatus(w, http.StatusForbidden, metav1.StatusReasonForbidden,
+			fmt.Sprintf("%s of %s is not allowed for %s", r.Verb, r.Resource, owner.Kind))
+		return
+	}
+	gvk, err := getGVKFromRequestInfo(r, a.restMapper)
+	if err != nil {
+		logger.Info("Denied request for unknown resource", "resource", r.Resource, "error", err.Error())
+		metrics.ProxyRequestDenied(ownerGVK.String(), r.Resource, r.Verb)
+		writeStatus(w, http.StatusForbidden, metav1.StatusReasonForbidden,
+			fmt.Sprintf("%s of unknown resource %s is not allowed for %s", r.Verb, r.Resource, owner.Kind))
+		return
+	}
+	for _, rule := range contents.APIAllowlist {
+		if rule.Allows(gvk, r.Subresource, r.Verb) {
+			a.next.ServeHTTP(w, req)
+			return
+		}
+	}
+
+	resource := kindString(gvk)
+	if r.Subresource != "" {
+		resource = fmt.Sprintf("subresource %s of %s", r.Subresource, resource)
+	}
+	logger.Info("Denied request not in the API allowlist of the watch", "GVK", gvk.String(),
+		"subresource", r.Subresource, "namespace", r.Namespace, "name", r.Name)
+	metrics.ProxyRequestDenied(ownerGVK.String(), gvk.String(), r.Verb)
+	writeStatus(w, http.StatusForbidden, metav1.StatusReasonForbidden,
+		fmt.Sprintf("%s of %s is not in the API allowlist of %s", r.Verb, resource, kindString(ownerGVK)))
+}
+
+// kindString returns gvk as e.g. "Deployment (apps/v1)".
+func kindString(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf("%s (%s)", gvk.Kind, gvk.GroupVersion().String())
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kcorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	kmetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+func TestAllowlistHandler(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	otherGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Other"}
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(kcorev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	restMapper.Add(kcorev1.SchemeGroupVersion.WithKind("Secret"), meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	cMap := controllermap.NewControllerMap()
+	cMap.Store(gvk, &controllermap.Contents{APIAllowlist: []watches.APIRule{
+		{Kind: "ConfigMap", Verbs: []string{"get", "list", "watch"}},
+		{Group: "apps", Kind: "Deployment", Verbs: []string{"*"}, Subresources: []string{"status"}},
+	}}, nil)
+	cMap.Store(otherGVK, &controllermap.Contents{}, nil)
+
+	passed := false
+	h := &allowlistHandler{
+		next:       http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { passed = true }),
+		cMap:       cMap,
+		restMapper: restMapper,
+	}
+
+	owner := &kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: "example"},
+		Namespace:      "default",
+	}
+	webhookOwner := &kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: "new"},
+		Namespace:      "default",
+		Webhook:        true,
+	}
+	otherOwner := &kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{APIVersion: otherGVK.GroupVersion().String(), Kind: otherGVK.Kind, Name: "example"},
+		Namespace:      "default",
+	}
+
+	testCases := []struct {
+		name     string
+		owner    *kubeconfig.NamespacedOwnerReference
+		method   string
+		path     string
+		expected bool
+	}{
+		{
+			name:     "no owner",
+			method:   http.MethodDelete,
+			path:     "/api/v1/namespaces/default/secrets/test",
+			expected: true,
+		},
+		{
+			name:     "owner without allowlist",
+			owner:    otherOwner,
+			method:   http.MethodDelete,
+			path:     "/api/v1/namespaces/default/secrets/test",
+			expected: true,
+		},
+		{
+			name:     "discovery",
+			owner:    owner,
+			method:   http.MethodGet,
+			path:     "/apis/apps/v1",
+			expected: true,
+		},
+		{
+			name:     "allowed list",
+			owner:    owner,
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/default/configmaps",
+			expected: true,
+		},
+		{
+			name:   "denied verb",
+			owner:  owner,
+			method: http.MethodPost,
+			path:   "/api/v1/namespaces/default/configmaps",
+		},
+		{
+			name:   "denied kind",
+			owner:  owner,
+			method: http.MethodGet,
+			path:   "/api/v1/namespaces/default/secrets/test",
+		},
+		{
+			name:   "unknown resource",
+			owner:  owner,
+			method: http.MethodGet,
+			path:   "/api/v1/namespaces/default/unknowns",
+		},
+		{
+			name:     "allowed by webhook run",
+			owner:    webhookOwner,
+			method:   http.MethodGet,
+			path:     "/api/v1/namespaces/default/configmaps",
+			expected: true,
+		},
+		{
+			name:   "denied to webhook run",
+			owner:  webhookOwner,
+			method: http.MethodGet,
+			path:   "/api/v1/namespaces/default/secrets/test",
+		},
+		{
+			name:     "allowed subresource",
+			owner:    owner,
+			method:   http.MethodPut,
+			path:     "/apis/apps/v1/namespaces/default/deployments/test/status",
+			expected: true,
+		},
+		{
+			name:   "denied subresource",
+			owner:  owner,
+			method: http.MethodPut,
+			path:   "/apis/apps/v1/namespaces/default/deployments/test/scale",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			passed = false
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.owner != nil {
+				req = req.WithContext(context.WithValue(req.Context(), ownerKey{}, tc.owner))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if passed != tc.expected {
+				t.Fatalf("Unexpected result: passed %v, expected %v", passed, tc.expected)
+			}
+			if !tc.expected && rec.Code != http.StatusForbidden {
+				t.Fatalf("Unexpected status %d for denied request", rec.Code)
+			}
+		})
+	}
+}
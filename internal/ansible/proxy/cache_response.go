@@ -25,16 +25,15 @@ import (
 
 	libhandler "github.com/operator-framework/operator-lib/handler"
 	"k8s.io/apimachinery/pkg/api/meta"
-	metainternalscheme "k8s.io/apimachinery/pkg/apis/meta/internalversion/scheme"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/fields"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
 	k8sRequest "github.com/operator-framework/operator-sdk/internal/ansible/proxy/requestfactory"
 )
@@ -52,122 +51,165 @@ type cacheResponseHandler struct {
 	injectOwnerRef    bool
 	apiResources      *apiResources
 	skipPathRegexp    []*regexp.Regexp
+	pathPrefix        string
+	watches           *watchBroadcaster
 }
 
 func (c *cacheResponseHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	switch req.Method {
-	case http.MethodGet:
-		// GET request means we need to check the cache
-		rf := k8sRequest.RequestInfoFactory{APIPrefixes: sets.NewString("api", "apis"),
-			GrouplessAPIPrefixes: sets.NewString("api")}
-		r, err := rf.NewRequestInfo(req)
-		if err != nil {
-			log.Error(err, "Failed to convert request")
-			break
-		}
-
-		// Skip cache for non-cacheable requests, not a part of skipCacheLookup for performance.
-		if !r.IsResourceRequest || !(r.Subresource == "" || r.Subresource == "status") {
-			log.V(2).Info("Skipping cache lookup", "resource", r)
-			break
-		}
+	rf := k8sRequest.RequestInfoFactory{APIPrefixes: sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"), PathPrefix: c.pathPrefix}
+	r, err := rf.NewRequestInfo(req)
+	if err != nil {
+		log.Error(err, "Failed to convert request")
+		c.next.ServeHTTP(w, req)
+		return
+	}
+	if !r.IsResourceRequest {
+		c.next.ServeHTTP(w, req)
+		return
+	}
 
-		if c.restMapper == nil {
-			c.restMapper = meta.NewDefaultRESTMapper([]schema.GroupVersion{schema.GroupVersion{
-				Group:   r.APIGroup,
-				Version: r.APIVersion,
-			}})
-		}
-		k, err := getGVKFromRequestInfo(r, c.restMapper)
-		if err != nil {
-			// break here in case resource doesn't exist in cache
+	if c.restMapper == nil {
+		c.restMapper = meta.NewDefaultRESTMapper([]schema.GroupVersion{schema.GroupVersion{
+			Group:   r.APIGroup,
+			Version: r.APIVersion,
+		}})
+	}
+	k, err := getGVKFromRequestInfo(r, c.restMapper)
+	if err != nil {
+		if req.Method == http.MethodGet {
+			// pass along in case resource doesn't exist in cache
 			log.Error(err, "Cache miss, can not find in rest mapper")
-			break
 		}
+		c.next.ServeHTTP(w, req)
+		return
+	}
 
-		if c.skipCacheLookup(r, k, req) {
-			log.V(2).Info("Skipping cache lookup", "resource", r)
-			break
-		}
+	// GET request means we need to check the cache
+	if req.Method == http.MethodGet && c.serveFromCache(w, req, r, k) {
+		// Return so that request isn't passed along to APIserver
+		return
+	}
 
-		// Determine if the resource is virtual. If it is then we should not attempt to use cache
-		isVR, err := c.apiResources.IsVirtualResource(k)
-		if err != nil {
-			// break here in case we can not understand if virtual resource or not
-			log.Error(err, "Unable to determine if virtual resource", "gvk", k)
-			break
-		}
+	// Watches last as long as the client wants, so their latency says nothing.
+	if r.Verb != "watch" {
+		timer := metrics.ProxyUpstreamTimer(k.String(), r.Verb)
+		defer timer.ObserveDuration()
+	}
+	c.next.ServeHTTP(w, req)
+}
 
-		if isVR {
-			log.V(2).Info("Virtual resource, must ask the cluster API", "gvk", k)
-			break
-		}
+// serveFromCache - answers the read request r from the informer cache. It returns false
+// if the request must be passed along to the API server.
+func (c *cacheResponseHandler) serveFromCache(w http.ResponseWriter, req *http.Request,
+	r *k8sRequest.RequestInfo, k schema.GroupVersionKind) bool {
+	// Skip cache for non-cacheable requests, not a part of skipCacheLookup for performance.
+	if !(r.Subresource == "" || r.Subresource == "status") {
+		log.V(2).Info("Skipping cache lookup", "resource", r)
+		metrics.ProxyCacheBypassed(k.String(), "subresource")
+		return false
+	}
 
-		var m marshaler
+	if reason := c.skipCacheLookup(r, k, req); reason != "" {
+		log.V(2).Info("Skipping cache lookup", "resource", r, "reason", reason)
+		metrics.ProxyCacheBypassed(k.String(), reason)
+		return false
+	}
 
-		log.V(2).Info("Get resource in our cache", "r", r)
-		if r.Verb == "list" {
-			m, err = c.getListFromCache(r, req, k)
-			if err != nil {
-				break
-			}
-		} else {
-			m, err = c.getObjectFromCache(r, req, k)
-			if err != nil {
-				break
-			}
-		}
+	// Determine if the resource is virtual. If it is then we should not attempt to use cache
+	isVR, err := c.apiResources.IsVirtualResource(k)
+	if err != nil {
+		// pass along in case we can not understand if virtual resource or not
+		log.Error(err, "Unable to determine if virtual resource", "gvk", k)
+		return false
+	}
 
-		i := bytes.Buffer{}
-		resp, err := m.MarshalJSON()
-		if err != nil {
-			// return will give a 500
-			log.Error(err, "Failed to marshal data")
-			http.Error(w, "", http.StatusInternalServerError)
-			return
-		}
+	if isVR {
+		log.V(2).Info("Virtual resource, must ask the cluster API", "gvk", k)
+		metrics.ProxyCacheBypassed(k.String(), "virtual")
+		return false
+	}
 
-		// Set Content-Type header
-		w.Header().Set("Content-Type", "application/json")
-		// Set X-Cache header to signal that response is served from Cache
-		w.Header().Set("X-Cache", "HIT")
-		if err := json.Indent(&i, resp, "", "  "); err != nil {
-			log.Error(err, "Failed to indent json")
-		}
-		_, err = w.Write(i.Bytes())
+	informerCache, done := c.cacheFor(req, k)
+
+	var sel *listSelectors
+	if r.Verb == "list" || r.Verb == "watch" {
+		sel, err = parseListSelectors(req)
 		if err != nil {
-			log.Error(err, "Failed to write response")
-			http.Error(w, "", http.StatusInternalServerError)
-			return
+			// The API server reports invalid selectors.
+			log.V(2).Info("Skipping cache lookup", "resource", r, "reason", err.Error())
+			metrics.ProxyCacheBypassed(k.String(), "selector")
+			return false
 		}
+	}
 
-		// Return so that request isn't passed along to APIserver
-		log.Info("Read object from cache", "resource", r)
-		return
+	if r.Verb == "watch" {
+		return c.serveWatchFromCache(w, req, r, k, sel, informerCache, done)
 	}
-	c.next.ServeHTTP(w, req)
+
+	var m marshaler
+
+	log.V(2).Info("Get resource in our cache", "r", r)
+	if r.Verb == "list" {
+		m, err = c.getListFromCache(r, k, sel, informerCache)
+	} else {
+		m, err = c.getObjectFromCache(r, req, k, informerCache)
+	}
+	if err != nil {
+		metrics.ProxyCacheMiss(k.String(), r.Verb)
+		return false
+	}
+
+	i := bytes.Buffer{}
+	resp, err := m.MarshalJSON()
+	if err != nil {
+		// return will give a 500
+		log.Error(err, "Failed to marshal data")
+		http.Error(w, "", http.StatusInternalServerError)
+		return true
+	}
+
+	// Set Content-Type header
+	w.Header().Set("Content-Type", "application/json")
+	// Set X-Cache header to signal that response is served from Cache
+	w.Header().Set("X-Cache", "HIT")
+	if err := json.Indent(&i, resp, "", "  "); err != nil {
+		log.Error(err, "Failed to indent json")
+	}
+	_, err = w.Write(i.Bytes())
+	if err != nil {
+		log.Error(err, "Failed to write response")
+		http.Error(w, "", http.StatusInternalServerError)
+		return true
+	}
+
+	metrics.ProxyCacheHit(k.String(), r.Verb)
+	log.Info("Read object from cache", "resource", r)
+	return true
 }
 
-// skipCacheLookup - determine if we should skip the cache lookup
+// skipCacheLookup - determine if we should skip the cache lookup, and why. It returns
+// an empty reason if the cache should be used.
 func (c *cacheResponseHandler) skipCacheLookup(r *k8sRequest.RequestInfo, gvk schema.GroupVersionKind,
-	req *http.Request) bool {
+	req *http.Request) string {
 
-	skip := matchesRegexp(req.URL.String(), c.skipPathRegexp)
-	if skip {
-		return true
+	u := *req.URL
+	u.Path = k8sRequest.TrimPathPrefix(c.pathPrefix, u.Path)
+	if matchesRegexp(u.String(), c.skipPathRegexp) {
+		return "skip_path"
 	}
 
-	owner, err := getRequestOwnerRef(req)
+	owner, err := getRequestWatchOwnerRef(req)
 	if err != nil {
 		log.Error(err, "Could not get owner reference from proxy.")
-		return false
+		return ""
 	}
 	if owner != nil {
 		ownerGV, err := schema.ParseGroupVersion(owner.APIVersion)
 		if err != nil {
 			m := fmt.Sprintf("Could not get group version for: %v.", owner)
 			log.Error(err, m)
-			return false
+			return ""
 		}
 		ownerGVK := schema.GroupVersionKind{
 			Group:   ownerGV.Group,
@@ -178,11 +220,17 @@ func (c *cacheResponseHandler) skipCacheLookup(r *k8sRequest.RequestInfo, gvk sc
 		relatedController, ok := c.cMap.Get(ownerGVK)
 		if !ok {
 			log.Info("Could not find controller for gvk.", "ownerGVK:", ownerGVK)
-			return false
+			return ""
 		}
 		if relatedController.Blacklist[gvk] {
 			log.Info("Skipping, because gvk is blacklisted", "GVK", gvk)
-			return true
+			return "blacklist"
+		}
+		// The cache holds what the operator can read, not what the impersonated
+		// ServiceAccount can.
+		if relatedController.Impersonation != nil {
+			log.V(2).Info("Skipping, because requests of the owner are impersonated", "GVK", gvk)
+			return "impersonation"
 		}
 	}
 	// check if resource doesn't exist in watched namespaces
@@ -191,16 +239,46 @@ func (c *cacheResponseHandler) skipCacheLookup(r *k8sRequest.RequestInfo, gvk sc
 	_, allNsPresent := c.watchedNamespaces[metav1.NamespaceAll]
 	_, reqNsPresent := c.watchedNamespaces[r.Namespace]
 	if !allNsPresent && !reqNsPresent {
-		return true
+		return "namespace"
 	}
 
 	if strings.HasPrefix(r.Path, "/version") {
 		// Temporarily pass along to API server
 		// Ideally we cache this response as well
-		return true
+		return "version"
 	}
 
-	return false
+	return ""
+}
+
+// cacheFor - returns the informer cache to read k from for req, and a channel closed once
+// that cache is stopped. The dependent resources read for an owner whose controller
+// watches them are read from the cache of their kind in its Dependents, so that the
+// informer is stopped once no CR depends on the kind anymore.
+func (c *cacheResponseHandler) cacheFor(req *http.Request, k schema.GroupVersionKind) (cache.Cache, <-chan struct{}) {
+	owner, err := getRequestOwnerRef(req)
+	if err != nil || owner == nil {
+		return c.informerCache, nil
+	}
+	ownerGV, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return c.informerCache, nil
+	}
+	contents, ok := c.cMap.Get(ownerGV.WithKind(owner.Kind))
+	if !ok || contents.Dependents == nil || !contents.WatchDependentResources {
+		return c.informerCache, nil
+	}
+	// The custom resources of the controllers are always in the cache of the manager.
+	if _, ok := c.cMap.Get(k); ok {
+		return c.informerCache, nil
+	}
+	nn := types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}
+	dependentCache, done, err := contents.Dependents.Reference(nn, k)
+	if err != nil {
+		log.Error(err, "Failed to get the informer cache of dependent resources", "GVK", k)
+		return c.informerCache, nil
+	}
+	return dependentCache, done
 }
 
 func (c *cacheResponseHandler) recoverDependentWatches(req *http.Request, un *unstructured.Unstructured) {
@@ -240,56 +318,44 @@ func (c *cacheResponseHandler) recoverDependentWatches(req *http.Request, un *un
 	}
 }
 
-func (c *cacheResponseHandler) getListFromCache(r *k8sRequest.RequestInfo, req *http.Request,
-	k schema.GroupVersionKind) (marshaler, error) {
-	k8sListOpts := &metav1.ListOptions{}
-	if err := metainternalscheme.ParameterCodec.DecodeParameters(req.URL.Query(), metav1.SchemeGroupVersion,
-		k8sListOpts); err != nil {
-		log.Error(err, "Unable to decode list options from request")
-		return nil, err
-	}
+func (c *cacheResponseHandler) getListFromCache(r *k8sRequest.RequestInfo, k schema.GroupVersionKind,
+	sel *listSelectors, informerCache cache.Cache) (marshaler, error) {
 	clientListOpts := []client.ListOption{
 		client.InNamespace(r.Namespace),
-	}
-	if k8sListOpts.LabelSelector != "" {
-		sel, err := labels.ConvertSelectorToLabelsMap(k8sListOpts.LabelSelector)
-		if err != nil {
-			log.Error(err, "Unable to convert label selectors for the client")
-			return nil, err
-		}
-		clientListOpts = append(clientListOpts, client.MatchingLabels(sel))
-	}
-	if k8sListOpts.FieldSelector != "" {
-		sel, err := fields.ParseSelector(k8sListOpts.FieldSelector)
-		if err != nil {
-			log.Error(err, "Unable to parse field selectors for the client")
-			return nil, err
-		}
-		clientListOpts = append(clientListOpts, client.MatchingFieldsSelector{Selector: sel})
+		client.MatchingLabelsSelector{Selector: sel.labels},
 	}
 	k.Kind = k.Kind + "List"
 	un := unstructured.UnstructuredList{}
 	un.SetGroupVersionKind(k)
 	ctx, cancel := context.WithTimeout(context.Background(), cacheEstablishmentTimeout)
 	defer cancel()
-	err := c.informerCache.List(ctx, &un, clientListOpts...)
+	err := informerCache.List(ctx, &un, clientListOpts...)
 	if err != nil {
 		// break here in case resource doesn't exist in cache but exists on APIserver
 		// This is very unlikely but provides user with expected 404
 		log.Info(fmt.Sprintf("cache miss: %v err-%v", k, err))
 		return nil, err
 	}
+	if !sel.fields.Empty() {
+		items := un.Items[:0]
+		for i := range un.Items {
+			if sel.matches(r, &un.Items[i]) {
+				items = append(items, un.Items[i])
+			}
+		}
+		un.Items = items
+	}
 	return &un, nil
 }
 
 func (c *cacheResponseHandler) getObjectFromCache(r *k8sRequest.RequestInfo, req *http.Request,
-	k schema.GroupVersionKind) (marshaler, error) {
+	k schema.GroupVersionKind, informerCache cache.Cache) (marshaler, error) {
 	un := &unstructured.Unstructured{}
 	un.SetGroupVersionKind(k)
 	obj := client.ObjectKey{Namespace: r.Namespace, Name: r.Name}
 	ctx, cancel := context.WithTimeout(context.Background(), cacheEstablishmentTimeout)
 	defer cancel()
-	err := c.informerCache.Get(ctx, obj, un)
+	err := informerCache.Get(ctx, obj, un)
 	if err != nil {
 		// break here in case resource doesn't exist in cache but exists on APIserver
 		// This is very unlikely but provides user with expected 404
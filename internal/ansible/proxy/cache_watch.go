@@ -0,0 +1,369 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	metainternalscheme "k8s.io/apimachinery/pkg/apis/meta/internalversion/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+	k8sRequest "github.com/operator-framework/operator-sdk/internal/ansible/proxy/requestfactory"
+)
+
+// cachedSelectorFields are the fields of field selectors that can be evaluated against
+// cached objects.
+var cachedSelectorFields = map[string]func(*unstructured.Unstructured) string{
+	"metadata.name":      (*unstructured.Unstructured).GetName,
+	"metadata.namespace": (*unstructured.Unstructured).GetNamespace,
+}
+
+// watchEventBuffer is the number of events a watch served from the cache may fall
+// behind before it is closed. Clients re-establish closed watches.
+const watchEventBuffer = 256
+
+// listSelectors - the options and selectors of a list or watch request.
+type listSelectors struct {
+	options metav1.ListOptions
+	labels  labels.Selector
+	fields  fields.Selector
+}
+
+// parseListSelectors parses the options of the list or watch request req. It fails for
+// selectors with fields that cannot be evaluated against cached objects.
+func parseListSelectors(req *http.Request) (*listSelectors, error) {
+	sel := &listSelectors{}
+	if err := metainternalscheme.ParameterCodec.DecodeParameters(req.URL.Query(), metav1.SchemeGroupVersion,
+		&sel.options); err != nil {
+		return nil, fmt.Errorf("unable to decode list options from request: %v", err)
+	}
+	var err error
+	if sel.labels, err = labels.Parse(sel.options.LabelSelector); err != nil {
+		return nil, fmt.Errorf("unable to parse label selector: %v", err)
+	}
+	if sel.fields, err = fields.ParseSelector(sel.options.FieldSelector); err != nil {
+		return nil, fmt.Errorf("unable to parse field selector: %v", err)
+	}
+	for _, req := range sel.fields.Requirements() {
+		if _, ok := cachedSelectorFields[req.Field]; !ok {
+			return nil, fmt.Errorf("field selector on %s cannot be evaluated against the cache", req.Field)
+		}
+	}
+	return sel, nil
+}
+
+// matches returns whether u is in the namespace and has the name of r, if any, and
+// matches the selectors.
+func (s *listSelectors) matches(r *k8sRequest.RequestInfo, u *unstructured.Unstructured) bool {
+	if r.Namespace != "" && u.GetNamespace() != r.Namespace {
+		return false
+	}
+	if r.Name != "" && u.GetName() != r.Name {
+		return false
+	}
+	if !s.labels.Matches(labels.Set(u.GetLabels())) {
+		return false
+	}
+	if s.fields.Empty() {
+		return true
+	}
+	set := fields.Set{}
+	for field, get := range cachedSelectorFields {
+		set[field] = get(u)
+	}
+	return s.fields.Matches(set)
+}
+
+// informerEvent - a change of an object seen by an informer. Old is only set for
+// modifications.
+type informerEvent struct {
+	eventType watch.EventType
+	old       *unstructured.Unstructured
+	obj       *unstructured.Unstructured
+}
+
+// informerKey - identifies the informer of a GVK in an informer cache.
+type informerKey struct {
+	cache cache.Cache
+	gvk   schema.GroupVersionKind
+}
+
+// watchSubscriber - receives the informer events of a GVK for a watch request.
+type watchSubscriber struct {
+	events chan informerEvent
+	// closed is guarded by the mutex of the broadcaster.
+	closed bool
+}
+
+// watchBroadcaster - fans the events of the shared informers out to the watch requests
+// served from the cache. Event handlers cannot be removed from informers, so a single
+// handler is added per informer and watch requests subscribe to it.
+type watchBroadcaster struct {
+	mu          sync.Mutex
+	handlers    map[informerKey]bool
+	subscribers map[informerKey]map[*watchSubscriber]struct{}
+}
+
+func newWatchBroadcaster() *watchBroadcaster {
+	return &watchBroadcaster{
+		handlers:    map[informerKey]bool{},
+		subscribers: map[informerKey]map[*watchSubscriber]struct{}{},
+	}
+}
+
+// subscribe subscribes to the events of the informer of gvk in informerCache, which is
+// started if needed. done, if not nil, is closed once informerCache is stopped, which
+// closes the subscribers of its informers.
+func (b *watchBroadcaster) subscribe(ctx context.Context, informerCache cache.Cache, done <-chan struct{},
+	gvk schema.GroupVersionKind) (informerKey, *watchSubscriber, error) {
+	key := informerKey{cache: informerCache, gvk: gvk}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	informer, err := informerCache.GetInformer(ctx, u)
+	if err != nil {
+		return key, nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.handlers[key] {
+		informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				b.broadcast(key, watch.Added, nil, obj)
+			},
+			UpdateFunc: func(old, obj interface{}) {
+				b.broadcast(key, watch.Modified, old, obj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				b.broadcast(key, watch.Deleted, nil, obj)
+			},
+		})
+		b.handlers[key] = true
+		if done != nil {
+			go func() {
+				<-done
+				b.forget(key)
+			}()
+		}
+	}
+	sub := &watchSubscriber{events: make(chan informerEvent, watchEventBuffer)}
+	if b.subscribers[key] == nil {
+		b.subscribers[key] = map[*watchSubscriber]struct{}{}
+	}
+	b.subscribers[key][sub] = struct{}{}
+	return key, sub, nil
+}
+
+// unsubscribe stops sending events of the informer of key to sub.
+func (b *watchBroadcaster) unsubscribe(key informerKey, sub *watchSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closeSubscriber(key, sub)
+}
+
+// forget closes the subscribers of the stopped informer of key, and forgets its handler.
+func (b *watchBroadcaster) forget(key informerKey) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers[key] {
+		b.closeSubscriber(key, sub)
+	}
+	delete(b.subscribers, key)
+	delete(b.handlers, key)
+}
+
+func (b *watchBroadcaster) closeSubscriber(key informerKey, sub *watchSubscriber) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.events)
+	delete(b.subscribers[key], sub)
+}
+
+// broadcast sends an event to the subscribers of the informer of key. It never blocks the
+// informer: subscribers that fall too far behind are closed.
+func (b *watchBroadcaster) broadcast(key informerKey, eventType watch.EventType, old, obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	ev := informerEvent{eventType: eventType, obj: u}
+	if o, ok := old.(*unstructured.Unstructured); ok {
+		ev.old = o
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers[key] {
+		select {
+		case sub.events <- ev:
+		default:
+			log.Info("Closing watch served from cache that fell behind", "GVK", key.gvk)
+			b.closeSubscriber(key, sub)
+		}
+	}
+}
+
+// serveWatchFromCache - serves the watch request r from the informer of k in informerCache,
+// which is stopped once done is closed, if it is not nil. The current objects are sent as
+// ADDED events, followed by the changes seen by the informer. It returns false if the
+// request must be passed along to the API server instead.
+func (c *cacheResponseHandler) serveWatchFromCache(w http.ResponseWriter, req *http.Request,
+	r *k8sRequest.RequestInfo, k schema.GroupVersionKind, sel *listSelectors, informerCache cache.Cache,
+	done <-chan struct{}) bool {
+	// The informer only knows the current state, so it cannot replay the changes
+	// since a given resource version.
+	if rv := sel.options.ResourceVersion; rv != "" && rv != "0" {
+		log.V(2).Info("Skipping cache lookup of watch with resource version", "resource", r)
+		metrics.ProxyCacheBypassed(k.String(), "resource_version")
+		return false
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.V(2).Info("Skipping cache lookup of watch, response cannot be streamed", "resource", r)
+		metrics.ProxyCacheBypassed(k.String(), "streaming")
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), cacheEstablishmentTimeout)
+	defer cancel()
+	key, sub, err := c.watches.subscribe(ctx, informerCache, done, k)
+	if err != nil {
+		log.Info(fmt.Sprintf("Cache miss: %v err-%v", k, err))
+		metrics.ProxyCacheMiss(k.String(), r.Verb)
+		return false
+	}
+	defer c.watches.unsubscribe(key, sub)
+
+	listGVK := k
+	listGVK.Kind = k.Kind + "List"
+	current := unstructured.UnstructuredList{}
+	current.SetGroupVersionKind(listGVK)
+	if err := informerCache.List(ctx, &current, client.InNamespace(r.Namespace),
+		client.MatchingLabelsSelector{Selector: sel.labels}); err != nil {
+		log.Info(fmt.Sprintf("Cache miss: %v err-%v", k, err))
+		metrics.ProxyCacheMiss(k.String(), r.Verb)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", "HIT")
+	w.WriteHeader(http.StatusOK)
+	metrics.ProxyCacheHit(k.String(), r.Verb)
+	log.Info("Serving watch from cache", "resource", r)
+
+	// sent holds the resource versions of the objects sent to the client, to skip the
+	// events of the informer for objects it already has.
+	sent := map[string]string{}
+	send := func(eventType watch.EventType, u *unstructured.Unstructured) error {
+		raw, err := u.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		event := metav1.WatchEvent{Type: string(eventType), Object: runtime.RawExtension{Raw: raw}}
+		if err := json.NewEncoder(w).Encode(event); err != nil {
+			return err
+		}
+		flusher.Flush()
+		key := u.GetNamespace() + "/" + u.GetName()
+		if eventType == watch.Deleted {
+			delete(sent, key)
+		} else {
+			sent[key] = u.GetResourceVersion()
+		}
+		return nil
+	}
+	isSent := func(u *unstructured.Unstructured) bool {
+		rv, ok := sent[u.GetNamespace()+"/"+u.GetName()]
+		return ok && rv == u.GetResourceVersion()
+	}
+
+	for i := range current.Items {
+		if sel.matches(r, &current.Items[i]) {
+			if err := send(watch.Added, &current.Items[i]); err != nil {
+				log.V(1).Info("Stopped watch served from cache", "reason", err.Error())
+				return true
+			}
+		}
+	}
+	flusher.Flush()
+
+	var timeout <-chan time.Time
+	if sel.options.TimeoutSeconds != nil {
+		timer := time.NewTimer(time.Duration(*sel.options.TimeoutSeconds) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		var ev informerEvent
+		select {
+		case <-req.Context().Done():
+			return true
+		case <-timeout:
+			return true
+		case ev, ok = <-sub.events:
+			if !ok {
+				return true
+			}
+		}
+
+		var err error
+		matches := sel.matches(r, ev.obj)
+		switch ev.eventType {
+		case watch.Added:
+			if matches && !isSent(ev.obj) {
+				err = send(watch.Added, ev.obj)
+			}
+		case watch.Modified:
+			// Objects entering or leaving the selection are added or deleted.
+			oldMatches := ev.old != nil && sel.matches(r, ev.old)
+			switch {
+			case matches && oldMatches && !isSent(ev.obj):
+				err = send(watch.Modified, ev.obj)
+			case matches && !oldMatches && !isSent(ev.obj):
+				err = send(watch.Added, ev.obj)
+			case !matches && oldMatches:
+				err = send(watch.Deleted, ev.obj)
+			}
+		case watch.Deleted:
+			if matches {
+				err = send(watch.Deleted, ev.obj)
+			}
+		}
+		if err != nil {
+			log.V(1).Info("Stopped watch served from cache", "reason", err.Error())
+			return true
+		}
+	}
+}
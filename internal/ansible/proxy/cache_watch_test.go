@@ -0,0 +1,232 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	kcorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	kmetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeCache is an informer cache that reads objects from a fake client.
+type fakeCache struct {
+	*informertest.FakeInformers
+	reader client.Reader
+}
+
+func (c *fakeCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.reader.Get(ctx, key, obj)
+}
+
+func (c *fakeCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader.List(ctx, list, opts...)
+}
+
+func newConfigMap(name, app, resourceVersion string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(kcorev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	u.SetNamespace("default")
+	u.SetName(name)
+	u.SetLabels(map[string]string{"app": app})
+	u.SetResourceVersion(resourceVersion)
+	return u
+}
+
+func newCacheTestHandler(t *testing.T, objs ...*unstructured.Unstructured) (*cacheResponseHandler, *fakeCache,
+	*bool) {
+	configMapGVK := kcorev1.SchemeGroupVersion.WithKind("ConfigMap")
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(configMapGVK, meta.RESTScopeNamespace)
+
+	builder := fake.NewClientBuilder()
+	for _, obj := range objs {
+		// The fake client stores the typed objects of its scheme.
+		cm := &kcorev1.ConfigMap{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, cm); err != nil {
+			t.Fatal(err)
+		}
+		builder = builder.WithObjects(cm)
+	}
+	informerCache := &fakeCache{FakeInformers: &informertest.FakeInformers{}, reader: builder.Build()}
+
+	upstream := false
+	h := &cacheResponseHandler{
+		next:              http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { upstream = true }),
+		informerCache:     informerCache,
+		restMapper:        restMapper,
+		watchedNamespaces: map[string]interface{}{kmetav1.NamespaceAll: nil},
+		apiResources: &apiResources{
+			mu: &sync.RWMutex{},
+			gvkToAPIResource: map[string]kmetav1.APIResource{
+				configMapGVK.String(): {Verbs: kmetav1.Verbs{"get", "list", "watch"}},
+			},
+		},
+		watches: newWatchBroadcaster(),
+	}
+	return h, informerCache, &upstream
+}
+
+func TestCacheResponseHandlerSelectors(t *testing.T) {
+	h, _, upstream := newCacheTestHandler(t, newConfigMap("a", "x", ""), newConfigMap("b", "x", ""),
+		newConfigMap("c", "y", ""))
+
+	testCases := []struct {
+		name             string
+		url              string
+		expectedUpstream bool
+		expectedNames    []string
+	}{
+		{
+			name:          "label selector",
+			url:           "/api/v1/namespaces/default/configmaps?labelSelector=app%3Dx",
+			expectedNames: []string{"a", "b"},
+		},
+		{
+			name:          "set-based label selector",
+			url:           "/api/v1/namespaces/default/configmaps?labelSelector=app+notin+%28x%29",
+			expectedNames: []string{"c"},
+		},
+		{
+			name:          "name field selector",
+			url:           "/api/v1/namespaces/default/configmaps?fieldSelector=metadata.name%3Db",
+			expectedNames: []string{"b"},
+		},
+		{
+			name:          "namespace and name field selector",
+			url:           "/api/v1/configmaps?fieldSelector=metadata.namespace%3Ddefault%2Cmetadata.name%21%3Db",
+			expectedNames: []string{"a", "c"},
+		},
+		{
+			name:             "unsupported field selector",
+			url:              "/api/v1/namespaces/default/configmaps?fieldSelector=data.key%3Dvalue",
+			expectedUpstream: true,
+		},
+		{
+			name:             "watch from resource version",
+			url:              "/api/v1/namespaces/default/configmaps?watch=true&resourceVersion=42",
+			expectedUpstream: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			*upstream = false
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			if *upstream != tc.expectedUpstream {
+				t.Fatalf("Unexpected upstream %v, expected %v", *upstream, tc.expectedUpstream)
+			}
+			if tc.expectedUpstream {
+				return
+			}
+			list := &unstructured.UnstructuredList{}
+			if err := list.UnmarshalJSON(rec.Body.Bytes()); err != nil {
+				t.Fatalf("Failed to unmarshal list %s: %v", rec.Body.String(), err)
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.GetName())
+			}
+			if len(names) != len(tc.expectedNames) {
+				t.Fatalf("Unexpected items %v, expected %v", names, tc.expectedNames)
+			}
+			for i := range names {
+				if names[i] != tc.expectedNames[i] {
+					t.Fatalf("Unexpected items %v, expected %v", names, tc.expectedNames)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheResponseHandlerWatch(t *testing.T) {
+	h, informerCache, upstream := newCacheTestHandler(t, newConfigMap("a", "x", "1"), newConfigMap("b", "y", "1"))
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		server.URL+"/api/v1/namespaces/default/configmaps?watch=true&labelSelector=app%3Dx", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Cache") != "HIT" {
+		t.Fatalf("Expected watch to be served from cache")
+	}
+
+	events := bufio.NewScanner(resp.Body)
+	expectEvent := func(eventType watch.EventType, name, resourceVersion string) {
+		t.Helper()
+		if !events.Scan() {
+			t.Fatalf("Watch ended, expected %s event for %s: %v", eventType, name, events.Err())
+		}
+		event := struct {
+			Type   watch.EventType
+			Object unstructured.Unstructured
+		}{}
+		if err := json.Unmarshal(events.Bytes(), &event); err != nil {
+			t.Fatalf("Failed to unmarshal event %s: %v", events.Text(), err)
+		}
+		if event.Type != eventType || event.Object.GetName() != name ||
+			event.Object.GetResourceVersion() != resourceVersion {
+			t.Fatalf("Unexpected event %s, expected %s event for %s at %s", events.Text(), eventType, name,
+				resourceVersion)
+		}
+	}
+
+	// The current objects are sent first, and only after the subscription to the informer.
+	expectEvent(watch.Added, "a", "1")
+	informer, err := informerCache.FakeInformerForKind(ctx, kcorev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events for objects the client has are skipped.
+	informer.Add(newConfigMap("a", "x", "1"))
+	informer.Update(newConfigMap("a", "x", "1"), newConfigMap("a", "x", "2"))
+	expectEvent(watch.Modified, "a", "2")
+	// Objects that do not match the selector are filtered.
+	informer.Update(newConfigMap("b", "y", "1"), newConfigMap("b", "y", "2"))
+	// Objects entering and leaving the selection are added and deleted.
+	informer.Update(newConfigMap("b", "y", "2"), newConfigMap("b", "x", "3"))
+	expectEvent(watch.Added, "b", "3")
+	informer.Update(newConfigMap("b", "x", "3"), newConfigMap("b", "y", "4"))
+	expectEvent(watch.Deleted, "b", "4")
+	informer.Delete(newConfigMap("a", "x", "2"))
+	expectEvent(watch.Deleted, "a", "2")
+
+	if *upstream {
+		t.Fatalf("Expected watch not to be passed along to the API server")
+	}
+}
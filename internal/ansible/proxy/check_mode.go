@@ -0,0 +1,75 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	k8sRequest "github.com/operator-framework/operator-sdk/internal/ansible/proxy/requestfactory"
+)
+
+var (
+	// mutatingVerbs are the verbs of the requests that are sent as dry runs in check mode.
+	mutatingVerbs = sets.NewString("create", "update", "patch", "delete", "deletecollection")
+	// connectSubresources are the subresources that connect to pods or services, whose
+	// requests cannot be sent as dry runs and are denied in check mode.
+	connectSubresources = sets.NewString("exec", "attach", "portforward", "proxy")
+)
+
+// checkModeHandler - keeps the Ansible runs in check mode from changing any resource. Their
+// mutating requests are sent as server-side dry runs, so that they are validated and
+// answered as usual without being persisted, and their requests that connect to pods or
+// services are denied.
+func checkModeHandler(h http.Handler, pathPrefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		owner, err := getRequestOwnerRef(req)
+		if err != nil || owner == nil || !owner.CheckMode {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		rf := k8sRequest.RequestInfoFactory{APIPrefixes: sets.NewString("api", "apis"),
+			GrouplessAPIPrefixes: sets.NewString("api"), PathPrefix: pathPrefix}
+		r, err := rf.NewRequestInfo(req)
+		if err != nil {
+			m := "Could not convert request"
+			log.Error(err, m)
+			http.Error(w, m, http.StatusBadRequest)
+			return
+		}
+		if !r.IsResourceRequest {
+			h.ServeHTTP(w, req)
+			return
+		}
+		if connectSubresources.Has(r.Subresource) {
+			log.Info("Denied request of Ansible run in check mode", "owner",
+				fmt.Sprintf("%s %s/%s", owner.Kind, owner.Namespace, owner.Name), "uri", req.RequestURI)
+			writeStatus(w, http.StatusForbidden, metav1.StatusReasonForbidden,
+				fmt.Sprintf("%s of %s/%s is not allowed in check mode", r.Verb, r.Resource, r.Subresource))
+			return
+		}
+		if mutatingVerbs.Has(r.Verb) {
+			query := req.URL.Query()
+			query.Set("dryRun", metav1.DryRunAll)
+			req.URL.RawQuery = query.Encode()
+			req.RequestURI = req.URL.RequestURI()
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	kmetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+)
+
+func TestCheckModeHandler(t *testing.T) {
+	var passed *http.Request
+	h := checkModeHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { passed = req }), "")
+
+	ownerRef := kmetav1.OwnerReference{APIVersion: "cache.example.com/v1alpha1", Kind: "Memcached", Name: "example"}
+	owner := &kubeconfig.NamespacedOwnerReference{OwnerReference: ownerRef, Namespace: "default"}
+	checkOwner := &kubeconfig.NamespacedOwnerReference{OwnerReference: ownerRef, Namespace: "default", CheckMode: true}
+
+	testCases := []struct {
+		name          string
+		owner         *kubeconfig.NamespacedOwnerReference
+		method        string
+		path          string
+		expectedQuery string
+		denied        bool
+	}{
+		{
+			name:   "owner not in check mode",
+			owner:  owner,
+			method: http.MethodDelete,
+			path:   "/api/v1/namespaces/default/configmaps/test",
+		},
+		{
+			name:   "get",
+			owner:  checkOwner,
+			method: http.MethodGet,
+			path:   "/api/v1/namespaces/default/configmaps/test",
+		},
+		{
+			name:          "create",
+			owner:         checkOwner,
+			method:        http.MethodPost,
+			path:          "/api/v1/namespaces/default/configmaps?fieldManager=ansible",
+			expectedQuery: "dryRun=All&fieldManager=ansible",
+		},
+		{
+			name:          "patch of subresource",
+			owner:         checkOwner,
+			method:        http.MethodPatch,
+			path:          "/apis/apps/v1/namespaces/default/deployments/test/status",
+			expectedQuery: "dryRun=All",
+		},
+		{
+			name:          "delete collection",
+			owner:         checkOwner,
+			method:        http.MethodDelete,
+			path:          "/api/v1/namespaces/default/pods",
+			expectedQuery: "dryRun=All",
+		},
+		{
+			name:   "exec",
+			owner:  checkOwner,
+			method: http.MethodPost,
+			path:   "/api/v1/namespaces/default/pods/test/exec?command=ls",
+			denied: true,
+		},
+		{
+			name:   "discovery",
+			owner:  checkOwner,
+			method: http.MethodGet,
+			path:   "/apis/apps/v1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			passed = nil
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.owner != nil {
+				req = req.WithContext(context.WithValue(req.Context(), ownerKey{}, tc.owner))
+			}
+			query := req.URL.RawQuery
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if tc.denied {
+				if passed != nil || rec.Code != http.StatusForbidden {
+					t.Fatalf("Expected the request to be denied, got status %d", rec.Code)
+				}
+				return
+			}
+			if passed == nil {
+				t.Fatalf("Expected the request to be passed, got status %d", rec.Code)
+			}
+			if tc.expectedQuery == "" {
+				tc.expectedQuery = query
+			}
+			if passed.URL.RawQuery != tc.expectedQuery {
+				t.Fatalf("Unexpected query %q, expected %q", passed.URL.RawQuery, tc.expectedQuery)
+			}
+		})
+	}
+}
@@ -19,6 +19,9 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
 // ControllerMap - map of GVK to ControllerMapContents
@@ -27,20 +30,24 @@ type ControllerMap struct {
 	internal map[schema.GroupVersionKind]*Contents
 }
 
-// WatchMap - map of GVK to interface. Determines if resource is being watched already
-type WatchMap struct {
-	mutex    sync.RWMutex
-	internal map[schema.GroupVersionKind]interface{}
-}
-
 // Contents - Contains internal data associated with each controller
 type Contents struct {
 	Controller                  controller.Controller
 	WatchDependentResources     bool
 	WatchClusterScopedResources bool
-	OwnerWatchMap               *WatchMap
-	AnnotationWatchMap          *WatchMap
 	Blacklist                   map[schema.GroupVersionKind]bool
+	// Dependents reference counts the dependent kinds read and watched for the CRs of
+	// the controller.
+	Dependents *Dependents
+	// Impersonation, if set, makes the proxy impersonate a ServiceAccount for the
+	// requests of the controller's Ansible runs.
+	Impersonation *watches.Impersonation
+	// APIAllowlist, if not empty, are the only API requests the proxy lets the
+	// controller's Ansible runs make.
+	APIAllowlist []watches.APIRule
+	// DependentTriggers, if set, records the custom resources enqueued for the events of
+	// the dependent resources of the controller.
+	DependentTriggers *handler.DependentTriggers
 }
 
 // NewControllerMap returns a new object that contains a mapping between GVK
@@ -51,14 +58,6 @@ func NewControllerMap() *ControllerMap {
 	}
 }
 
-// NewWatchMap - returns a new object that maps GVK to interface to determine
-// if resource is being watched
-func NewWatchMap() *WatchMap {
-	return &WatchMap{
-		internal: make(map[schema.GroupVersionKind]interface{}),
-	}
-}
-
 // Get - Returns a ControllerMapContents given a GVK as the key. `ok`
 // determines if the key exists
 func (cm *ControllerMap) Get(key schema.GroupVersionKind) (value *Contents, ok bool) {
@@ -87,25 +86,3 @@ func (cm *ControllerMap) Store(key schema.GroupVersionKind, value *Contents, bla
 		cm.internal[key].Blacklist[blacklistGVK] = true
 	}
 }
-
-// Get - Checks if GVK is already watched
-func (wm *WatchMap) Get(key schema.GroupVersionKind) (value interface{}, ok bool) {
-	wm.mutex.RLock()
-	defer wm.mutex.RUnlock()
-	value, ok = wm.internal[key]
-	return value, ok
-}
-
-// Delete - Deletes associated watches for a specific GVK
-func (wm *WatchMap) Delete(key schema.GroupVersionKind) {
-	wm.mutex.Lock()
-	defer wm.mutex.Unlock()
-	delete(wm.internal, key)
-}
-
-// Store - Adds a new GVK to be watched
-func (wm *WatchMap) Store(key schema.GroupVersionKind) {
-	wm.mutex.Lock()
-	defer wm.mutex.Unlock()
-	wm.internal[key] = nil
-}
@@ -0,0 +1,364 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllermap
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+)
+
+var log = logf.Log.WithName("controllermap")
+
+// NewCacheFunc - returns a new, not yet started, informer cache.
+type NewCacheFunc func() (cache.Cache, error)
+
+// WatchType - how the events of a dependent resource are mapped to its owner.
+type WatchType int
+
+const (
+	// OwnerWatch enqueues the owner in the owner references of the dependent resource.
+	OwnerWatch WatchType = iota
+	// AnnotationWatch enqueues the owner in the owner annotations of the dependent resource.
+	AnnotationWatch
+)
+
+// DependentCaches - the informer caches of the dependent kinds of all controllers. Every
+// kind is read and watched through one informer cache, shared by the controllers whose CRs
+// depend on it, which is stopped once no controller depends on the kind anymore. Event
+// handlers cannot be removed from informers, so stopping the cache is the only way to stop
+// watching a kind.
+type DependentCaches struct {
+	mutex    sync.Mutex
+	newCache NewCacheFunc
+	caches   map[schema.GroupVersionKind]*dependentCache
+}
+
+// dependentCache - the informer cache of a dependent kind.
+type dependentCache struct {
+	cache cache.Cache
+	// ctx is the context the cache runs with, which is cancelled to stop it.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// refs is the number of Dependents, and of watches being added, that use the cache.
+	refs int
+	// watches records the event handlers each Dependents added to the informer.
+	watches map[*Dependents]map[WatchType]bool
+}
+
+// NewDependentCaches - returns the DependentCaches that create the informer caches of
+// dependent kinds with newCache.
+func NewDependentCaches(newCache NewCacheFunc) *DependentCaches {
+	return &DependentCaches{
+		newCache: newCache,
+		caches:   map[schema.GroupVersionKind]*dependentCache{},
+	}
+}
+
+// acquire returns the cache of gvk, started if needed, and adds a reference to it.
+func (dc *DependentCaches) acquire(gvk schema.GroupVersionKind) (*dependentCache, error) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	c, ok := dc.caches[gvk]
+	if !ok {
+		informerCache, err := dc.newCache()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		c = &dependentCache{
+			cache:   informerCache,
+			ctx:     ctx,
+			cancel:  cancel,
+			done:    make(chan struct{}),
+			watches: map[*Dependents]map[WatchType]bool{},
+		}
+		go func() {
+			defer close(c.done)
+			if err := informerCache.Start(ctx); err != nil {
+				log.Error(err, "Failed to start the informer cache of dependent resources",
+					"dependent", gvk.String())
+			}
+		}()
+		dc.caches[gvk] = c
+		log.Info("Started the informer cache of dependent resources", "dependent", gvk.String())
+	}
+	c.refs++
+	return c, nil
+}
+
+// release removes a reference to the cache of gvk, and stops it if it was the last one.
+func (dc *DependentCaches) release(gvk schema.GroupVersionKind) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	c, ok := dc.caches[gvk]
+	if !ok {
+		return
+	}
+	if c.refs--; c.refs > 0 {
+		return
+	}
+	c.cancel()
+	delete(dc.caches, gvk)
+	log.Info("Stopped the informer cache of dependent resources no longer used", "dependent", gvk.String())
+}
+
+// startWatch records that d adds the event handler of t to the informer of c, and returns
+// false if d already did.
+func (dc *DependentCaches) startWatch(c *dependentCache, d *Dependents, t WatchType) bool {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	if c.watches[d][t] {
+		return false
+	}
+	if c.watches[d] == nil {
+		c.watches[d] = map[WatchType]bool{}
+	}
+	c.watches[d][t] = true
+	return true
+}
+
+// abortWatch records that d failed to add the event handler of t to the informer of c.
+func (dc *DependentCaches) abortWatch(c *dependentCache, d *Dependents, t WatchType) {
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+	delete(c.watches[d], t)
+}
+
+// Dependents - reference counts the kinds of the dependent resources of the CRs of a
+// controller. The controller holds a reference to the shared informer cache of every kind
+// a CR depends on, which it gives back once the last CR that depends on the kind is
+// deleted or a successful run of it no longer uses the kind. The event handlers the
+// controller added to the informer of a kind stay until the cache is stopped.
+type Dependents struct {
+	mutex  sync.Mutex
+	gvk    schema.GroupVersionKind
+	caches *DependentCaches
+	// crs maps each CR to the kinds its runs depend on.
+	crs map[types.NamespacedName]map[schema.GroupVersionKind]bool
+	// runs maps each CR with a run in progress to the kinds that run used so far.
+	runs  map[types.NamespacedName]map[schema.GroupVersionKind]bool
+	kinds map[schema.GroupVersionKind]*dependentCache
+}
+
+// NewDependents - returns the Dependents of the controller of gvk, which reads and watches
+// the dependent kinds through the informer caches of caches.
+func NewDependents(gvk schema.GroupVersionKind, caches *DependentCaches) *Dependents {
+	return &Dependents{
+		gvk:    gvk,
+		caches: caches,
+		crs:    map[types.NamespacedName]map[schema.GroupVersionKind]bool{},
+		runs:   map[types.NamespacedName]map[schema.GroupVersionKind]bool{},
+		kinds:  map[schema.GroupVersionKind]*dependentCache{},
+	}
+}
+
+// Reference - records that the CR nn depends on gvk, and returns the informer cache of
+// gvk. done is closed once the cache is stopped.
+func (d *Dependents) Reference(nn types.NamespacedName, gvk schema.GroupVersionKind) (c cache.Cache,
+	done <-chan struct{}, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	kind, err := d.reference(nn, gvk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kind.cache, kind.done, nil
+}
+
+// Watch - records that the CR nn depends on gvk and, unless the controller already
+// watches gvk with the watch type t, calls watch with the informer of gvk to add the event
+// handler of t to it.
+func (d *Dependents) Watch(nn types.NamespacedName, gvk schema.GroupVersionKind, t WatchType,
+	watch func(cache.Informer) error) error {
+	d.mutex.Lock()
+	kind, err := d.reference(nn, gvk)
+	if err != nil {
+		d.mutex.Unlock()
+		return err
+	}
+	// The cache is kept running until the informer is registered, even if the CR stops
+	// depending on gvk in the meantime.
+	if _, err := d.caches.acquire(gvk); err != nil {
+		d.mutex.Unlock()
+		return err
+	}
+	defer d.caches.release(gvk)
+	d.mutex.Unlock()
+
+	if !d.caches.startWatch(kind, d, t) {
+		return nil
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	informer, err := kind.cache.GetInformer(kind.ctx, u)
+	if err == nil {
+		err = watch(informer)
+	}
+	if err != nil {
+		d.caches.abortWatch(kind, d, t)
+	}
+	return err
+}
+
+// reference records that nn depends on gvk and returns the cache of gvk, to which a
+// reference is added the first time a CR of the controller depends on gvk. The mutex must
+// be held.
+func (d *Dependents) reference(nn types.NamespacedName, gvk schema.GroupVersionKind) (*dependentCache, error) {
+	kind, ok := d.kinds[gvk]
+	if !ok {
+		var err error
+		kind, err = d.caches.acquire(gvk)
+		if err != nil {
+			return nil, err
+		}
+		d.kinds[gvk] = kind
+	}
+
+	kinds := d.runs[nn]
+	if kinds == nil {
+		kinds = d.crs[nn]
+	}
+	if kinds == nil {
+		kinds = map[schema.GroupVersionKind]bool{}
+		d.crs[nn] = kinds
+	}
+	if !kinds[gvk] {
+		kinds[gvk] = true
+		d.updateMetrics(gvk)
+	}
+	return kind, nil
+}
+
+// RunStarted - records that a run of the CR nn started. The kinds the CR depends on are
+// replaced by the kinds the run uses if it succeeds.
+func (d *Dependents) RunStarted(nn types.NamespacedName) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.runs[nn] = map[schema.GroupVersionKind]bool{}
+}
+
+// RunFinished - records that the run of the CR nn finished, and gives back the caches of
+// the kinds no CR depends on anymore. The CR depends on the kinds a successful run used, and
+// additionally on the kinds it depended on before otherwise, since a failed run may have
+// stopped before it used all of them.
+func (d *Dependents) RunFinished(nn types.NamespacedName, successful bool) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	used, ok := d.runs[nn]
+	if !ok {
+		return
+	}
+	delete(d.runs, nn)
+	kinds := d.crs[nn]
+	if successful || kinds == nil {
+		kinds = map[schema.GroupVersionKind]bool{}
+	}
+	for gvk := range used {
+		kinds[gvk] = true
+	}
+	if len(kinds) == 0 {
+		delete(d.crs, nn)
+	} else {
+		d.crs[nn] = kinds
+	}
+	d.collect()
+}
+
+// Forget - records that the CR nn was deleted, and gives back the caches of the kinds no
+// CR depends on anymore.
+func (d *Dependents) Forget(nn types.NamespacedName) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.crs, nn)
+	delete(d.runs, nn)
+	d.collect()
+}
+
+// Stop - gives back the caches of all dependent kinds, e.g. once the controller is stopped.
+func (d *Dependents) Stop() {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.crs = map[types.NamespacedName]map[schema.GroupVersionKind]bool{}
+	d.runs = map[types.NamespacedName]map[schema.GroupVersionKind]bool{}
+	d.collect()
+}
+
+// Kinds - returns the number of CRs that depend on each dependent kind whose informer
+// cache the controller holds.
+func (d *Dependents) Kinds() map[schema.GroupVersionKind]int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	counts := make(map[schema.GroupVersionKind]int, len(d.kinds))
+	for gvk := range d.kinds {
+		counts[gvk] = d.count(gvk)
+	}
+	return counts
+}
+
+// collect gives back the references to the caches of the kinds no CR depends on. The
+// mutex must be held.
+func (d *Dependents) collect() {
+	for gvk := range d.kinds {
+		if d.count(gvk) > 0 {
+			d.updateMetrics(gvk)
+			continue
+		}
+		delete(d.kinds, gvk)
+		d.caches.release(gvk)
+		metrics.DependentWatchStopped(d.gvk.String(), gvk.String())
+	}
+}
+
+// count returns the number of CRs that depend on gvk. The mutex must be held.
+func (d *Dependents) count(gvk schema.GroupVersionKind) int {
+	n := 0
+	for nn, kinds := range d.crs {
+		if kinds[gvk] || d.runs[nn][gvk] {
+			n++
+		}
+	}
+	for nn, kinds := range d.runs {
+		if _, ok := d.crs[nn]; !ok && kinds[gvk] {
+			n++
+		}
+	}
+	return n
+}
+
+// updateMetrics records the number of CRs that depend on gvk. The mutex must be held.
+func (d *Dependents) updateMetrics(gvk schema.GroupVersionKind) {
+	metrics.DependentWatches(d.gvk.String(), gvk.String(), d.count(gvk))
+}
@@ -0,0 +1,248 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllermap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+// runningCache is a fake informer cache that runs until it is stopped.
+type runningCache struct {
+	*informertest.FakeInformers
+}
+
+func (c runningCache) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func TestDependents(t *testing.T) {
+	ownerGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	secretGVK := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	a := types.NamespacedName{Namespace: "default", Name: "a"}
+	b := types.NamespacedName{Namespace: "default", Name: "b"}
+
+	created := 0
+	d := NewDependents(ownerGVK, NewDependentCaches(func() (cache.Cache, error) {
+		created++
+		return runningCache{&informertest.FakeInformers{}}, nil
+	}))
+	reference := func(nn types.NamespacedName, gvk schema.GroupVersionKind) <-chan struct{} {
+		_, done, err := d.Reference(nn, gvk)
+		if err != nil {
+			t.Fatalf("Unexpected error referencing %s: %v", gvk, err)
+		}
+		return done
+	}
+	expectKinds := func(expected map[schema.GroupVersionKind]int) {
+		t.Helper()
+		if kinds := d.Kinds(); !reflect.DeepEqual(kinds, expected) {
+			t.Fatalf("Unexpected dependent kinds %v, expected %v", kinds, expected)
+		}
+	}
+	expectStopped := func(done <-chan struct{}) {
+		t.Helper()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the cache to be stopped")
+		}
+	}
+
+	d.RunStarted(a)
+	configMaps := reference(a, configMapGVK)
+	secrets := reference(a, secretGVK)
+	d.RunFinished(a, true)
+	d.RunStarted(b)
+	reference(b, configMapGVK)
+	d.RunFinished(b, true)
+	expectKinds(map[schema.GroupVersionKind]int{configMapGVK: 2, secretGVK: 1})
+	if created != 2 {
+		t.Fatalf("Expected a cache per dependent kind, got %d caches", created)
+	}
+
+	// A failed run keeps the kinds the CR depended on.
+	d.RunStarted(a)
+	reference(a, configMapGVK)
+	d.RunFinished(a, false)
+	expectKinds(map[schema.GroupVersionKind]int{configMapGVK: 2, secretGVK: 1})
+
+	// A successful run replaces them.
+	d.RunStarted(a)
+	reference(a, configMapGVK)
+	d.RunFinished(a, true)
+	expectKinds(map[schema.GroupVersionKind]int{configMapGVK: 2})
+	expectStopped(secrets)
+
+	// A kind stays watched while another CR depends on it.
+	d.Forget(a)
+	expectKinds(map[schema.GroupVersionKind]int{configMapGVK: 1})
+	select {
+	case <-configMaps:
+		t.Fatalf("Expected the cache of a kind with dependents to keep running")
+	default:
+	}
+	d.Forget(b)
+	expectKinds(map[schema.GroupVersionKind]int{})
+	expectStopped(configMaps)
+
+	// The cache of a kind is started again once a CR depends on it again.
+	d.RunStarted(a)
+	reference(a, configMapGVK)
+	expectKinds(map[schema.GroupVersionKind]int{configMapGVK: 1})
+	if created != 3 {
+		t.Fatalf("Expected a new cache for a kind used again, got %d caches", created)
+	}
+	d.Stop()
+	expectKinds(map[schema.GroupVersionKind]int{})
+}
+
+func TestDependentsWatch(t *testing.T) {
+	ownerGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	d := NewDependents(ownerGVK, NewDependentCaches(func() (cache.Cache, error) {
+		return runningCache{&informertest.FakeInformers{}}, nil
+	}))
+	defer d.Stop()
+
+	watched := map[WatchType]int{}
+	for _, nn := range []types.NamespacedName{{Namespace: "default", Name: "a"}, {Namespace: "default", Name: "b"}} {
+		for _, wt := range []WatchType{OwnerWatch, AnnotationWatch} {
+			err := d.Watch(nn, configMapGVK, wt, func(cache.Informer) error {
+				watched[wt]++
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	}
+	if expected := map[WatchType]int{OwnerWatch: 1, AnnotationWatch: 1}; !reflect.DeepEqual(watched, expected) {
+		t.Fatalf("Unexpected watches %v, expected %v", watched, expected)
+	}
+	if kinds := d.Kinds(); kinds[configMapGVK] != 2 {
+		t.Fatalf("Expected both CRs to depend on the watched kind, got %v", kinds)
+	}
+}
+
+func TestDependentCachesShared(t *testing.T) {
+	memcachedGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	redisGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Redis"}
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	nn := types.NamespacedName{Namespace: "default", Name: "a"}
+
+	created := 0
+	caches := NewDependentCaches(func() (cache.Cache, error) {
+		created++
+		return runningCache{&informertest.FakeInformers{}}, nil
+	})
+	memcached := NewDependents(memcachedGVK, caches)
+	redis := NewDependents(redisGVK, caches)
+
+	watched := 0
+	watch := func(d *Dependents) {
+		t.Helper()
+		err := d.Watch(nn, configMapGVK, OwnerWatch, func(cache.Informer) error {
+			watched++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	watch(memcached)
+	watch(redis)
+	if created != 1 {
+		t.Fatalf("Expected one cache per dependent kind for all controllers, got %d caches", created)
+	}
+	if watched != 2 {
+		t.Fatalf("Expected every controller to watch the kind, got %d watches", watched)
+	}
+
+	_, done, err := redis.Reference(nn, configMapGVK)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	memcached.Forget(nn)
+	select {
+	case <-done:
+		t.Fatalf("Expected the cache of a kind another controller depends on to keep running")
+	default:
+	}
+
+	// The event handler of a controller stays on the informer while the cache runs.
+	watch(memcached)
+	if watched != 2 {
+		t.Fatalf("Expected the kind not to be watched again, got %d watches", watched)
+	}
+
+	memcached.Stop()
+	redis.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the cache to be stopped")
+	}
+}
+
+func TestDependentsWatchKeepsCache(t *testing.T) {
+	ownerGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	nn := types.NamespacedName{Namespace: "default", Name: "a"}
+	d := NewDependents(ownerGVK, NewDependentCaches(func() (cache.Cache, error) {
+		return runningCache{&informertest.FakeInformers{}}, nil
+	}))
+
+	_, done, err := d.Reference(nn, configMapGVK)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The CR stops depending on the kind while its informer is registered.
+	err = d.Watch(nn, configMapGVK, OwnerWatch, func(cache.Informer) error {
+		d.Forget(nn)
+		select {
+		case <-done:
+			t.Errorf("Expected the cache to keep running until the informer is registered")
+		default:
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the cache to be stopped once the informer is registered")
+	}
+}
+
+func TestDependentsNil(t *testing.T) {
+	var d *Dependents
+	nn := types.NamespacedName{Namespace: "default", Name: "a"}
+	d.RunStarted(nn)
+	d.RunFinished(nn, true)
+	d.Forget(nn)
+	d.Stop()
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// impersonationHandler - makes the requests of Ansible runs for the CRs of watches with
+// impersonation act as a ServiceAccount in the namespace of the CR, by replacing their
+// impersonation headers.
+type impersonationHandler struct {
+	next   http.Handler
+	cMap   *controllermap.ControllerMap
+	reader client.Reader
+}
+
+func (i *impersonationHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	owner, err := getRequestWatchOwnerRef(req)
+	if err != nil || owner == nil {
+		i.next.ServeHTTP(w, req)
+		return
+	}
+	imp, ownerGVK := impersonationFor(owner, i.cMap)
+	if imp == nil {
+		i.next.ServeHTTP(w, req)
+		return
+	}
+
+	name, err := serviceAccountName(req.Context(), i.reader, owner, ownerGVK, imp)
+	if err != nil {
+		m := fmt.Sprintf("Unable to determine the ServiceAccount to impersonate for %s %s/%s: %v",
+			owner.Kind, owner.Namespace, owner.Name, err)
+		log.Error(err, "Unable to determine the ServiceAccount to impersonate", "owner", owner)
+		writeStatus(w, http.StatusForbidden, metav1.StatusReasonForbidden, m)
+		return
+	}
+	user := serviceAccountUsername(owner.Namespace, name)
+
+	for header := range req.Header {
+		if strings.HasPrefix(header, "Impersonate-") {
+			req.Header.Del(header)
+		}
+	}
+	req.Header.Set(authenticationv1.ImpersonateUserHeader, user)
+	log.V(1).Info("Impersonating ServiceAccount", "user", user, "method", req.Method, "uri", req.RequestURI)
+	i.next.ServeHTTP(w, req)
+}
+
+// impersonationFor returns the impersonation of the watch of owner, if any, and the GVK
+// of owner.
+func impersonationFor(owner *kubeconfig.NamespacedOwnerReference,
+	cMap *controllermap.ControllerMap) (*watches.Impersonation, schema.GroupVersionKind) {
+	ownerGVK := schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind)
+	contents, ok := cMap.Get(ownerGVK)
+	if !ok {
+		return nil, ownerGVK
+	}
+	return contents.Impersonation, ownerGVK
+}
+
+// serviceAccountName returns the name of the ServiceAccount to impersonate for owner: the
+// value of the ServiceAccountField of its CR, or the ServiceAccount of imp if the field is
+// unset. Webhook runs impersonate the ServiceAccount their token was issued for, as the
+// object of their admission request may not exist yet.
+func serviceAccountName(ctx context.Context, reader client.Reader, owner *kubeconfig.NamespacedOwnerReference,
+	ownerGVK schema.GroupVersionKind, imp *watches.Impersonation) (string, error) {
+	if owner.Webhook {
+		if errs := validation.IsDNS1123Subdomain(owner.ServiceAccount); len(errs) > 0 {
+			return "", fmt.Errorf("invalid ServiceAccount name %q: %s", owner.ServiceAccount, strings.Join(errs, ", "))
+		}
+		return owner.ServiceAccount, nil
+	}
+	u := &unstructured.Unstructured{}
+	if imp.ServiceAccountField != "" {
+		u.SetGroupVersionKind(ownerGVK)
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: owner.Namespace, Name: owner.Name}, u); err != nil {
+			return "", err
+		}
+	}
+	return imp.ServiceAccountOf(u)
+}
+
+// serviceAccountUsername returns the username of the ServiceAccount name in namespace.
+func serviceAccountUsername(namespace, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// writeStatus - writes a Status response like the API server does, so that clients
+// report its message.
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	status := metav1.Status{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+		Status:   metav1.StatusFailure,
+		Message:  message,
+		Reason:   reason,
+		Code:     int32(code),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Error(err, "Failed to write status response")
+	}
+}
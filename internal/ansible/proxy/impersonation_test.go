@@ -0,0 +1,159 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	kmetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+func TestImpersonationHandler(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	newCR := func(name string, spec map[string]interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		u.SetGroupVersionKind(gvk)
+		u.SetNamespace("tenant")
+		u.SetName(name)
+		return u
+	}
+	reader := fake.NewClientBuilder().WithRuntimeObjects(
+		newCR("named", map[string]interface{}{"serviceAccountName": "deployer"}),
+		newCR("unnamed", map[string]interface{}{}),
+		newCR("invalid", map[string]interface{}{"serviceAccountName": "Not_Valid"}),
+	).Build()
+
+	cMap := controllermap.NewControllerMap()
+	cMap.Store(gvk, &controllermap.Contents{Impersonation: &watches.Impersonation{
+		ServiceAccountField: "spec.serviceAccountName",
+		ServiceAccount:      "default",
+	}}, nil)
+
+	var gotHeader http.Header
+	h := &impersonationHandler{
+		next:   http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { gotHeader = req.Header }),
+		cMap:   cMap,
+		reader: reader,
+	}
+
+	testCases := []struct {
+		name           string
+		owner          *kubeconfig.NamespacedOwnerReference
+		expectedUser   string
+		expectedStatus int
+	}{
+		{
+			name:           "no owner",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "owner without impersonation",
+			owner: &kubeconfig.NamespacedOwnerReference{
+				OwnerReference: kmetav1.OwnerReference{APIVersion: "other.example.com/v1", Kind: "Other", Name: "x"},
+				Namespace:      "tenant",
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "service account named in CR",
+			owner:          ownerOf(gvk, "named"),
+			expectedUser:   "system:serviceaccount:tenant:deployer",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "default service account",
+			owner:          ownerOf(gvk, "unnamed"),
+			expectedUser:   "system:serviceaccount:tenant:default",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid service account",
+			owner:          ownerOf(gvk, "invalid"),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "missing CR",
+			owner:          ownerOf(gvk, "missing"),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			// The object of a webhook run may not exist yet.
+			name:           "webhook run",
+			owner:          webhookOwnerOf(gvk, "missing", "deployer"),
+			expectedUser:   "system:serviceaccount:tenant:deployer",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "webhook run without service account",
+			owner:          webhookOwnerOf(gvk, "named", ""),
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotHeader = nil
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/tenant/pods", nil)
+			req.Header.Set(authenticationv1.ImpersonateGroupHeader, "system:masters")
+			if tc.owner != nil {
+				req = req.WithContext(context.WithValue(req.Context(), ownerKey{}, tc.owner))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Unexpected status %d, expected %d", rec.Code, tc.expectedStatus)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				status := kmetav1.Status{}
+				if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || status.Message == "" {
+					t.Fatalf("Expected a Status with a message, got %q", rec.Body.String())
+				}
+				return
+			}
+			if user := gotHeader.Get(authenticationv1.ImpersonateUserHeader); user != tc.expectedUser {
+				t.Fatalf("Unexpected impersonated user %q, expected %q", user, tc.expectedUser)
+			}
+			groups := gotHeader.Get(authenticationv1.ImpersonateGroupHeader)
+			if tc.expectedUser != "" && groups != "" {
+				t.Fatalf("Expected impersonation headers of the request to be removed, got group %q", groups)
+			}
+		})
+	}
+}
+
+func ownerOf(gvk schema.GroupVersionKind, name string) *kubeconfig.NamespacedOwnerReference {
+	return &kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind, Name: name},
+		Namespace:      "tenant",
+	}
+}
+
+func webhookOwnerOf(gvk schema.GroupVersionKind, name, serviceAccount string) *kubeconfig.NamespacedOwnerReference {
+	owner := ownerOf(gvk, name)
+	owner.Webhook = true
+	owner.ServiceAccount = serviceAccount
+	return owner
+}
@@ -19,17 +19,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/http/httputil"
 
 	"github.com/operator-framework/operator-lib/handler"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
 
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
 	k8sRequest "github.com/operator-framework/operator-sdk/internal/ansible/proxy/requestfactory"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 )
@@ -37,152 +43,285 @@ import (
 // injectOwnerReferenceHandler will handle proxied requests and inject the
 // owner reference found in the authorization header. The Authorization is
 // then deleted so that the proxy can re-set with the correct authorization.
+//
+// Objects created with POST, objects created or updated with server-side apply
+// PATCH requests, and objects patched with strategic merge and JSON merge PATCH
+// requests that do not exist yet or are already owned by the owner get the owner
+// reference, or the owner annotations if they cannot be owned by the owner.
+// Merge patches of existing objects the owner does not own, e.g. objects shared
+// with other operators, are left alone so that they are not garbage collected
+// with the owner.
 type injectOwnerReferenceHandler struct {
 	next              http.Handler
 	cMap              *controllermap.ControllerMap
 	restMapper        meta.RESTMapper
 	watchedNamespaces map[string]interface{}
 	apiResources      *apiResources
+	pathPrefix        string
+	// apiReader reads the current objects of merge patches, to tell whether the owner
+	// owns them, and to keep their owner references in JSON merge patches, which
+	// replace the whole list.
+	apiReader client.Reader
 }
 
 func (i *injectOwnerReferenceHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
-	case http.MethodPost:
-		dump, _ := httputil.DumpRequest(req, false)
-		log.V(2).Info("Dumping request", "RequestDump", string(dump))
-		rf := k8sRequest.RequestInfoFactory{APIPrefixes: sets.NewString("api", "apis"),
-			GrouplessAPIPrefixes: sets.NewString("api")}
-		r, err := rf.NewRequestInfo(req)
-		if err != nil {
-			m := "Could not convert request"
-			log.Error(err, m)
-			http.Error(w, m, http.StatusBadRequest)
+	case http.MethodPost, http.MethodPatch:
+		if !i.inject(w, req) {
 			return
 		}
-		if r.Subresource != "" {
-			// Don't inject owner ref if we are POSTing to a subresource
-			break
-		}
+	}
+	i.next.ServeHTTP(w, req)
+}
 
-		if i.restMapper == nil {
-			i.restMapper = meta.NewDefaultRESTMapper([]schema.GroupVersion{schema.GroupVersion{
-				Group:   r.APIGroup,
-				Version: r.APIVersion,
-			}})
-		}
+// inject injects the owner into the object of req. It returns false if it failed and has
+// written an error response.
+func (i *injectOwnerReferenceHandler) inject(w http.ResponseWriter, req *http.Request) bool { //nolint:gocyclo
+	dump, _ := httputil.DumpRequest(req, false)
+	log.V(2).Info("Dumping request", "RequestDump", string(dump))
+	rf := k8sRequest.RequestInfoFactory{APIPrefixes: sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"), PathPrefix: i.pathPrefix}
+	r, err := rf.NewRequestInfo(req)
+	if err != nil {
+		m := "Could not convert request"
+		log.Error(err, m)
+		http.Error(w, m, http.StatusBadRequest)
+		return false
+	}
+	if r.Subresource != "" {
+		// Don't inject owner ref if we are POSTing to a subresource
+		return true
+	}
 
-		k, err := getGVKFromRequestInfo(r, i.restMapper)
+	var patchType types.PatchType
+	if req.Method == http.MethodPatch {
+		contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
 		if err != nil {
-			// break here in case resource doesn't exist in cache
-			log.Error(err, "Cache miss, can not find in rest mapper")
-			break
+			log.V(1).Info("Not injecting owner reference into patch with invalid content type", "error", err.Error())
+			return true
+		}
+		patchType = types.PatchType(contentType)
+		switch patchType {
+		case types.ApplyPatchType, types.StrategicMergePatchType, types.MergePatchType:
+		default:
+			log.V(1).Info("Not injecting owner reference into patch", "patchType", patchType)
+			return true
 		}
+	}
+
+	if i.restMapper == nil {
+		i.restMapper = meta.NewDefaultRESTMapper([]schema.GroupVersion{schema.GroupVersion{
+			Group:   r.APIGroup,
+			Version: r.APIVersion,
+		}})
+	}
 
-		// Determine if the resource is virtual. If it is then we should not attempt to use cache
-		isVR, err := i.apiResources.IsVirtualResource(k)
+	k, err := getGVKFromRequestInfo(r, i.restMapper)
+	if err != nil {
+		// break here in case resource doesn't exist in cache
+		log.Error(err, "Cache miss, can not find in rest mapper")
+		return true
+	}
+
+	// Determine if the resource is virtual. If it is then we should not attempt to use cache
+	isVR, err := i.apiResources.IsVirtualResource(k)
+	if err != nil {
+		// Fail if we can't determine whether it's a virtual resource or not.
+		// Otherwise we might create a resource without an ownerReference, which will prevent
+		// dependentWatches from being re-established and garbage collection from deleting the
+		// resource, unless a user manually adds the ownerReference.
+		m := "Unable to determine if virtual resource"
+		log.Error(err, m, "gvk", k)
+		http.Error(w, m, http.StatusInternalServerError)
+		return false
+	}
+
+	if isVR {
+		log.V(2).Info("Virtual resource, must ask the cluster API", "gvk", k)
+		return true
+	}
+
+	log.Info("Injecting owner reference")
+	owner, err := getRequestOwnerRef(req)
+	if err != nil {
+		m := "Could not get owner reference"
+		log.Error(err, m)
+		http.Error(w, m, http.StatusInternalServerError)
+		return false
+	}
+	if owner == nil {
+		return true
+	}
+
+	var current *unstructured.Unstructured
+	if patchType == types.StrategicMergePatchType || patchType == types.MergePatchType {
+		current, err = i.currentObject(req, k, r.Namespace, r.Name)
 		if err != nil {
-			// Fail if we can't determine whether it's a virtual resource or not.
-			// Otherwise we might create a resource without an ownerReference, which will prevent
-			// dependentWatches from being re-established and garbage collection from deleting the
-			// resource, unless a user manually adds the ownerReference.
-			m := "Unable to determine if virtual resource"
-			log.Error(err, m, "gvk", k)
+			m := "Could not get current object"
+			log.Error(err, m)
 			http.Error(w, m, http.StatusInternalServerError)
-			return
+			return false
+		}
+		if current != nil && !isOwnedBy(current, *owner) {
+			log.V(1).Info("Not injecting owner reference into patch of object not owned by the owner",
+				"gvk", k, "namespace", r.Namespace, "name", r.Name)
+			return true
+		}
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		m := "Could not read request body"
+		log.Error(err, m)
+		http.Error(w, m, http.StatusInternalServerError)
+		return false
+	}
+	// Apply patches may be YAML, which is converted to JSON, also a valid apply patch.
+	if patchType == types.ApplyPatchType {
+		if body, err = yaml.YAMLToJSON(body); err != nil {
+			m := "Could not convert apply patch to JSON"
+			log.Error(err, m)
+			http.Error(w, m, http.StatusBadRequest)
+			return false
 		}
+	}
+	data := &unstructured.Unstructured{}
+	if req.Method == http.MethodPatch {
+		// Patches are not necessarily objects with an apiVersion and kind.
+		err = json.Unmarshal(body, &data.Object)
+	} else {
+		err = json.Unmarshal(body, data)
+	}
+	if err != nil {
+		m := "Could not deserialize request body"
+		log.Error(err, m)
+		http.Error(w, m, http.StatusBadRequest)
+		return false
+	}
+
+	// The object the request creates or updates. Patches do not necessarily carry the
+	// type, namespace and name of the object, so they are taken from the request.
+	target := data
+	if req.Method == http.MethodPatch {
+		target = &unstructured.Unstructured{}
+		target.SetGroupVersionKind(k)
+		target.SetNamespace(r.Namespace)
+		target.SetName(r.Name)
+	}
 
-		if isVR {
-			log.V(2).Info("Virtual resource, must ask the cluster API", "gvk", k)
-			break
+	ownerGV, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		m := fmt.Sprintf("could not get group version for: %v", owner)
+		log.Error(err, m)
+		http.Error(w, m, http.StatusBadRequest)
+		return false
+	}
+	ownerGVK := schema.GroupVersionKind{
+		Group:   ownerGV.Group,
+		Version: ownerGV.Version,
+		Kind:    owner.Kind,
+	}
+	ownerObject := &unstructured.Unstructured{}
+	ownerObject.SetGroupVersionKind(ownerGVK)
+	ownerObject.SetNamespace(owner.Namespace)
+	ownerObject.SetName(owner.Name)
+	addOwnerRef, err := k8sutil.SupportsOwnerReference(i.restMapper, ownerObject, target)
+	if err != nil {
+		m := "Could not determine if we should add owner ref"
+		log.Error(err, m)
+		http.Error(w, m, http.StatusBadRequest)
+		return false
+	}
+	if addOwnerRef {
+		ownerRefs := data.GetOwnerReferences()
+		// A JSON merge patch replaces the owner references of the object, so they are
+		// kept unless the patch sets them.
+		if patchType == types.MergePatchType && len(ownerRefs) == 0 && current != nil {
+			ownerRefs = current.GetOwnerReferences()
+		}
+		data.SetOwnerReferences(appendOwnerReference(ownerRefs, *owner))
+	} else {
+		err := handler.SetOwnerAnnotations(ownerObject, data)
+		if err != nil {
+			m := "Could not set owner annotations"
+			log.Error(err, m)
+			http.Error(w, m, http.StatusBadRequest)
+			return false
 		}
+	}
+	newBody, err := json.Marshal(data.Object)
+	if err != nil {
+		m := "Could not serialize body"
+		log.Error(err, m)
+		http.Error(w, m, http.StatusInternalServerError)
+		return false
+	}
+	log.V(2).Info("Serialized body", "Body", string(newBody))
+	req.Body = ioutil.NopCloser(bytes.NewBuffer(newBody))
+	req.ContentLength = int64(len(newBody))
 
-		log.Info("Injecting owner reference")
-		owner, err := getRequestOwnerRef(req)
+	// add watch for resource
+	// check if resource doesn't exist in watched namespaces
+	// if watchedNamespaces[""] exists then we are watching all namespaces
+	// and want to continue
+	// This is making sure we are not attempting to watch a resource outside of the
+	// namespaces that the cache can watch.
+	_, allNsPresent := i.watchedNamespaces[metav1.NamespaceAll]
+	_, reqNsPresent := i.watchedNamespaces[r.Namespace]
+	if allNsPresent || reqNsPresent {
+		err = addWatchToController(*owner, i.cMap, target, i.restMapper, addOwnerRef)
 		if err != nil {
-			m := "Could not get owner reference"
+			m := "could not add watch to controller"
 			log.Error(err, m)
 			http.Error(w, m, http.StatusInternalServerError)
-			return
+			return false
 		}
-		if owner != nil {
-			body, err := ioutil.ReadAll(req.Body)
-			if err != nil {
-				m := "Could not read request body"
-				log.Error(err, m)
-				http.Error(w, m, http.StatusInternalServerError)
-				return
-			}
-			data := &unstructured.Unstructured{}
-			err = json.Unmarshal(body, data)
-			if err != nil {
-				m := "Could not deserialize request body"
-				log.Error(err, m)
-				http.Error(w, m, http.StatusBadRequest)
-				return
-			}
-			ownerGV, err := schema.ParseGroupVersion(owner.APIVersion)
-			if err != nil {
-				m := fmt.Sprintf("could not get group version for: %v", owner)
-				log.Error(err, m)
-				http.Error(w, m, http.StatusBadRequest)
-				return
-			}
-			ownerGVK := schema.GroupVersionKind{
-				Group:   ownerGV.Group,
-				Version: ownerGV.Version,
-				Kind:    owner.Kind,
-			}
-			ownerObject := &unstructured.Unstructured{}
-			ownerObject.SetGroupVersionKind(ownerGVK)
-			ownerObject.SetNamespace(owner.Namespace)
-			ownerObject.SetName(owner.Name)
-			addOwnerRef, err := k8sutil.SupportsOwnerReference(i.restMapper, ownerObject, data)
-			if err != nil {
-				m := "Could not determine if we should add owner ref"
-				log.Error(err, m)
-				http.Error(w, m, http.StatusBadRequest)
-				return
-			}
-			if addOwnerRef {
-				data.SetOwnerReferences(append(data.GetOwnerReferences(), owner.OwnerReference))
-			} else {
-				err := handler.SetOwnerAnnotations(ownerObject, data)
-				if err != nil {
-					m := "Could not set owner annotations"
-					log.Error(err, m)
-					http.Error(w, m, http.StatusBadRequest)
-					return
-				}
-			}
-			newBody, err := json.Marshal(data.Object)
-			if err != nil {
-				m := "Could not serialize body"
-				log.Error(err, m)
-				http.Error(w, m, http.StatusInternalServerError)
-				return
-			}
-			log.V(2).Info("Serialized body", "Body", string(newBody))
-			req.Body = ioutil.NopCloser(bytes.NewBuffer(newBody))
-			req.ContentLength = int64(len(newBody))
-
-			// add watch for resource
-			// check if resource doesn't exist in watched namespaces
-			// if watchedNamespaces[""] exists then we are watching all namespaces
-			// and want to continue
-			// This is making sure we are not attempting to watch a resource outside of the
-			// namespaces that the cache can watch.
-			_, allNsPresent := i.watchedNamespaces[metav1.NamespaceAll]
-			_, reqNsPresent := i.watchedNamespaces[r.Namespace]
-			if allNsPresent || reqNsPresent {
-				err = addWatchToController(*owner, i.cMap, data, i.restMapper, addOwnerRef)
-				if err != nil {
-					m := "could not add watch to controller"
-					log.Error(err, m)
-					http.Error(w, m, http.StatusInternalServerError)
-					return
-				}
-			}
+	}
+	return true
+}
+
+// currentObject returns the object of kind gvk named name in namespace, or nil if it does
+// not exist.
+func (i *injectOwnerReferenceHandler) currentObject(req *http.Request, gvk schema.GroupVersionKind,
+	namespace, name string) (*unstructured.Unstructured, error) {
+	if i.apiReader == nil {
+		return nil, fmt.Errorf("no reader for %s", gvk)
+	}
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(gvk)
+	err := i.apiReader.Get(req.Context(), client.ObjectKey{Namespace: namespace, Name: name}, current)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// isOwnedBy returns whether obj has the owner reference or the owner annotations of owner.
+func isOwnedBy(obj *unstructured.Unstructured, owner kubeconfig.NamespacedOwnerReference) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.UID {
+			return true
 		}
 	}
-	i.next.ServeHTTP(w, req)
+	ownerGV, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return false
+	}
+	annotations := obj.GetAnnotations()
+	return annotations[handler.NamespacedNameAnnotation] == owner.Namespace+"/"+owner.Name &&
+		annotations[handler.TypeAnnotation] == ownerGV.WithKind(owner.Kind).GroupKind().String()
+}
+
+// appendOwnerReference appends the owner reference of owner to refs unless it is there.
+func appendOwnerReference(refs []metav1.OwnerReference, owner kubeconfig.NamespacedOwnerReference) []metav1.OwnerReference {
+	for _, ref := range refs {
+		if ref.UID == owner.UID && ref.Kind == owner.Kind && ref.APIVersion == owner.APIVersion &&
+			ref.Name == owner.Name {
+			return refs
+		}
+	}
+	return append(refs, owner.OwnerReference)
 }
@@ -0,0 +1,238 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	libhandler "github.com/operator-framework/operator-lib/handler"
+	kcorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	kmetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	crHandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+)
+
+// watchRecorder is a controller that records the sources it is asked to watch.
+type watchRecorder struct {
+	mu      sync.Mutex
+	sources []source.Source
+}
+
+func (c *watchRecorder) Reconcile(context.Context, reconcile.Request) (reconcile.Result, error) {
+	return reconcile.Result{}, nil
+}
+
+func (c *watchRecorder) Watch(src source.Source, _ crHandler.EventHandler, _ ...predicate.Predicate) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sources = append(c.sources, src)
+	return nil
+}
+
+func (c *watchRecorder) Start(context.Context) error { return nil }
+
+func (c *watchRecorder) GetLogger() logr.Logger { return logf.Log }
+
+func TestInjectOwnerReferenceIntoPatch(t *testing.T) {
+	ownerGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	configMapGVK := kcorev1.SchemeGroupVersion.WithKind("ConfigMap")
+	clusterRoleGVK := schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(ownerGVK, meta.RESTScopeNamespace)
+	restMapper.Add(configMapGVK, meta.RESTScopeNamespace)
+	restMapper.Add(clusterRoleGVK, meta.RESTScopeRoot)
+
+	owner := kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{
+			APIVersion: ownerGVK.GroupVersion().String(), Kind: ownerGVK.Kind, Name: "example", UID: "owner-uid",
+		},
+		Namespace: "default",
+	}
+	otherOwner := kmetav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid"}
+	shared := &unstructured.Unstructured{}
+	shared.SetGroupVersionKind(configMapGVK)
+	shared.SetNamespace("default")
+	shared.SetName("shared")
+	shared.SetOwnerReferences([]kmetav1.OwnerReference{otherOwner})
+	owned := shared.DeepCopy()
+	owned.SetName("owned")
+	owned.SetOwnerReferences([]kmetav1.OwnerReference{otherOwner, owner.OwnerReference})
+
+	readAPIResource := kmetav1.APIResource{Verbs: kmetav1.Verbs{"get", "list", "watch"}}
+	resources := &apiResources{
+		mu: &sync.RWMutex{},
+		gvkToAPIResource: map[string]kmetav1.APIResource{
+			configMapGVK.String():   readAPIResource,
+			clusterRoleGVK.String(): readAPIResource,
+		},
+	}
+
+	testCases := []struct {
+		name                 string
+		path                 string
+		patchType            types.PatchType
+		body                 string
+		expectedOwnerRefs    []kmetav1.OwnerReference
+		expectedAnnotation   bool
+		expectedWatchedKinds int
+		// expectedUnchanged is set if the patch is forwarded as is.
+		expectedUnchanged bool
+	}{
+		{
+			name:      "server-side apply",
+			path:      "/api/v1/namespaces/default/configmaps/new",
+			patchType: types.ApplyPatchType,
+			body: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new\n  namespace: default\n" +
+				"data:\n  key: value\n",
+			expectedOwnerRefs:    []kmetav1.OwnerReference{owner.OwnerReference},
+			expectedWatchedKinds: 1,
+		},
+		{
+			name:                 "strategic merge patch of object owned by the owner",
+			path:                 "/api/v1/namespaces/default/configmaps/owned",
+			patchType:            types.StrategicMergePatchType,
+			body:                 `{"data":{"key":"value"}}`,
+			expectedOwnerRefs:    []kmetav1.OwnerReference{owner.OwnerReference},
+			expectedWatchedKinds: 1,
+		},
+		{
+			name:                 "strategic merge patch of object that does not exist",
+			path:                 "/api/v1/namespaces/default/configmaps/missing",
+			patchType:            types.StrategicMergePatchType,
+			body:                 `{"data":{"key":"value"}}`,
+			expectedOwnerRefs:    []kmetav1.OwnerReference{owner.OwnerReference},
+			expectedWatchedKinds: 1,
+		},
+		{
+			name:              "strategic merge patch of object not owned by the owner",
+			path:              "/api/v1/namespaces/default/configmaps/shared",
+			patchType:         types.StrategicMergePatchType,
+			body:              `{"data":{"key":"value"}}`,
+			expectedUnchanged: true,
+		},
+		{
+			name:                 "merge patch of object owned by the owner keeps its owner references",
+			path:                 "/api/v1/namespaces/default/configmaps/owned",
+			patchType:            types.MergePatchType,
+			body:                 `{"data":{"key":"value"}}`,
+			expectedOwnerRefs:    []kmetav1.OwnerReference{otherOwner, owner.OwnerReference},
+			expectedWatchedKinds: 1,
+		},
+		{
+			name:              "merge patch of object not owned by the owner",
+			path:              "/api/v1/namespaces/default/configmaps/shared",
+			patchType:         types.MergePatchType,
+			body:              `{"data":{"key":"value"}}`,
+			expectedUnchanged: true,
+		},
+		{
+			name:              "JSON patch",
+			path:              "/api/v1/namespaces/default/configmaps/owned",
+			patchType:         types.JSONPatchType,
+			body:              `[{"op":"add","path":"/data/key","value":"value"}]`,
+			expectedUnchanged: true,
+		},
+		{
+			name:                 "cluster-scoped resource",
+			path:                 "/apis/rbac.authorization.k8s.io/v1/clusterroles/role",
+			patchType:            types.ApplyPatchType,
+			body:                 `{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"ClusterRole","metadata":{"name":"role"}}`,
+			expectedAnnotation:   true,
+			expectedWatchedKinds: 0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := &watchRecorder{}
+			cMap := controllermap.NewControllerMap()
+			cMap.Store(ownerGVK, &controllermap.Contents{
+				Controller:              controller,
+				WatchDependentResources: true,
+				Dependents: controllermap.NewDependents(ownerGVK,
+					controllermap.NewDependentCaches(func() (cache.Cache, error) {
+						return &informertest.FakeInformers{}, nil
+					})),
+			}, nil)
+
+			var forwarded []byte
+			h := &injectOwnerReferenceHandler{
+				next: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					forwarded, _ = ioutil.ReadAll(req.Body)
+				}),
+				cMap:              cMap,
+				restMapper:        restMapper,
+				watchedNamespaces: map[string]interface{}{"default": nil},
+				apiResources:      resources,
+				apiReader:         fake.NewClientBuilder().WithRuntimeObjects(shared.DeepCopy(), owned.DeepCopy()).Build(),
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", string(tc.patchType))
+			req = req.WithContext(context.WithValue(req.Context(), ownerKey{}, &owner))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Unexpected status %d: %s", rec.Code, rec.Body.String())
+			}
+
+			if tc.expectedUnchanged {
+				if string(forwarded) != tc.body {
+					t.Fatalf("Expected patch to be forwarded unchanged, got %s", forwarded)
+				}
+				if len(controller.sources) != 0 {
+					t.Fatalf("Unexpected dependent watches %v", controller.sources)
+				}
+				return
+			}
+			got := &unstructured.Unstructured{}
+			if err := json.Unmarshal(forwarded, &got.Object); err != nil {
+				t.Fatalf("Failed to unmarshal forwarded body %s: %v", forwarded, err)
+			}
+			if refs := got.GetOwnerReferences(); len(refs) != len(tc.expectedOwnerRefs) ||
+				(len(refs) > 0 && refs[len(refs)-1].UID != owner.UID) ||
+				(len(refs) > 1 && refs[0].UID != otherOwner.UID) {
+				t.Fatalf("Unexpected owner references %v, expected %v", refs, tc.expectedOwnerRefs)
+			}
+			if _, ok := got.GetAnnotations()[libhandler.TypeAnnotation]; ok != tc.expectedAnnotation {
+				t.Fatalf("Unexpected owner annotations %v", got.GetAnnotations())
+			}
+			if len(controller.sources) != tc.expectedWatchedKinds {
+				t.Fatalf("Unexpected number of dependent watches %d, expected %d", len(controller.sources),
+					tc.expectedWatchedKinds)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeconfig
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultProxyURL is the URL the proxy listens on unless configured otherwise.
+const DefaultProxyURL = "http://localhost:8888"
+
+// Tokens holds the bearer tokens issued to Ansible runs, each mapped to the owner
+// reference of the run. The proxy rejects requests that carry no known token.
+type Tokens struct {
+	mu     sync.RWMutex
+	tokens map[string]*NamespacedOwnerReference
+}
+
+// NewTokens returns an empty set of tokens.
+func NewTokens() *Tokens {
+	return &Tokens{tokens: map[string]*NamespacedOwnerReference{}}
+}
+
+// Issue returns a new token for a run on behalf of owner, which may be nil.
+func (t *Tokens) Issue(owner *NamespacedOwnerReference) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tokens[token] = owner
+	return token, nil
+}
+
+// Revoke invalidates token.
+func (t *Tokens) Revoke(token string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tokens, token)
+}
+
+// Lookup returns the owner token was issued for, and whether token is valid.
+func (t *Tokens) Lookup(token string) (*NamespacedOwnerReference, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	owner, ok := t.tokens[token]
+	return owner, ok
+}
+
+// Issuer writes the kubeconfig files Ansible runs use to reach the proxy.
+type Issuer struct {
+	// ProxyURL is the URL of the proxy. Defaults to DefaultProxyURL.
+	ProxyURL string
+	// Tokens, if set, makes every kubeconfig carry a token of its own that is
+	// revoked when the run has finished.
+	Tokens *Tokens
+}
+
+// Create writes a kubeconfig for the run ident in namespace on behalf of ownerRef, or of
+// no owner if ownerRef is nil. The returned function revokes the access of the kubeconfig
+// and must be called once the run has finished.
+func (i *Issuer) Create(ident string, ownerRef *metav1.OwnerReference, namespace string) (*os.File, func(), error) {
+	var owner *NamespacedOwnerReference
+	if ownerRef != nil {
+		owner = &NamespacedOwnerReference{OwnerReference: *ownerRef, Namespace: namespace, Ident: ident}
+	}
+	return i.CreateForOwner(owner, namespace)
+}
+
+// CreateForOwner writes a kubeconfig in namespace on behalf of owner, or of no owner if
+// owner is nil. The returned function revokes the access of the kubeconfig and must be
+// called once the run has finished.
+func (i *Issuer) CreateForOwner(owner *NamespacedOwnerReference, namespace string) (*os.File, func(), error) {
+	proxyURL := i.ProxyURL
+	if proxyURL == "" {
+		proxyURL = DefaultProxyURL
+	}
+
+	if i.Tokens == nil {
+		var file *os.File
+		var err error
+		if owner != nil {
+			file, err = createWithOwner(*owner, proxyURL)
+		} else {
+			file, err = CreateWithoutOwner(proxyURL, namespace)
+		}
+		return file, func() {}, err
+	}
+
+	token, err := i.Tokens.Issue(owner)
+	if err != nil {
+		return nil, nil, err
+	}
+	revoke := func() { i.Tokens.Revoke(token) }
+	file, err := write(values{
+		Token:     token,
+		ProxyURL:  proxyURL,
+		Namespace: namespace,
+	})
+	if err != nil {
+		revoke()
+		return nil, nil, err
+	}
+	return file, revoke, nil
+}
@@ -51,14 +51,16 @@ current-context: {{.Namespace}}/proxy-server
 preferences: {}
 users:
 - name: admin/proxy-server
-  user:
+  user:{{if .Username}}
     username: {{.Username}}
-    password: unused
+    password: unused{{else if .Token}}
+    token: {{.Token}}{{else}} {}{{end}}
 `
 
 // values holds the data used to render the template
 type values struct {
 	Username  string
+	Token     string
 	ProxyURL  string
 	Namespace string
 }
@@ -66,11 +68,29 @@ type values struct {
 type NamespacedOwnerReference struct {
 	metav1.OwnerReference
 	Namespace string
+	// Ident is the job ident of the Ansible run the kubeconfig was created for, if any.
+	Ident string `json:"ident,omitempty"`
+	// CheckMode is set if the Ansible run is in check mode, in which case the proxy does
+	// not let its requests change any resource.
+	CheckMode bool `json:"checkMode,omitempty"`
+	// Webhook is set if the Ansible run is a webhook run for an admission request of the
+	// object, which may not exist. The proxy applies the impersonation and API allowlist of
+	// the watch of the object to its requests, but does not treat the object as their owner.
+	Webhook bool `json:"webhook,omitempty"`
+	// ServiceAccount is the ServiceAccount a webhook run impersonates if its watch has
+	// impersonation.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
 }
 
 // Create renders a kubeconfig template and writes it to disk
 func Create(ownerRef metav1.OwnerReference, proxyURL string, namespace string) (*os.File, error) {
-	nsOwnerRef := NamespacedOwnerReference{OwnerReference: ownerRef, Namespace: namespace}
+	return createWithOwner(NamespacedOwnerReference{OwnerReference: ownerRef, Namespace: namespace}, proxyURL)
+}
+
+// createWithOwner renders a kubeconfig template whose username encodes nsOwnerRef and
+// writes it to disk
+func createWithOwner(nsOwnerRef NamespacedOwnerReference, proxyURL string) (*os.File, error) {
+	namespace := nsOwnerRef.Namespace
 	parsedURL, err := url.Parse(proxyURL)
 	if err != nil {
 		return nil, err
@@ -81,12 +101,26 @@ func Create(ownerRef metav1.OwnerReference, proxyURL string, namespace string) (
 	}
 	username := base64.URLEncoding.EncodeToString(ownerRefJSON)
 	parsedURL.User = url.User(username)
-	v := values{
+	return write(values{
 		Username:  username,
 		ProxyURL:  parsedURL.String(),
 		Namespace: namespace,
+	})
+}
+
+// CreateWithoutOwner renders a kubeconfig template without an owner reference, so that the
+// proxy does not inject one into the resources created with it, and writes it to disk
+func CreateWithoutOwner(proxyURL string, namespace string) (*os.File, error) {
+	if _, err := url.Parse(proxyURL); err != nil {
+		return nil, err
 	}
+	return write(values{
+		ProxyURL:  proxyURL,
+		Namespace: namespace,
+	})
+}
 
+func write(v values) (*os.File, error) {
 	var parsed bytes.Buffer
 
 	t := template.Must(template.New("kubeconfig").Parse(kubeConfigTemplate))
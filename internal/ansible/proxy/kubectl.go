@@ -190,8 +190,10 @@ func makeUpgradeTransport(config *rest.Config) (k8sproxy.UpgradeRequestRoundTrip
 	return k8sproxy.NewUpgradeRequestRoundTripper(rt, upgrader), nil
 }
 
-// NewServer creates and installs a new Server.
-func newServer(apiProxyPrefix string, cfg *rest.Config) (*server, error) {
+// NewServer creates and installs a new Server. Requests are forwarded to the API server
+// with upstreamPathPrefix, the path prefix of the API server, prepended to their path
+// unless they already have it.
+func newServer(apiProxyPrefix, upstreamPathPrefix string, cfg *rest.Config) (*server, error) {
 	host := cfg.Host
 	if !strings.HasSuffix(host, "/") {
 		host = host + "/"
@@ -215,6 +217,9 @@ func newServer(apiProxyPrefix string, cfg *rest.Config) (*server, error) {
 	proxy.UseRequestLocation = true
 
 	proxyServer := http.Handler(proxy)
+	if upstreamPathPrefix != "" {
+		proxyServer = prependPathPrefix(upstreamPathPrefix, proxyServer)
+	}
 
 	if !strings.HasPrefix(apiProxyPrefix, "/api") {
 		proxyServer = stripLeaveSlash(apiProxyPrefix, proxyServer)
@@ -259,6 +264,17 @@ func (s *server) ServeOnListener(l net.Listener) error {
 	return server.Serve(l)
 }
 
+// prependPathPrefix prepends prefix to the path of requests that do not start with it.
+func prependPathPrefix(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != prefix && !strings.HasPrefix(req.URL.Path, prefix+"/") {
+			req.URL.Path = prefix + req.URL.Path
+			req.URL.RawPath = ""
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
 // like http.StripPrefix, but always leaves an initial slash. (so that our
 // regexps will work.)
 func stripLeaveSlash(prefix string, h http.Handler) http.Handler {
@@ -22,7 +22,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -33,9 +35,11 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	crHandler "sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -86,24 +90,35 @@ type Options struct {
 	DisableCache      bool
 	OwnerInjection    bool
 	LogRequests       bool
+	// PathPrefix is the path prefix of the API server, e.g. /k8s/clusters/c-xyz. Requests
+	// are forwarded with the prefix whether or not they have it. Defaults to the path of
+	// the host of KubeConfig.
+	PathPrefix string
+	// Tokens, if set, are the only bearer tokens the proxy accepts. The owner of a
+	// request is the owner its token was issued for.
+	Tokens *kubeconfig.Tokens
 }
 
 // Run will start a proxy server in a go routine that returns on the error
 // channel if something is not correct on startup. Run will not return until
-// the network socket is listening.
-func Run(done chan error, o Options) error {
-	server, err := newServer("/", o.KubeConfig)
+// the network socket is listening, and returns the address it is listening on.
+func Run(done chan error, o Options) (net.Addr, error) {
+	pathPrefix, err := apiPathPrefix(o)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	server, err := newServer("/", pathPrefix, o.KubeConfig)
+	if err != nil {
+		return nil, err
 	}
 	if o.Handler != nil {
 		server.Handler = o.Handler(server.Handler)
 	}
 	if o.ControllerMap == nil {
-		return fmt.Errorf("failed to get controller map from options")
+		return nil, fmt.Errorf("failed to get controller map from options")
 	}
 	if o.WatchedNamespaces == nil {
-		return fmt.Errorf("failed to get list of watched namespaces from options")
+		return nil, fmt.Errorf("failed to get list of watched namespaces from options")
 	}
 
 	watchedNamespaceMap := make(map[string]interface{})
@@ -115,7 +130,7 @@ func Run(done chan error, o Options) error {
 	// Create apiResources and
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(o.KubeConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	resources := &apiResources{
 		mu:               &sync.RWMutex{},
@@ -128,7 +143,7 @@ func Run(done chan error, o Options) error {
 		log.Info("Initializing and starting informer cache...")
 		informerCache, err := cache.New(o.KubeConfig, cache.Options{})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		ctx, cancel := context.WithCancel(context.TODO())
 		go func() {
@@ -140,7 +155,7 @@ func Run(done chan error, o Options) error {
 		log.Info("Waiting for cache to sync...")
 		synced := informerCache.WaitForCacheSync(context.TODO())
 		if !synced {
-			return fmt.Errorf("failed to sync cache")
+			return nil, fmt.Errorf("failed to sync cache")
 		}
 		log.Info("Cache sync was successful")
 		o.Cache = informerCache
@@ -149,6 +164,20 @@ func Run(done chan error, o Options) error {
 	// Remove the authorization header so the proxy can correctly inject the header.
 	server.Handler = removeAuthorizationHeader(server.Handler)
 
+	apiReader, err := client.New(o.KubeConfig, client.Options{Mapper: o.RESTMapper})
+	if err != nil {
+		return nil, err
+	}
+	var reader client.Reader = apiReader
+	if o.Cache != nil {
+		reader = o.Cache
+	}
+	server.Handler = &impersonationHandler{
+		next:   server.Handler,
+		cMap:   o.ControllerMap,
+		reader: reader,
+	}
+
 	if o.OwnerInjection {
 		server.Handler = &injectOwnerReferenceHandler{
 			next:              server.Handler,
@@ -156,6 +185,8 @@ func Run(done chan error, o Options) error {
 			restMapper:        o.RESTMapper,
 			watchedNamespaces: watchedNamespaceMap,
 			apiResources:      resources,
+			pathPrefix:        pathPrefix,
+			apiReader:         apiReader,
 		}
 	} else {
 		log.Info("Warning: injection of owner references and dependent watches is turned off")
@@ -177,18 +208,48 @@ func Run(done chan error, o Options) error {
 			injectOwnerRef:    o.OwnerInjection,
 			apiResources:      resources,
 			skipPathRegexp:    autoSkipCacheRegexp,
+			pathPrefix:        pathPrefix,
+			watches:           newWatchBroadcaster(),
 		}
 	}
+	server.Handler = &allowlistHandler{
+		next:       server.Handler,
+		cMap:       o.ControllerMap,
+		restMapper: o.RESTMapper,
+		pathPrefix: pathPrefix,
+	}
+	server.Handler = checkModeHandler(server.Handler, pathPrefix)
+	server.Handler = tracingHandler(server.Handler)
+	if o.Tokens != nil {
+		server.Handler = tokenAuthHandler(server.Handler, o.Tokens)
+	}
 
 	l, err := server.Listen(o.Address, o.Port)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	go func() {
 		log.Info("Starting to serve", "Address", l.Addr().String())
 		done <- server.ServeOnListener(l)
 	}()
-	return nil
+	return l.Addr(), nil
+}
+
+// apiPathPrefix returns the path prefix of the API server without trailing slash.
+func apiPathPrefix(o Options) (string, error) {
+	prefix := o.PathPrefix
+	if prefix == "" {
+		hostURL, err := url.Parse(o.KubeConfig.Host)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse API server host %q: %w", o.KubeConfig.Host, err)
+		}
+		prefix = hostURL.Path
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix, nil
 }
 
 // Helper function used by cache response and owner injection
@@ -221,53 +282,48 @@ func addWatchToController(owner kubeconfig.NamespacedOwnerReference, cMap *contr
 	if !ok {
 		return errors.New("failed to find controller in map")
 	}
-	owMap := contents.OwnerWatchMap
-	awMap := contents.AnnotationWatchMap
 	u := &unstructured.Unstructured{}
 	u.SetGroupVersionKind(ownerMapping.GroupVersionKind)
+	nn := types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}
 
 	// Add a watch to controller
 	if contents.WatchDependentResources && !contents.Blacklist[resource.GroupVersionKind()] {
-		// Store watch in map
+		if contents.Dependents == nil {
+			return errors.New("failed to find dependents of controller in map")
+		}
 		// Use EnqueueRequestForOwner unless user has configured watching cluster scoped resources and we have to
 		switch {
 		case useOwnerRef:
-			_, exists := owMap.Get(resource.GroupVersionKind())
-			// If already watching resource no need to add a new watch
-			if exists {
-				return nil
-			}
-
-			owMap.Store(resource.GroupVersionKind())
-			log.Info("Watching child resource", "kind", resource.GroupVersionKind(),
-				"enqueue_kind", u.GroupVersionKind())
-			err := contents.Controller.Watch(&source.Kind{Type: resource},
-				&handler.LoggingEnqueueRequestForOwner{
-					EnqueueRequestForOwner: crHandler.EnqueueRequestForOwner{OwnerType: u},
-				}, predicate.DependentPredicate{})
-			// Store watch in map
+			// The watch is only added if the resource is not watched already, but the
+			// dependency of the owner on the resource is recorded either way.
+			err := contents.Dependents.Watch(nn, resource.GroupVersionKind(), controllermap.OwnerWatch,
+				func(informer cache.Informer) error {
+					log.Info("Watching child resource", "kind", resource.GroupVersionKind(),
+						"enqueue_kind", u.GroupVersionKind())
+					return contents.Controller.Watch(&source.Informer{Informer: informer},
+						dependentHandler(contents, &handler.LoggingEnqueueRequestForOwner{
+							EnqueueRequestForOwner: crHandler.EnqueueRequestForOwner{OwnerType: u},
+						}), predicate.DependentPredicate{})
+				})
 			if err != nil {
 				log.Error(err, "Failed to watch child resource",
 					"kind", resource.GroupVersionKind(), "enqueue_kind", u.GroupVersionKind())
 				return err
 			}
 		case (!useOwnerRef && dataNamespaceScoped) || contents.WatchClusterScopedResources:
-			_, exists := awMap.Get(resource.GroupVersionKind())
-			// If already watching resource no need to add a new watch
-			if exists {
-				return nil
-			}
-			awMap.Store(resource.GroupVersionKind())
 			ownerGK := schema.GroupKind{
 				Kind:  owner.Kind,
 				Group: ownerGV.Group,
 			}
-			log.Info("Watching child resource", "kind", resource.GroupVersionKind(),
-				"enqueue_annotation_type", ownerGK.String())
-			err = contents.Controller.Watch(&source.Kind{Type: resource},
-				&handler.LoggingEnqueueRequestForAnnotation{
-					EnqueueRequestForAnnotation: libhandler.EnqueueRequestForAnnotation{Type: ownerGK},
-				}, predicate.DependentPredicate{})
+			err := contents.Dependents.Watch(nn, resource.GroupVersionKind(), controllermap.AnnotationWatch,
+				func(informer cache.Informer) error {
+					log.Info("Watching child resource", "kind", resource.GroupVersionKind(),
+						"enqueue_annotation_type", ownerGK.String())
+					return contents.Controller.Watch(&source.Informer{Informer: informer},
+						dependentHandler(contents, &handler.LoggingEnqueueRequestForAnnotation{
+							EnqueueRequestForAnnotation: libhandler.EnqueueRequestForAnnotation{Type: ownerGK},
+						}), predicate.DependentPredicate{})
+				})
 			if err != nil {
 				log.Error(err, "Failed to watch child resource",
 					"kind", resource.GroupVersionKind(), "enqueue_kind", u.GroupVersionKind())
@@ -280,6 +336,15 @@ func addWatchToController(owner kubeconfig.NamespacedOwnerReference, cMap *contr
 	return nil
 }
 
+// dependentHandler returns h, which enqueues the owners of the dependent resources of
+// the controller of contents, recording the owners it enqueues if the controller tracks them.
+func dependentHandler(contents *controllermap.Contents, h crHandler.EventHandler) crHandler.EventHandler {
+	if contents.DependentTriggers == nil {
+		return h
+	}
+	return contents.DependentTriggers.Wrap(h)
+}
+
 func removeAuthorizationHeader(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		req.Header.Del("Authorization")
@@ -287,8 +352,47 @@ func removeAuthorizationHeader(h http.Handler) http.Handler {
 	})
 }
 
-// Helper function used by recovering dependent watches and owner ref injection.
+// ownerKey is the context key of the owner of a request authenticated by its token.
+type ownerKey struct{}
+
+// tokenAuthHandler - rejects requests that do not carry one of tokens as bearer token,
+// and records the owner of the token in the context of the others.
+func tokenAuthHandler(h http.Handler, tokens *kubeconfig.Tokens) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if !strings.HasPrefix(auth, prefix) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		owner, ok := tokens.Lookup(strings.TrimPrefix(auth, prefix))
+		if !ok {
+			log.Info("Rejected request with unknown token", "method", req.Method, "uri", req.RequestURI)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ownerKey{}, owner)))
+	})
+}
+
+// Helper function used by recovering dependent watches and owner ref injection. The
+// objects of webhook runs are not owners, as they may not exist, so their requests are
+// treated as requests without owner.
 func getRequestOwnerRef(req *http.Request) (*kubeconfig.NamespacedOwnerReference, error) {
+	owner, err := getRequestWatchOwnerRef(req)
+	if err == nil && owner != nil && owner.Webhook {
+		return nil, nil
+	}
+	return owner, err
+}
+
+// getRequestWatchOwnerRef returns the owner of req like getRequestOwnerRef, including the
+// object of webhook runs, for the handlers that apply the settings of the watch of the run.
+func getRequestWatchOwnerRef(req *http.Request) (*kubeconfig.NamespacedOwnerReference, error) {
+	// Requests authenticated by a token are owned by the owner of the token.
+	if owner, ok := req.Context().Value(ownerKey{}).(*kubeconfig.NamespacedOwnerReference); ok {
+		return owner, nil
+	}
 	owner := kubeconfig.NamespacedOwnerReference{}
 	user, _, ok := req.BasicAuth()
 	if !ok {
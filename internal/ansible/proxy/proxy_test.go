@@ -15,21 +15,29 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
 
 	kcorev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	kmetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
 )
 
 func TestHandler(t *testing.T) {
@@ -42,7 +50,7 @@ func TestHandler(t *testing.T) {
 	}
 	done := make(chan error)
 	cMap := controllermap.NewControllerMap()
-	err = Run(done, Options{
+	_, err = Run(done, Options{
 		Address:           "localhost",
 		Port:              8888,
 		KubeConfig:        mgr.GetConfig(),
@@ -98,6 +106,211 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func TestGetRequestOwnerRefOfWebhookRun(t *testing.T) {
+	owner := &kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{APIVersion: "cache.example.com/v1alpha1", Kind: "Memcached", Name: "example"},
+		Namespace:      "default",
+		Webhook:        true,
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ownerKey{}, owner))
+	// The requests of webhook runs get no owner references or dependent watches.
+	if got, err := getRequestOwnerRef(req); err != nil || got != nil {
+		t.Fatalf("Expected no owner of webhook run, got %v, %v", got, err)
+	}
+	if got, err := getRequestWatchOwnerRef(req); err != nil || got != owner {
+		t.Fatalf("Expected watch owner %v of webhook run, got %v, %v", owner, got, err)
+	}
+}
+
+func TestTokenAuthHandler(t *testing.T) {
+	tokens := kubeconfig.NewTokens()
+	owner := &kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{APIVersion: "cache.example.com/v1alpha1", Kind: "Memcached", Name: "example"},
+		Namespace:      "default",
+	}
+	token, err := tokens.Issue(owner)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+
+	var gotOwner *kubeconfig.NamespacedOwnerReference
+	h := tokenAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotOwner, err = getRequestOwnerRef(req)
+		if err != nil {
+			t.Errorf("Failed to get owner: %v", err)
+		}
+	}), tokens)
+
+	testCases := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "no token", expectedStatus: http.StatusUnauthorized},
+		{name: "basic auth", authorization: "Basic dXNlcjp1bnVzZWQ=", expectedStatus: http.StatusUnauthorized},
+		{name: "unknown token", authorization: "Bearer unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "issued token", authorization: "Bearer " + token, expectedStatus: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotOwner = nil
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tc.expectedStatus {
+				t.Fatalf("Unexpected status %d, expected %d", rec.Code, tc.expectedStatus)
+			}
+			if tc.expectedStatus == http.StatusOK && gotOwner != owner {
+				t.Fatalf("Unexpected owner %v, expected %v", gotOwner, owner)
+			}
+		})
+	}
+
+	tokens.Revoke(token)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected revoked token to be rejected, got status %d", rec.Code)
+	}
+}
+
+// prefixedAPIServer is a fake API server served under a path prefix, which records the
+// requests it receives.
+type prefixedAPIServer struct {
+	prefix string
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (s *prefixedAPIServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.bodies = append(s.bodies, body)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	var resp interface{}
+	switch req.URL.Path {
+	case s.prefix + "/api":
+		resp = kmetav1.APIVersions{Versions: []string{"v1"}}
+	case s.prefix + "/apis":
+		resp = kmetav1.APIGroupList{}
+	case s.prefix + "/api/v1":
+		resp = kmetav1.APIResourceList{GroupVersion: "v1", APIResources: []kmetav1.APIResource{{
+			Name: "configmaps", Namespaced: true, Kind: "ConfigMap",
+			Verbs: kmetav1.Verbs{"create", "get", "list", "watch"},
+		}}}
+	case s.prefix + "/api/v1/namespaces/default/configmaps":
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+		return
+	case s.prefix + "/api/v1/namespaces/default/configmaps/test":
+		resp = kcorev1.ConfigMap{ObjectMeta: kmetav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	default:
+		http.NotFound(w, req)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *prefixedAPIServer) last() (*http.Request, []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1], s.bodies[len(s.bodies)-1]
+}
+
+func TestRunWithPathPrefix(t *testing.T) {
+	apiServer := &prefixedAPIServer{prefix: "/k8s/clusters/c-xyz"}
+	ts := httptest.NewServer(apiServer)
+	defer ts.Close()
+
+	ownerGVK := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(ownerGVK, meta.RESTScopeNamespace)
+	restMapper.Add(kcorev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+
+	done := make(chan error)
+	addr, err := Run(done, Options{
+		Address:           "localhost",
+		Port:              0,
+		KubeConfig:        &rest.Config{Host: ts.URL + apiServer.prefix},
+		RESTMapper:        restMapper,
+		ControllerMap:     controllermap.NewControllerMap(),
+		WatchedNamespaces: []string{"other"},
+		DisableCache:      true,
+		OwnerInjection:    true,
+	})
+	if err != nil {
+		t.Fatalf("Error starting proxy: %v", err)
+	}
+	proxyURL := "http://" + addr.String()
+
+	// Requests are forwarded with the prefix, whether or not they have it.
+	for _, path := range []string{
+		"/api/v1/namespaces/default/configmaps/test",
+		apiServer.prefix + "/api/v1/namespaces/default/configmaps/test",
+	} {
+		resp, err := http.Get(proxyURL + path)
+		if err != nil {
+			t.Fatalf("Error getting %s from proxy: %v", path, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected status %d for %s", resp.StatusCode, path)
+		}
+		if req, _ := apiServer.last(); req.URL.Path != apiServer.prefix+"/api/v1/namespaces/default/configmaps/test" {
+			t.Fatalf("Unexpected path %s forwarded for %s", req.URL.Path, path)
+		}
+	}
+
+	// Owner references are injected into prefixed requests.
+	owner := kubeconfig.NamespacedOwnerReference{
+		OwnerReference: kmetav1.OwnerReference{
+			APIVersion: ownerGVK.GroupVersion().String(), Kind: ownerGVK.Kind, Name: "example", UID: "uid",
+		},
+		Namespace: "default",
+	}
+	ownerJSON, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatalf("Failed to marshal owner: %v", err)
+	}
+	cm := []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"test","namespace":"default"}}`)
+	req, err := http.NewRequest(http.MethodPost,
+		proxyURL+apiServer.prefix+"/api/v1/namespaces/default/configmaps", bytes.NewReader(cm))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.SetBasicAuth(base64.StdEncoding.EncodeToString(ownerJSON), "unused")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error creating configmap through proxy: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Unexpected status %d creating configmap", resp.StatusCode)
+	}
+	forwarded, body := apiServer.last()
+	if forwarded.URL.Path != apiServer.prefix+"/api/v1/namespaces/default/configmaps" {
+		t.Fatalf("Unexpected path %s forwarded", forwarded.URL.Path)
+	}
+	created := kcorev1.ConfigMap{}
+	if err := json.Unmarshal(body, &created); err != nil {
+		t.Fatalf("Failed to unmarshal forwarded body: %v", err)
+	}
+	if len(created.OwnerReferences) != 1 || created.OwnerReferences[0].Name != "example" {
+		t.Fatalf("Expected owner reference to be injected, got %v", created.OwnerReferences)
+	}
+}
+
 func createPod(name, namespace string, cl client.Client) (client.Object, error) {
 	three := int64(3)
 	pod := &kcorev1.Pod{
@@ -41,7 +41,7 @@ type RequestInfo struct {
 	// IsResourceRequest indicates whether or not the request is for an API
 	// resource or subresource
 	IsResourceRequest bool
-	// Path is the URL path of the request
+	// Path is the URL path of the request, without the path prefix of the API server
 	Path string
 	// Verb is the kube verb associated with the request for API requests, not
 	// the http verb.  This includes things like list and watch.  for
@@ -93,6 +93,24 @@ var NamespaceSubResourcesForTest = sets.NewString(namespaceSubresources.List()..
 type RequestInfoFactory struct {
 	APIPrefixes          sets.String // without leading and trailing slashes
 	GrouplessAPIPrefixes sets.String // without leading and trailing slashes
+	// PathPrefix is the path prefix of the API server, e.g. /k8s/clusters/c-xyz, that is
+	// removed from the paths of requests that have it. Without trailing slash.
+	PathPrefix string
+}
+
+// TrimPathPrefix returns path without prefix, if path is prefix or starts with prefix
+// followed by a slash. The result always has a leading slash.
+func TrimPathPrefix(prefix, path string) string {
+	if prefix == "" || prefix == "/" {
+		return path
+	}
+	if path == prefix {
+		return "/"
+	}
+	if strings.HasPrefix(path, prefix+"/") {
+		return strings.TrimPrefix(path, prefix)
+	}
+	return path
 }
 
 // TODO write an integration test against the swagger doc to test the
@@ -130,13 +148,14 @@ type RequestInfoFactory struct {
 func (r *RequestInfoFactory) NewRequestInfo(req *http.Request) (*RequestInfo, error) { //nolint:gocyclo
 	// TODO: Try to reduce the complexity of this last measured at 33 (failing at > 30) and remove the // nolint:gocyclo
 	// start with a non-resource request until proven otherwise
+	apiPath := TrimPathPrefix(r.PathPrefix, req.URL.Path)
 	requestInfo := RequestInfo{
 		IsResourceRequest: false,
-		Path:              req.URL.Path,
+		Path:              apiPath,
 		Verb:              strings.ToLower(req.Method),
 	}
 
-	currentParts := splitPath(req.URL.Path)
+	currentParts := splitPath(apiPath)
 	if len(currentParts) < 3 {
 		// return a non-resource request
 		return &requestInfo, nil
@@ -0,0 +1,98 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package requestfactory
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestNewRequestInfoWithPathPrefix(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		pathPrefix           string
+		path                 string
+		expectedPath         string
+		expectedResource     bool
+		expectedNamespace    string
+		expectedAPIGroup     string
+		expectedResourceName string
+	}{
+		{
+			name:                 "no prefix",
+			path:                 "/api/v1/namespaces/default/pods/test",
+			expectedPath:         "/api/v1/namespaces/default/pods/test",
+			expectedResource:     true,
+			expectedNamespace:    "default",
+			expectedResourceName: "pods",
+		},
+		{
+			name:                 "prefixed request",
+			pathPrefix:           "/k8s/clusters/c-xyz",
+			path:                 "/k8s/clusters/c-xyz/apis/apps/v1/namespaces/default/deployments",
+			expectedPath:         "/apis/apps/v1/namespaces/default/deployments",
+			expectedResource:     true,
+			expectedNamespace:    "default",
+			expectedAPIGroup:     "apps",
+			expectedResourceName: "deployments",
+		},
+		{
+			name:                 "unprefixed request",
+			pathPrefix:           "/k8s/clusters/c-xyz",
+			path:                 "/api/v1/namespaces/default/pods",
+			expectedPath:         "/api/v1/namespaces/default/pods",
+			expectedResource:     true,
+			expectedNamespace:    "default",
+			expectedResourceName: "pods",
+		},
+		{
+			name:         "non-resource request",
+			pathPrefix:   "/k8s/clusters/c-xyz",
+			path:         "/k8s/clusters/c-xyz/version",
+			expectedPath: "/version",
+		},
+		{
+			name:         "prefix only matches whole segments",
+			pathPrefix:   "/k8s",
+			path:         "/k8sapi/v1/namespaces/default/pods",
+			expectedPath: "/k8sapi/v1/namespaces/default/pods",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rf := RequestInfoFactory{
+				APIPrefixes:          sets.NewString("api", "apis"),
+				GrouplessAPIPrefixes: sets.NewString("api"),
+				PathPrefix:           tc.pathPrefix,
+			}
+			r, err := rf.NewRequestInfo(httptest.NewRequest("GET", tc.path, nil))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if r.Path != tc.expectedPath {
+				t.Fatalf("Unexpected path %q, expected %q", r.Path, tc.expectedPath)
+			}
+			if r.IsResourceRequest != tc.expectedResource {
+				t.Fatalf("Unexpected resource request %v, expected %v", r.IsResourceRequest, tc.expectedResource)
+			}
+			if r.Namespace != tc.expectedNamespace || r.APIGroup != tc.expectedAPIGroup ||
+				r.Resource != tc.expectedResourceName {
+				t.Fatalf("Unexpected request info %+v", r)
+			}
+		})
+	}
+}
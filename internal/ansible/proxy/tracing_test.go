@@ -0,0 +1,87 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/tracing"
+)
+
+func TestTracingHandler(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	_, run := tracing.Tracer().Start(context.Background(), "Reconcile")
+	defer tracing.RegisterRun("42", run.SpanContext())()
+
+	h := tracingHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !trace.SpanContextFromContext(req.Context()).IsValid() {
+			t.Fatalf("Expected the request to be traced")
+		}
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	}))
+
+	testCases := []struct {
+		name           string
+		owner          *kubeconfig.NamespacedOwnerReference
+		expectedParent trace.SpanID
+	}{
+		{
+			name:           "request of a run",
+			owner:          &kubeconfig.NamespacedOwnerReference{OwnerReference: metav1.OwnerReference{Name: "a"}, Ident: "42"},
+			expectedParent: run.SpanContext().SpanID(),
+		},
+		{
+			name:  "request of a finished run",
+			owner: &kubeconfig.NamespacedOwnerReference{OwnerReference: metav1.OwnerReference{Name: "a"}, Ident: "7"},
+		},
+		{
+			name: "request without owner",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/configmaps", nil)
+			if tc.owner != nil {
+				req = req.WithContext(context.WithValue(req.Context(), ownerKey{}, tc.owner))
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("Unexpected number of spans %d, expected 1", len(spans))
+			}
+			if spans[0].Parent.SpanID() != tc.expectedParent {
+				t.Fatalf("Unexpected parent %v, expected %v", spans[0].Parent.SpanID(), tc.expectedParent)
+			}
+			if spans[0].Status.Code != codes.Error {
+				t.Fatalf("Expected the failed request to have an error status")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// runPriorities are the priorities of the run priority classes of watches. The waiting
+// runs of higher priorities start first.
+var runPriorities = map[string]int{
+	watches.RunPriorityClassHigh:   2,
+	watches.RunPriorityClassNormal: 1,
+	watches.RunPriorityClassLow:    0,
+}
+
+// RunBudget - limits the number of Ansible runs of all the watches of the operator that
+// run at the same time. When a run finishes, the next run to start is the one that has
+// waited longest in the highest priority class with waiting runs, from the GVK of that
+// class with the fewest running runs, so that the runs of one GVK cannot take up the
+// budget of the others. A nil RunBudget does not limit runs.
+type RunBudget struct {
+	size int
+
+	mu      sync.Mutex
+	running int
+	// runningByGVK is the number of running runs of each GVK that has any.
+	runningByGVK map[schema.GroupVersionKind]int
+	// queue holds the waiting runs in the order they started waiting.
+	queue []*budgetWaiter
+}
+
+// budgetWaiter - a run waiting for its share of a RunBudget.
+type budgetWaiter struct {
+	gvk      schema.GroupVersionKind
+	priority int
+	// ready is closed once the run may start.
+	ready chan struct{}
+}
+
+// NewRunBudget - returns a RunBudget that lets size runs run at the same time, or nil if
+// size is not positive.
+func NewRunBudget(size int) *RunBudget {
+	if size <= 0 {
+		return nil
+	}
+	return &RunBudget{
+		size:         size,
+		runningByGVK: map[schema.GroupVersionKind]int{},
+	}
+}
+
+// acquire blocks until a run of gvk in priorityClass may start, and returns the func that
+// gives its share of the budget back once the run has finished. An error is returned if
+// ctx is done before the run may start.
+func (b *RunBudget) acquire(ctx context.Context, gvk schema.GroupVersionKind, priorityClass string) (func(), error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	w := &budgetWaiter{gvk: gvk, priority: runPriorities[priorityClass], ready: make(chan struct{})}
+	b.mu.Lock()
+	b.queue = append(b.queue, w)
+	b.dispatch()
+	b.mu.Unlock()
+
+	select {
+	case <-w.ready:
+	default:
+		metrics.RunQueued(gvk.String(), priorityClass)
+		select {
+		case <-w.ready:
+			metrics.RunDequeued(gvk.String(), priorityClass)
+		case <-ctx.Done():
+			metrics.RunDequeued(gvk.String(), priorityClass)
+			b.cancel(w)
+			return nil, ctx.Err()
+		}
+	}
+	metrics.ObserveRunQueueWait(gvk.String(), priorityClass, time.Since(start).Seconds())
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.release(gvk)
+		})
+	}, nil
+}
+
+// cancel removes w from the queue, or gives its share back if it may already start.
+func (b *RunBudget) cancel(w *budgetWaiter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, queued := range b.queue {
+		if queued == w {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			return
+		}
+	}
+	b.release(w.gvk)
+}
+
+// release gives the share of a run of gvk back and starts the next waiting run. b.mu must
+// be held.
+func (b *RunBudget) release(gvk schema.GroupVersionKind) {
+	b.running--
+	if b.runningByGVK[gvk]--; b.runningByGVK[gvk] <= 0 {
+		delete(b.runningByGVK, gvk)
+	}
+	b.dispatch()
+}
+
+// dispatch starts the waiting runs the budget has room for. b.mu must be held.
+func (b *RunBudget) dispatch() {
+	for b.running < b.size && len(b.queue) > 0 {
+		next := 0
+		for i := 1; i < len(b.queue); i++ {
+			if b.startsBefore(b.queue[i], b.queue[next]) {
+				next = i
+			}
+		}
+		w := b.queue[next]
+		b.queue = append(b.queue[:next], b.queue[next+1:]...)
+		b.running++
+		b.runningByGVK[w.gvk]++
+		close(w.ready)
+	}
+}
+
+// startsBefore returns whether w starts before other, which waited longer.
+func (b *RunBudget) startsBefore(w, other *budgetWaiter) bool {
+	if w.priority != other.priority {
+		return w.priority > other.priority
+	}
+	return b.runningByGVK[w.gvk] < b.runningByGVK[other.gvk]
+}
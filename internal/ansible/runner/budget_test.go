@@ -0,0 +1,168 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+var (
+	gvkA = schema.GroupVersionKind{Group: "app.example.com", Version: "v1", Kind: "A"}
+	gvkB = schema.GroupVersionKind{Group: "app.example.com", Version: "v1", Kind: "B"}
+	gvkC = schema.GroupVersionKind{Group: "app.example.com", Version: "v1", Kind: "C"}
+)
+
+// budgetRun - a run started by a RunBudget.
+type budgetRun struct {
+	name    string
+	release func()
+}
+
+// queueRun starts waiting for the share of a run of gvk in b, and returns once the run is
+// queued or started. The run is sent to started once it may start.
+func queueRun(t *testing.T, b *RunBudget, started chan<- budgetRun, name string, gvk schema.GroupVersionKind,
+	priorityClass string) {
+	t.Helper()
+	// No shares are given back while the run is queued, so it is queued or started once
+	// the runs that are running or queued are more than before.
+	runs := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.running + len(b.queue)
+	}
+	before := runs()
+	go func() {
+		release, err := b.acquire(context.TODO(), gvk, priorityClass)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			return
+		}
+		started <- budgetRun{name: name, release: release}
+	}()
+	for i := 0; i < 500; i++ {
+		if runs() > before {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Run %s was neither queued nor started", name)
+}
+
+func expectStarted(t *testing.T, started <-chan budgetRun, name string) func() {
+	t.Helper()
+	select {
+	case run := <-started:
+		if run.name != name {
+			t.Fatalf("Unexpected run %s started, expected %s", run.name, name)
+		}
+		return run.release
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run %s did not start", name)
+	}
+	return nil
+}
+
+func expectNoneStarted(t *testing.T, started <-chan budgetRun) {
+	t.Helper()
+	select {
+	case run := <-started:
+		t.Fatalf("Unexpected run %s started", run.name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestRunBudget(t *testing.T) {
+	b := NewRunBudget(2)
+	started := make(chan budgetRun, 10)
+
+	queueRun(t, b, started, "a1", gvkA, watches.RunPriorityClassNormal)
+	releaseA1 := expectStarted(t, started, "a1")
+	queueRun(t, b, started, "a2", gvkA, watches.RunPriorityClassNormal)
+	releaseA2 := expectStarted(t, started, "a2")
+
+	// The budget is used up, so the other runs wait.
+	queueRun(t, b, started, "a3", gvkA, watches.RunPriorityClassNormal)
+	queueRun(t, b, started, "b1", gvkB, watches.RunPriorityClassNormal)
+	queueRun(t, b, started, "c1", gvkC, watches.RunPriorityClassLow)
+	queueRun(t, b, started, "a4", gvkA, watches.RunPriorityClassHigh)
+	expectNoneStarted(t, started)
+
+	// Runs of higher priority classes start first. Giving a share back twice only gives it
+	// back once.
+	releaseA1()
+	releaseA1()
+	releaseA4 := expectStarted(t, started, "a4")
+	expectNoneStarted(t, started)
+
+	// Within a class, the GVK with the fewest running runs goes first, even though a3
+	// waited longer than b1.
+	releaseA2()
+	releaseB1 := expectStarted(t, started, "b1")
+
+	// Runs of lower priority classes start last.
+	releaseA4()
+	releaseA3 := expectStarted(t, started, "a3")
+	releaseB1()
+	releaseC1 := expectStarted(t, started, "c1")
+	releaseA3()
+	releaseC1()
+
+	if b.running != 0 || len(b.runningByGVK) != 0 || len(b.queue) != 0 {
+		t.Fatalf("Unexpected budget state: %d running, %v by GVK, %d queued", b.running, b.runningByGVK,
+			len(b.queue))
+	}
+}
+
+func TestRunBudgetCancel(t *testing.T) {
+	b := NewRunBudget(1)
+	release, err := b.acquire(context.TODO(), gvkA, watches.RunPriorityClassNormal)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := b.acquire(ctx, gvkB, watches.RunPriorityClassNormal); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Unexpected error %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if len(b.queue) != 0 {
+		t.Fatalf("Unexpected %d queued runs", len(b.queue))
+	}
+
+	// The cancelled run does not take the share that is given back.
+	release()
+	if _, err := b.acquire(context.TODO(), gvkB, watches.RunPriorityClassNormal); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestNilRunBudget(t *testing.T) {
+	if b := NewRunBudget(0); b != nil {
+		t.Fatalf("Unexpected budget %+v for size 0", b)
+	}
+	var b *RunBudget
+	release, err := b.acquire(context.TODO(), gvkA, watches.RunPriorityClassNormal)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	release()
+}
@@ -15,9 +15,13 @@
 package eventapi
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -29,6 +33,10 @@ const (
 	EventRunnerOnOk = "runner_on_ok"
 	// EventRunnerOnFailed - task finished with failed status.
 	EventRunnerOnFailed = "runner_on_failed"
+	// EventRunnerOnSkipped - task was skipped.
+	EventRunnerOnSkipped = "runner_on_skipped"
+	// EventRunnerOnUnreachable - task could not reach its host.
+	EventRunnerOnUnreachable = "runner_on_unreachable"
 	// EventPlaybookOnStats - playbook has finished running.
 	EventPlaybookOnStats = "playbook_on_stats"
 	// EventRunnerItemOnOk - item finished with ok status.
@@ -108,11 +116,30 @@ func (je JobEvent) GetFailedPlaybookMessage() string {
 		return message
 	}
 	if m, ok := result["msg"].(string); ok {
-		message = m
+		message = apiStatusMessage(m)
 	}
 	return message
 }
 
+// apiStatusMessage - replaces the Kubernetes Status that the k8s modules embed in the
+// message of failed API requests, e.g. `Failed to create object: b'{"kind":"Status",...}'`,
+// with the message of the Status.
+func apiStatusMessage(msg string) string {
+	start := strings.Index(msg, `{"kind":"Status"`)
+	end := strings.LastIndex(msg, "}")
+	if start < 0 || end < start {
+		return msg
+	}
+	status := struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal([]byte(msg[start:end+1]), &status); err != nil || status.Message == "" {
+		return msg
+	}
+	prefix := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(msg[:start]), "b'"))
+	return strings.TrimSpace(prefix + " " + status.Message)
+}
+
 // IgnoreError - Does the job event contain the ignore_error ansible flag
 func (je JobEvent) IgnoreError() bool {
 	ignoreErrors, ok := je.EventData["ignore_errors"]
@@ -125,6 +152,122 @@ func (je JobEvent) IgnoreError() bool {
 	return false
 }
 
+// Task - name of the task of the event
+func (je JobEvent) Task() string {
+	task, _ := je.EventData["task"].(string)
+	return task
+}
+
+// Role - name of the role of the task of the event, if any
+func (je JobEvent) Role() string {
+	role, _ := je.EventData["role"].(string)
+	return role
+}
+
+// TaskUUID - the uuid of the task of the event
+func (je JobEvent) TaskUUID() string {
+	uuid, _ := je.EventData["task_uuid"].(string)
+	return uuid
+}
+
+// Changed - Does the result of the task of the event report a change
+func (je JobEvent) Changed() bool {
+	result, ok := je.EventData["res"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	changed, _ := result["changed"].(bool)
+	return changed
+}
+
+// Diff - the changes the task of the event reports when Ansible runs with --diff, as a
+// unified diff. Objects, such as those compared by the k8s modules, are diffed as YAML.
+func (je JobEvent) Diff() string {
+	result, ok := je.EventData["res"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	var diffs []interface{}
+	switch d := result["diff"].(type) {
+	case map[string]interface{}:
+		diffs = []interface{}{d}
+	case []interface{}:
+		diffs = d
+	}
+	var out []string
+	for _, di := range diffs {
+		d, ok := di.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if prepared, ok := d["prepared"].(string); ok {
+			out = append(out, prepared)
+			continue
+		}
+		_, hasBefore := d["before"]
+		_, hasAfter := d["after"]
+		if !hasBefore && !hasAfter {
+			continue
+		}
+		fromFile, ok := d["before_header"].(string)
+		if !ok {
+			fromFile = "before"
+		}
+		toFile, ok := d["after_header"].(string)
+		if !ok {
+			toFile = "after"
+		}
+		unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(diffText(d["before"])),
+			B:        splitLines(diffText(d["after"])),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err == nil && unified != "" {
+			out = append(out, unified)
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// splitLines splits text into lines that end with a line break.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	lines := strings.SplitAfter(text, "\n")
+	return lines[:len(lines)-1]
+}
+
+// diffText returns the text v is diffed as: strings as is, and anything else as YAML.
+func diffText(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// Duration - how long the task of the event took, which ansible-runner reports in the
+// events of finished tasks
+func (je JobEvent) Duration() (time.Duration, bool) {
+	seconds, ok := je.EventData["duration"].(float64)
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
 // Rescued - Detects whether or not a task was rescued
 func (je JobEvent) Rescued() bool {
 	if rescued, contains := je.EventData["rescued"]; contains {
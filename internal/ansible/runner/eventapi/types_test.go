@@ -0,0 +1,115 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventapi
+
+import (
+	"testing"
+)
+
+func TestGetFailedPlaybookMessage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		res      interface{}
+		expected string
+	}{
+		{
+			name:     "no result",
+			expected: defaultFailedMessage,
+		},
+		{
+			name:     "plain message",
+			res:      map[string]interface{}{"msg": "something failed"},
+			expected: "something failed",
+		},
+		{
+			name: "API status",
+			res: map[string]interface{}{"msg": `Failed to create object: b'{"kind":"Status","apiVersion":"v1",` +
+				`"status":"Failure","message":"configmaps is forbidden: User \"system:serviceaccount:tenant:default\" ` +
+				`cannot create resource \"configmaps\"","reason":"Forbidden","code":403}\n'`},
+			expected: `Failed to create object: configmaps is forbidden: User "system:serviceaccount:tenant:default" ` +
+				`cannot create resource "configmaps"`,
+		},
+		{
+			name:     "invalid status",
+			res:      map[string]interface{}{"msg": `Failed: {"kind":"Status", broken}`},
+			expected: `Failed: {"kind":"Status", broken}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			je := JobEvent{EventData: map[string]interface{}{}}
+			if tc.res != nil {
+				je.EventData["res"] = tc.res
+			}
+			if m := je.GetFailedPlaybookMessage(); m != tc.expected {
+				t.Fatalf("Unexpected message %q, expected %q", m, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		res      interface{}
+		expected string
+	}{
+		{
+			name: "no diff",
+			res:  map[string]interface{}{"changed": true},
+		},
+		{
+			name: "prepared diff",
+			res: map[string]interface{}{"diff": map[string]interface{}{
+				"prepared": "--- before\n+++ after\n",
+			}},
+			expected: "--- before\n+++ after\n",
+		},
+		{
+			name: "text diff",
+			res: map[string]interface{}{"diff": []interface{}{map[string]interface{}{
+				"before":        "a\nb\n",
+				"after":         "a\nc",
+				"before_header": "/etc/config",
+				"after_header":  "/etc/config",
+			}}},
+			expected: "--- /etc/config\n+++ /etc/config\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+		{
+			name: "object diff",
+			res: map[string]interface{}{"diff": map[string]interface{}{
+				"before": map[string]interface{}{"spec": map[string]interface{}{"replicas": 1.0}},
+				"after":  map[string]interface{}{"spec": map[string]interface{}{"replicas": 3.0}},
+			}},
+			expected: "--- before\n+++ after\n@@ -1,2 +1,2 @@\n spec:\n-  replicas: 1\n+  replicas: 3\n",
+		},
+		{
+			name: "created object",
+			res: map[string]interface{}{"diff": map[string]interface{}{
+				"before": map[string]interface{}{},
+				"after":  map[string]interface{}{"kind": "ConfigMap"},
+			}},
+			expected: "--- before\n+++ after\n@@ -1 +1 @@\n-{}\n+kind: ConfigMap\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := JobEvent{EventData: map[string]interface{}{"res": tc.res}}
+			if diff := event.Diff(); diff != tc.expected {
+				t.Fatalf("Unexpected diff\nexpected: %q\nactual: %q", tc.expected, diff)
+			}
+		})
+	}
+}
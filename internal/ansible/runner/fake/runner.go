@@ -15,6 +15,7 @@
 package fake
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
 // Runner - implements the Runner interface for a GVK that's being watched.
@@ -37,6 +39,10 @@ type Runner struct {
 	JobEvents []eventapi.JobEvent
 	//Stdout standard out to reply if failure occurs.
 	Stdout string
+	// Runs is the number of times Run was called.
+	Runs int
+	// Finalizers are the finalizer stages, used instead of Finalizer if set.
+	Finalizers []watches.Finalizer
 }
 
 type runResult struct {
@@ -56,7 +62,8 @@ func (r *runResult) Stdout() (string, error) {
 }
 
 // Run - runs the fake runner.
-func (r *Runner) Run(_ string, u *unstructured.Unstructured, _ string) (runner.RunResult, error) {
+func (r *Runner) Run(_ context.Context, _ string, u *unstructured.Unstructured, _ string) (runner.RunResult, error) {
+	r.Runs++
 	if r.Error != nil {
 		return nil, r.Error
 	}
@@ -90,7 +97,10 @@ func (r *Runner) GetWatchClusterScopedResources() bool {
 	return r.WatchClusterScopedResources
 }
 
-// GetFinalizer - gets the fake finalizer.
-func (r *Runner) GetFinalizer() (string, bool) {
-	return r.Finalizer, r.Finalizer != ""
+// GetFinalizers - gets the fake finalizer stages.
+func (r *Runner) GetFinalizers() []watches.Finalizer {
+	if len(r.Finalizers) > 0 || r.Finalizer == "" {
+		return r.Finalizers
+	}
+	return []watches.Finalizer{{Name: r.Finalizer}}
 }
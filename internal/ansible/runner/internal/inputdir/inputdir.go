@@ -37,8 +37,13 @@ type InputDir struct {
 	EnvVars      map[string]string
 	Settings     map[string]string
 	CmdLine      string
+	// CheckMode runs Ansible in check mode, reporting the changes it would make as diffs.
+	CheckMode bool
 }
 
+// checkModeCmdLine is appended to the command line of runs in check mode.
+const checkModeCmdLine = "--check --diff"
+
 // makeDirs creates the required directory structure.
 func (i *InputDir) makeDirs() error {
 	for _, path := range []string{"env", "project", "inventory"} {
@@ -137,12 +142,19 @@ func (i *InputDir) Write() error {
 		i.CmdLine = i.CmdLine[1 : len(i.CmdLine)-1]
 	}
 
-	cmdLineBytes := []byte(i.CmdLine)
+	cmdLine := i.CmdLine
+	if i.CheckMode {
+		cmdLine = strings.TrimSpace(cmdLine + " " + checkModeCmdLine)
+	}
+	cmdLineBytes := []byte(cmdLine)
 	if len(cmdLineBytes) > 0 {
 		err = i.addFile("env/cmdline", cmdLineBytes)
 		if err != nil {
 			return err
 		}
+	} else if err := os.Remove(filepath.Join(i.Path, "env/cmdline")); err != nil && !os.IsNotExist(err) {
+		// The input directory is reused, so the command line of a previous run must not remain.
+		return err
 	}
 
 	// ANSIBLE_INVENTORY takes precedence over our generated hosts file
@@ -0,0 +1,509 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// errWorkerPoolClosed is returned for runs on a closed workerPool.
+var errWorkerPoolClosed = errors.New("ansible-runner worker pool is closed")
+
+// workerStartTimeout is how long a worker process may take to import ansible and
+// accept connections on its socket.
+const workerStartTimeout = 2 * time.Minute
+
+// workerScript is run by every worker process of a workerPool. It imports ansible-runner
+// and ansible once, then forks a child for every job it receives on its unix socket so
+// that each run starts from an already initialized interpreter.
+const workerScript = `import json
+import os
+import socket
+import sys
+import traceback
+
+import ansible_runner
+
+try:
+    # Import the bulk of ansible up front so that every forked job starts warm.
+    import ansible.executor.playbook_executor  # noqa: F401
+    import ansible.plugins.loader  # noqa: F401
+except ImportError:
+    pass
+
+
+def run_job(job):
+    kwargs = {
+        "private_data_dir": job["private_data_dir"],
+        "ident": job["ident"],
+        "rotate_artifacts": job.get("rotate_artifacts", 0),
+        "verbosity": job.get("verbosity", 0),
+        "quiet": True,
+    }
+    if job.get("role"):
+        kwargs["role"] = job["role"]
+        kwargs["roles_path"] = [job["roles_path"]]
+        kwargs["hosts"] = "localhost"
+        kwargs["role_skip_facts"] = job.get("role_skip_facts", False)
+    else:
+        kwargs["playbook"] = job["playbook"]
+    return ansible_runner.run(**kwargs).rc
+
+
+def fork_job(job):
+    pid = os.fork()
+    if pid != 0:
+        return pid
+    rc = 1
+    try:
+        os.setpgid(0, 0)
+        os.environ.update(job.get("env") or {})
+        rc = run_job(job)
+    except Exception:
+        traceback.print_exc()
+    finally:
+        os._exit(rc if isinstance(rc, int) and 0 <= rc < 256 else 1)
+
+
+def exit_code(status):
+    if os.WIFEXITED(status):
+        return os.WEXITSTATUS(status)
+    return -os.WTERMSIG(status)
+
+
+def send(stream, msg):
+    stream.write((json.dumps(msg) + "\n").encode())
+    stream.flush()
+
+
+def main(path):
+    server = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+    server.bind(path)
+    server.listen(1)
+    while True:
+        conn, _ = server.accept()
+        with conn, conn.makefile("rwb") as stream:
+            try:
+                line = stream.readline()
+                if not line:
+                    # The operator checks that the worker accepts connections.
+                    continue
+                job = json.loads(line)
+                pid = fork_job(job)
+                send(stream, {"pid": pid})
+                _, status = os.waitpid(pid, 0)
+                send(stream, {"rc": exit_code(status), "done": True})
+            except Exception as e:
+                traceback.print_exc()
+                try:
+                    send(stream, {"error": str(e), "done": True})
+                except Exception:
+                    pass
+
+
+if __name__ == "__main__":
+    main(sys.argv[1])
+`
+
+// poolRunner - implements the Runner interface for a GVK that's being watched by
+// dispatching runs to a pool of persistent ansible-runner worker processes.
+type poolRunner struct {
+	*runner
+	pool   *workerPool
+	target jobTarget
+	// finalizerTargets are the targets of the finalizer stages.
+	finalizerTargets []jobTarget
+}
+
+// jobTarget is the playbook or role a worker job runs.
+type jobTarget struct {
+	Playbook string
+	Role     string
+}
+
+func newPoolRunner(r *runner, watch watches.Watch) *poolRunner {
+	pr := &poolRunner{
+		runner: r,
+		pool:   newWorkerPool(watch.WorkerPool.Size),
+		target: jobTarget{Playbook: watch.Playbook, Role: watch.Role},
+	}
+	// Mirror the finalizer handling of New: without its own playbook or role a
+	// finalizer stage runs the watch's playbook or role.
+	for _, f := range watch.FinalizerStages() {
+		target := pr.target
+		switch {
+		case f.Playbook != "":
+			target = jobTarget{Playbook: f.Playbook}
+		case f.Role != "":
+			target = jobTarget{Role: f.Role}
+		}
+		pr.finalizerTargets = append(pr.finalizerTargets, target)
+	}
+	return pr
+}
+
+// Close stops the worker processes of the pool. Jobs that are running are not
+// interrupted.
+func (r *poolRunner) Close() error {
+	r.pool.close()
+	return nil
+}
+
+func (r *poolRunner) Run(ctx context.Context, ident string, u *unstructured.Unstructured,
+	kubeconfig string) (RunResult, error) {
+	return r.run(ctx, ident, u, kubeconfig, r.execute)
+}
+
+// execute runs ansible-runner on the next idle worker of the pool. The process running the
+// job is terminated when ctx is done.
+func (r *poolRunner) execute(ctx context.Context, logger logr.Logger, ident, inputDirPath string, maxArtifacts,
+	verbosity int, kubeconfig string, stage int) error {
+	// Like the processes of execute, jobs are counted as runners from when they start on a
+	// worker until they exit.
+	started := false
+	defer func() {
+		if started {
+			metrics.RunnerExited(r.GVK.String())
+		}
+	}()
+	rc, err := r.pool.run(ctx, r.newJob(ident, inputDirPath, maxArtifacts, verbosity, kubeconfig, stage), func() {
+		started = true
+		metrics.RunnerStarted(r.GVK.String())
+	})
+	if err != nil {
+		logger.Error(err, "Failed to run job on ansible-runner worker")
+		return err
+	}
+	if rc != 0 {
+		err = fmt.Errorf("ansible-runner worker job exited with code %d", rc)
+		logger.Error(err, "")
+		return err
+	}
+	return nil
+}
+
+func (r *poolRunner) newJob(ident, inputDirPath string, maxArtifacts, verbosity int, kubeconfig string,
+	stage int) workerJob {
+	target := r.target
+	if stage >= 0 {
+		target = r.finalizerTargets[stage]
+	}
+	j := workerJob{
+		Ident:           ident,
+		PrivateDataDir:  inputDirPath,
+		RotateArtifacts: maxArtifacts,
+		Verbosity:       verbosity,
+		Playbook:        target.Playbook,
+		Env: map[string]string{
+			"K8S_AUTH_KUBECONFIG": kubeconfig,
+			"KUBECONFIG":          kubeconfig,
+		},
+	}
+	if target.Role != "" {
+		j.RolesPath, j.Role = filepath.Split(target.Role)
+		// See roleCmdFunc: ansible-runner does not respect ANSIBLE_GATHERING for roles.
+		j.RoleSkipFacts = os.Getenv("ANSIBLE_GATHERING") == "explicit"
+	}
+	return j
+}
+
+// workerJob is the message sent to a worker to start a run.
+type workerJob struct {
+	Ident           string            `json:"ident"`
+	PrivateDataDir  string            `json:"private_data_dir"`
+	Playbook        string            `json:"playbook,omitempty"`
+	Role            string            `json:"role,omitempty"`
+	RolesPath       string            `json:"roles_path,omitempty"`
+	RoleSkipFacts   bool              `json:"role_skip_facts,omitempty"`
+	RotateArtifacts int               `json:"rotate_artifacts"`
+	Verbosity       int               `json:"verbosity"`
+	Env             map[string]string `json:"env,omitempty"`
+}
+
+// workerReply is a message sent back by a worker. A worker replies once with the PID of
+// the process running the job, and once more with Done set when the job has finished.
+type workerReply struct {
+	PID   int    `json:"pid,omitempty"`
+	RC    int    `json:"rc"`
+	Done  bool   `json:"done,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// workerPool - a fixed number of ansible-runner worker processes. The processes are
+// started on the first run so that creating a runner does not spawn any processes. If they
+// cannot be started, the next run tries again.
+type workerPool struct {
+	size int
+
+	// startMu guards workers, which is set once all worker processes have started.
+	startMu sync.Mutex
+	workers chan *worker
+
+	// mu guards the processes of all workers, which are killed when the pool is closed.
+	mu     sync.Mutex
+	all    []*worker
+	closed bool
+}
+
+func newWorkerPool(size int) *workerPool {
+	if size < 1 {
+		size = 1
+	}
+	return &workerPool{size: size}
+}
+
+// start starts the worker processes unless they are started already, and returns the
+// channel of idle workers.
+func (p *workerPool) start() (chan *worker, error) {
+	p.startMu.Lock()
+	defer p.startMu.Unlock()
+	if p.workers != nil {
+		return p.workers, nil
+	}
+	workers, err := p.startWorkers()
+	if err != nil {
+		return nil, err
+	}
+	p.workers = workers
+	return workers, nil
+}
+
+// close kills the worker processes. A closed pool does not run any more jobs.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, w := range p.all {
+		w.kill()
+	}
+}
+
+// startWorker starts w unless the pool is closed. w is added to the workers killed when
+// the pool is closed before its process is started.
+func (p *workerPool) startWorker(w *worker) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errWorkerPoolClosed
+	}
+	if !w.added {
+		w.added = true
+		p.all = append(p.all, w)
+	}
+	return w.start()
+}
+
+// startWorkers starts the worker processes and returns the channel of idle workers. If a
+// worker cannot be started, the workers started before it are killed.
+func (p *workerPool) startWorkers() (chan *worker, error) {
+	dir, err := ioutil.TempDir("", "ansible-worker")
+	if err != nil {
+		return nil, err
+	}
+	script := filepath.Join(dir, "worker.py")
+	if err := ioutil.WriteFile(script, []byte(workerScript), 0600); err != nil {
+		p.removeDir(dir)
+		return nil, err
+	}
+	workers := make(chan *worker, p.size)
+	for i := 0; i < p.size; i++ {
+		w := &worker{
+			script:     script,
+			socketPath: filepath.Join(dir, fmt.Sprintf("%d.sock", i)),
+		}
+		if err := p.startWorker(w); err != nil {
+			p.mu.Lock()
+			for _, started := range p.all {
+				started.kill()
+			}
+			p.all = nil
+			p.mu.Unlock()
+			p.removeDir(dir)
+			return nil, err
+		}
+		workers <- w
+	}
+	log.Info("Started ansible-runner workers", "count", p.size)
+	return workers, nil
+}
+
+func (p *workerPool) removeDir(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		log.Error(err, "Failed to remove ansible-runner worker directory", "dir", dir)
+	}
+}
+
+// run blocks until a worker is idle and runs j on it, returning the exit code of the job.
+// started is called once the job has started on the worker.
+func (p *workerPool) run(ctx context.Context, j workerJob, started func()) (int, error) {
+	workers, err := p.start()
+	if err != nil {
+		return 0, err
+	}
+	var w *worker
+	select {
+	case w = <-workers:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	defer func() { workers <- w }()
+
+	if !w.alive() {
+		log.Info("Restarting exited ansible-runner worker", "socket", w.socketPath)
+		if err := p.startWorker(w); err != nil {
+			return 0, err
+		}
+	}
+	return w.run(ctx, j, started)
+}
+
+// worker - a single ansible-runner worker process listening on a unix socket.
+type worker struct {
+	script     string
+	socketPath string
+	process    *os.Process
+	exited     chan struct{}
+	// added is set once the worker is added to the workers of its pool.
+	added bool
+}
+
+func (w *worker) start() error {
+	if err := os.Remove(w.socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	cmd := exec.Command(pythonInterpreter(), w.script, w.socketPath)
+	cmd.Env = os.Environ()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	w.process = cmd.Process
+	w.exited = exited
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Error(err, "Ansible-runner worker exited", "socket", w.socketPath)
+		}
+		close(exited)
+	}()
+
+	timeout := time.NewTimer(workerStartTimeout)
+	defer timeout.Stop()
+	// The socket exists once the worker has bound it, but connections are refused until it
+	// listens, so the worker is only ready once a connection succeeds.
+	for {
+		if conn, err := net.Dial("unix", w.socketPath); err == nil {
+			if err := conn.Close(); err != nil {
+				log.Error(err, "Failed to close ansible-runner worker connection")
+			}
+			return nil
+		}
+		select {
+		case <-exited:
+			return fmt.Errorf("ansible-runner worker exited during start up")
+		case <-timeout.C:
+			_ = cmd.Process.Kill()
+			return fmt.Errorf("timed out waiting for ansible-runner worker to listen on %s", w.socketPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (w *worker) kill() {
+	if w.process == nil || !w.alive() {
+		return
+	}
+	if err := w.process.Kill(); err != nil {
+		log.Error(err, "Failed to kill ansible-runner worker", "socket", w.socketPath)
+	}
+}
+
+func (w *worker) alive() bool {
+	select {
+	case <-w.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+func (w *worker) run(ctx context.Context, j workerJob, started func()) (int, error) {
+	conn, err := net.Dial("unix", w.socketPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Error(err, "Failed to close ansible-runner worker connection")
+		}
+	}()
+
+	if err := json.NewEncoder(conn).Encode(j); err != nil {
+		return 0, err
+	}
+	// Each job runs in its own process group, which is terminated when ctx is done.
+	finished := make(chan struct{})
+	defer close(finished)
+
+	dec := json.NewDecoder(conn)
+	for {
+		reply := workerReply{}
+		if err := dec.Decode(&reply); err != nil {
+			return 0, fmt.Errorf("lost connection to ansible-runner worker: %w", err)
+		}
+		if reply.Error != "" {
+			return 0, errors.New(reply.Error)
+		}
+		if reply.Done {
+			return reply.RC, nil
+		}
+		log.V(1).Info("Job started on ansible-runner worker", "job", j.Ident, "pid", reply.PID)
+		started()
+		go func(pgid int) {
+			select {
+			case <-ctx.Done():
+				log.Info("Terminating ansible-runner worker job", "job", j.Ident, "reason", ctx.Err().Error())
+				terminateProcessGroup(pgid, finished)
+			case <-finished:
+			}
+		}(reply.PID)
+	}
+}
+
+// pythonInterpreter returns the python interpreter used to run workers, preferring the one
+// of an active virtual environment like the generated inventory does.
+func pythonInterpreter() string {
+	if venv := os.Getenv("VIRTUAL_ENV"); venv != "" {
+		return filepath.Join(venv, "bin", "python3")
+	}
+	return "python3"
+}
@@ -0,0 +1,173 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// listenScript binds the socket passed as the second argument, like a worker does, but
+// only listens on it after a while.
+const listenScript = `import socket, sys, time
+s = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+s.bind(sys.argv[2])
+time.sleep(0.5)
+s.listen(1)
+while True:
+    s.accept()[0].close()
+`
+
+// jobScript serves a single job like a worker does, reporting it as running for a while.
+const jobScript = `import json, socket, sys, time
+s = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+s.bind(sys.argv[2])
+s.listen(1)
+while True:
+    conn = s.accept()[0]
+    stream = conn.makefile("rwb")
+    if not stream.readline():
+        conn.close()
+        continue
+    stream.write(b'{"pid": 1}\n')
+    stream.flush()
+    time.sleep(0.5)
+    stream.write(b'{"rc": 0, "done": true}\n')
+    stream.flush()
+    conn.close()
+`
+
+// fakeInterpreter makes workers run the python script instead of the worker script, or
+// exit if it is empty, until restore is called.
+func fakeInterpreter(t *testing.T) (set func(script string), restore func()) {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	venv, err := ioutil.TempDir("", "venv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(venv, "bin"), 0700); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	interpreter := filepath.Join(venv, "bin", "python3")
+	fake := filepath.Join(venv, "fake.py")
+	set = func(script string) {
+		t.Helper()
+		wrapper := fmt.Sprintf("#!/bin/sh\nexec %s %s \"$@\"\n", python, fake)
+		if script == "" {
+			wrapper = "#!/bin/sh\nexit 1\n"
+		}
+		if err := ioutil.WriteFile(fake, []byte(script), 0600); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(interpreter, []byte(wrapper), 0700); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	oldVenv, hadVenv := os.LookupEnv("VIRTUAL_ENV")
+	os.Setenv("VIRTUAL_ENV", venv)
+	return set, func() {
+		if hadVenv {
+			os.Setenv("VIRTUAL_ENV", oldVenv)
+		} else {
+			os.Unsetenv("VIRTUAL_ENV")
+		}
+		os.RemoveAll(venv)
+	}
+}
+
+func TestWorkerPoolStart(t *testing.T) {
+	setInterpreter, restore := fakeInterpreter(t)
+	defer restore()
+
+	p := newWorkerPool(2)
+	defer p.close()
+
+	// A failed start is not cached, and leaves no workers behind.
+	setInterpreter("")
+	if _, err := p.start(); err == nil {
+		t.Fatalf("Expected error starting workers that exit")
+	}
+	if len(p.all) != 0 {
+		t.Fatalf("Unexpected %d workers after a failed start", len(p.all))
+	}
+
+	// The workers are only ready once they accept connections.
+	setInterpreter(listenScript)
+	workers, err := p.start()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(p.all) != 2 || len(workers) != 2 {
+		t.Fatalf("Unexpected workers %d, idle %d, expected 2", len(p.all), len(workers))
+	}
+	for _, w := range p.all {
+		conn, err := net.Dial("unix", w.socketPath)
+		if err != nil {
+			t.Fatalf("Worker does not accept connections: %v", err)
+		}
+		conn.Close()
+	}
+}
+
+func TestPoolRunnerMetrics(t *testing.T) {
+	setInterpreter, restore := fakeInterpreter(t)
+	defer restore()
+	setInterpreter(jobScript)
+
+	gvk := schema.GroupVersionKind{Group: "pool.example.com", Version: "v1", Kind: "Pool"}
+	r := &poolRunner{
+		runner: &runner{GVK: gvk},
+		pool:   newWorkerPool(1),
+		target: jobTarget{Playbook: "playbook.yml"},
+	}
+	defer r.Close()
+	inFlight := func() float64 {
+		m := gatherMetric(t, "ansible_operator_runners_in_flight", map[string]string{"GVK": gvk.String()})
+		if m == nil {
+			return 0
+		}
+		return m.GetGauge().GetValue()
+	}
+
+	executed := make(chan error, 1)
+	go func() {
+		executed <- r.execute(context.TODO(), log, "job", "/tmp/job", 1, 0, "/kubeconfig", -1)
+	}()
+	for i := 0; i < 500 && inFlight() != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := inFlight(); v != 1 {
+		t.Fatalf("Expected the running job to be counted as a runner, got %v", v)
+	}
+	if err := <-executed; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v := inFlight(); v != 0 {
+		t.Fatalf("Expected no runners once the job exited, got %v", v)
+	}
+}
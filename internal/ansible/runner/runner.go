@@ -15,6 +15,8 @@
 package runner
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -23,11 +25,15 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
+	"github.com/operator-framework/operator-sdk/internal/ansible/artifacts"
 	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
 	"github.com/operator-framework/operator-sdk/internal/ansible/paramconv"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
@@ -49,14 +55,28 @@ const (
 	// Example usage "ansible.sdk.operatorframework.io/verbosity: 5"
 	AnsibleVerbosityAnnotation = "ansible.sdk.operatorframework.io/verbosity"
 
+	// CheckModeAnnotation - annotation used by a user to run Ansible in check mode for a CR,
+	// i.e. with "--check --diff", so that its runs report the changes they would make
+	// instead of making them. The runs of finalizers are never in check mode.
+	// Example usage "ansible.sdk.operatorframework.io/check-mode: true"
+	CheckModeAnnotation = "ansible.sdk.operatorframework.io/check-mode"
+
 	ansibleRunnerBin = "ansible-runner"
+
+	// terminationGracePeriod is how long ansible-runner may take to exit after being
+	// asked to terminate, before it is killed.
+	terminationGracePeriod = 10 * time.Second
+
+	// artifactStoreTimeout is how long storing the artifacts of a run may take.
+	artifactStoreTimeout = 2 * time.Minute
 )
 
 // Runner - a runnable that should take the parameters and name and namespace
-// and run the correct code.
+// and run the correct code. The run is terminated when the context is done.
 type Runner interface {
-	Run(string, *unstructured.Unstructured, string) (RunResult, error)
-	GetFinalizer() (string, bool)
+	Run(context.Context, string, *unstructured.Unstructured, string) (RunResult, error)
+	// GetFinalizers returns the finalizer stages of the watch, in the order they run.
+	GetFinalizers() []watches.Finalizer
 }
 
 // ansibleVerbosityString will return the string with the -v* levels
@@ -119,10 +139,13 @@ func roleCmdFunc(path string) cmdFuncType {
 	}
 }
 
-// New - creates a Runner from a Watch struct
-func New(watch watches.Watch, runnerArgs string) (Runner, error) {
+// New - creates a Runner from a Watch struct. The artifacts of its runs are stored in sink
+// if it is not nil, the varsFrom of the watch are read as varsFrom configures, and its
+// runs wait for their share of budget, which is shared by the runners of all watches.
+func New(watch watches.Watch, runnerArgs string, sink artifacts.Sink, varsFrom VarsFromOptions,
+	budget *RunBudget) (Runner, error) {
 	var path string
-	var cmdFunc, finalizerCmdFunc cmdFuncType
+	var cmdFunc cmdFuncType
 
 	err := watch.Validate()
 	if err != nil {
@@ -139,58 +162,110 @@ func New(watch watches.Watch, runnerArgs string) (Runner, error) {
 		cmdFunc = roleCmdFunc(path)
 	}
 
-	// handle finalizer
-	switch {
-	case watch.Finalizer == nil:
-		finalizerCmdFunc = nil
-	case watch.Finalizer.Playbook != "":
-		finalizerCmdFunc = playbookCmdFunc(watch.Finalizer.Playbook)
-	case watch.Finalizer.Role != "":
-		finalizerCmdFunc = roleCmdFunc(watch.Finalizer.Role)
-	default:
-		finalizerCmdFunc = cmdFunc
+	// handle finalizers, a stage without a playbook or role runs the one of the watch
+	finalizers := watch.FinalizerStages()
+	finalizerCmdFuncs := make([]cmdFuncType, len(finalizers))
+	for i, f := range finalizers {
+		switch {
+		case f.Playbook != "":
+			finalizerCmdFuncs[i] = playbookCmdFunc(f.Playbook)
+		case f.Role != "":
+			finalizerCmdFuncs[i] = roleCmdFunc(f.Role)
+		default:
+			finalizerCmdFuncs[i] = cmdFunc
+		}
+	}
+
+	priorityClass := watch.RunPriorityClass
+	if priorityClass == "" {
+		priorityClass = watches.RunPriorityClassNormal
 	}
 
-	return &runner{
+	r := &runner{
 		Path:                path,
 		cmdFunc:             cmdFunc,
 		Vars:                watch.Vars,
-		Finalizer:           watch.Finalizer,
-		finalizerCmdFunc:    finalizerCmdFunc,
+		VarsFrom:            watch.VarsFrom,
+		Finalizers:          finalizers,
+		finalizerCmdFuncs:   finalizerCmdFuncs,
 		GVK:                 watch.GroupVersionKind,
 		maxRunnerArtifacts:  watch.MaxRunnerArtifacts,
 		ansibleVerbosity:    watch.AnsibleVerbosity,
 		ansibleArgs:         runnerArgs,
 		snakeCaseParameters: watch.SnakeCaseParameters,
 		markUnsafe:          watch.MarkUnsafe,
-	}, nil
+		sink:                sink,
+		varsFrom:            varsFrom,
+		budget:              budget,
+		priorityClass:       priorityClass,
+	}
+
+	if varsFrom.OperatorNamespace == "" && readsOperatorNamespace(watch) {
+		err = errors.New("varsFrom of the operator namespace require the operator namespace, " +
+			"set the OPERATOR_NAMESPACE environment variable")
+		log.Error(err, "Failed to validate watch")
+		return nil, err
+	}
+
+	if watch.WorkerPool != nil {
+		return newPoolRunner(r, watch), nil
+	}
+	return r, nil
 }
 
 // runner - implements the Runner interface for a GVK that's being watched.
 type runner struct {
 	Path                string                  // path on disk to a playbook or role depending on what cmdFunc expects
 	GVK                 schema.GroupVersionKind // GVK being watched that corresponds to the Path
-	Finalizer           *watches.Finalizer
+	Finalizers          []*watches.Finalizer
 	Vars                map[string]interface{}
+	VarsFrom            []watches.VarsFrom
 	cmdFunc             cmdFuncType // returns a Cmd that runs ansible-runner
-	finalizerCmdFunc    cmdFuncType
+	finalizerCmdFuncs   []cmdFuncType
 	maxRunnerArtifacts  int
 	ansibleVerbosity    int
 	snakeCaseParameters bool
 	markUnsafe          bool
 	ansibleArgs         string
+	// admission is set for the runners of webhooks, whose runs are not reconciles and
+	// use an input directory of their own that is removed once the run has finished.
+	admission bool
+	// sink stores the artifacts of finished runs, unless it is nil.
+	sink artifacts.Sink
+	// varsFrom configures how the Secrets and ConfigMaps of VarsFrom are read.
+	varsFrom VarsFromOptions
+	// budget limits the runs of all runners that run at the same time, if set. The runs
+	// of webhooks are not limited, as admission requests wait for them.
+	budget *RunBudget
+	// priorityClass is the run priority class of the watch in budget.
+	priorityClass string
 }
 
-func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig string) (RunResult, error) {
+// executeFunc runs ansible-runner against the input directory at inputDirPath and blocks
+// until the run has finished. stage is the index of the finalizer stage to run, or -1 if
+// the run is not a finalizer run.
+type executeFunc func(ctx context.Context, logger logr.Logger, ident, inputDirPath string, maxArtifacts,
+	verbosity int, kubeconfig string, stage int) error
+
+func (r *runner) Run(ctx context.Context, ident string, u *unstructured.Unstructured, kubeconfig string) (RunResult, error) {
 	if _, err := exec.LookPath(ansibleRunnerBin); err != nil {
 		return nil, err
 	}
+	return r.run(ctx, ident, u, kubeconfig, r.execute)
+}
 
-	timer := metrics.ReconcileTimer(r.GVK.String())
-	defer timer.ObserveDuration()
+// run writes the input directory for u, starts the event receiver and hands the run over
+// to execute in the background.
+func (r *runner) run(ctx context.Context, ident string, u *unstructured.Unstructured, kubeconfig string,
+	execute executeFunc) (RunResult, error) {
 
-	if u.GetDeletionTimestamp() != nil && !r.isFinalizerRun(u) {
-		return nil, errors.New("resource has been deleted, but no finalizer was matched, skipping reconciliation")
+	if !r.admission {
+		timer := metrics.ReconcileTimer(r.GVK.String())
+		defer timer.ObserveDuration()
+
+		if u.GetDeletionTimestamp() != nil && !r.isFinalizerRun(u) {
+			return nil, errors.New("resource has been deleted, but no finalizer was matched, skipping reconciliation")
+		}
 	}
 	logger := log.WithValues(
 		"job", ident,
@@ -198,6 +273,11 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 		"namespace", u.GetNamespace(),
 	)
 
+	varsFrom, err := r.readVarsFrom(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
 	// start the event receiver. We'll check errChan for an error after
 	// ansible-runner exits.
 	errChan := make(chan error, 1)
@@ -206,9 +286,8 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 		return nil, err
 	}
 	inputDir := inputdir.InputDir{
-		Path: filepath.Join("/tmp/ansible-operator/runner/", r.GVK.Group, r.GVK.Version, r.GVK.Kind,
-			u.GetNamespace(), u.GetName()),
-		Parameters: r.makeParameters(u),
+		Path:       r.inputDirPath(ident, u),
+		Parameters: r.makeParameters(u, varsFrom),
 		EnvVars: map[string]string{
 			"K8S_AUTH_KUBECONFIG": kubeconfig,
 			"KUBECONFIG":          kubeconfig,
@@ -217,7 +296,8 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 			"runner_http_url":  receiver.SocketPath,
 			"runner_http_path": receiver.URLPath,
 		},
-		CmdLine: r.ansibleArgs,
+		CmdLine:   r.ansibleArgs,
+		CheckMode: !r.admission && IsCheckModeRun(u),
 	}
 	// If Path is a dir, assume it is a role path. Otherwise assume it's a
 	// playbook path
@@ -252,26 +332,24 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 		}
 	}
 
+	stage := r.finalizerStage(u)
 	go func() {
-		var dc *exec.Cmd
-		if r.isFinalizerRun(u) {
+		// The run waits for its share of the budget within its timeout.
+		release, err := r.budget.acquire(ctx, r.GVK, r.priorityClass)
+		if err != nil {
+			logger.Info("Ansible run was not started", "reason", err.Error())
+			receiver.Close()
+			<-errChan
+			return
+		}
+		if stage >= 0 {
 			logger.V(1).Info("Resource is marked for deletion, running finalizer",
-				"Finalizer", r.Finalizer.Name)
-			dc = r.finalizerCmdFunc(ident, inputDir.Path, maxArtifacts, verbosity)
-		} else {
-			dc = r.cmdFunc(ident, inputDir.Path, maxArtifacts, verbosity)
+				"Finalizer", r.Finalizers[stage].Name)
 		}
-		// Append current environment since setting dc.Env to anything other than nil overwrites current env
-		dc.Env = append(dc.Env, os.Environ()...)
-		dc.Env = append(dc.Env, fmt.Sprintf("K8S_AUTH_KUBECONFIG=%s", kubeconfig),
-			fmt.Sprintf("KUBECONFIG=%s", kubeconfig))
-
-		output, err := dc.CombinedOutput()
-		if err != nil {
-			logger.Error(err, string(output))
-		} else {
+		if err := execute(ctx, logger, ident, inputDir.Path, maxArtifacts, verbosity, kubeconfig, stage); err == nil {
 			logger.Info("Ansible-runner exited successfully")
 		}
+		release()
 
 		receiver.Close()
 		err = <-errChan
@@ -280,8 +358,17 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 			logger.Error(err, "Error from event API")
 		}
 
-		// link the current run to the `latest` directory under artifacts
+		if r.admission {
+			if err := os.RemoveAll(inputDir.Path); err != nil {
+				logger.Error(err, "Error removing the input directory")
+			}
+			return
+		}
+
 		currentRun := filepath.Join(inputDir.Path, "artifacts", ident)
+		r.storeArtifacts(logger, ident, u, currentRun)
+
+		// link the current run to the `latest` directory under artifacts
 		latestArtifacts := filepath.Join(inputDir.Path, "artifacts", "latest")
 		if _, err = os.Lstat(latestArtifacts); err != nil {
 			if !errors.Is(err, os.ErrNotExist) {
@@ -306,17 +393,135 @@ func (r *runner) Run(ident string, u *unstructured.Unstructured, kubeconfig stri
 	}, nil
 }
 
+// execute runs ansible-runner in a new process group, which is terminated when ctx is done.
+func (r *runner) execute(ctx context.Context, logger logr.Logger, ident, inputDirPath string, maxArtifacts,
+	verbosity int, kubeconfig string, stage int) error {
+	var dc *exec.Cmd
+	if stage >= 0 {
+		dc = r.finalizerCmdFuncs[stage](ident, inputDirPath, maxArtifacts, verbosity)
+	} else {
+		dc = r.cmdFunc(ident, inputDirPath, maxArtifacts, verbosity)
+	}
+	// Append current environment since setting dc.Env to anything other than nil overwrites current env
+	dc.Env = append(dc.Env, os.Environ()...)
+	dc.Env = append(dc.Env, fmt.Sprintf("K8S_AUTH_KUBECONFIG=%s", kubeconfig),
+		fmt.Sprintf("KUBECONFIG=%s", kubeconfig))
+
+	dc.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var output bytes.Buffer
+	dc.Stdout = &output
+	dc.Stderr = &output
+
+	if err := dc.Start(); err != nil {
+		logger.Error(err, "Failed to start ansible-runner")
+		return err
+	}
+	metrics.RunnerStarted(r.GVK.String())
+	defer metrics.RunnerExited(r.GVK.String())
+	waitErr := make(chan error, 1)
+	exited := make(chan struct{})
+	go func() {
+		waitErr <- dc.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		logger.Info("Terminating ansible-runner", "reason", ctx.Err().Error())
+		terminateProcessGroup(dc.Process.Pid, exited)
+	}
+
+	err := <-waitErr
+	if err != nil {
+		logger.Error(err, output.String())
+	}
+	return err
+}
+
+// terminateProcessGroup asks the process group pgid to terminate, which gives ansible-runner
+// the chance to stop the ansible-playbook process it started, and kills the group if it
+// has not exited after terminationGracePeriod.
+func terminateProcessGroup(pgid int, exited <-chan struct{}) {
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		log.Error(err, "Failed to terminate process group", "pgid", pgid)
+	}
+	select {
+	case <-exited:
+	case <-time.After(terminationGracePeriod):
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil {
+			log.Error(err, "Failed to kill process group", "pgid", pgid)
+		}
+		<-exited
+	}
+}
+
+// inputDirPath returns the path of the input directory of the run ident for u.
+func (r *runner) inputDirPath(ident string, u *unstructured.Unstructured) string {
+	if r.admission {
+		// Admission requests for the same object can be handled concurrently, and objects
+		// being created may not have a name yet.
+		return filepath.Join("/tmp/ansible-operator/webhook/", r.GVK.Group, r.GVK.Version, r.GVK.Kind, ident)
+	}
+	return filepath.Join("/tmp/ansible-operator/runner/", r.GVK.Group, r.GVK.Version, r.GVK.Kind,
+		u.GetNamespace(), u.GetName())
+}
+
+// IsCheckModeRun returns whether the run of u is in check mode, as requested by its
+// CheckModeAnnotation. The runs of resources being deleted are not.
+func IsCheckModeRun(u *unstructured.Unstructured) bool {
+	v, ok := u.GetAnnotations()[CheckModeAnnotation]
+	if !ok || u.GetDeletionTimestamp() != nil {
+		return false
+	}
+	checkMode, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Info("Invalid check mode annotation", "err", err, "value", v)
+		return false
+	}
+	return checkMode
+}
+
+// storeArtifacts stores the artifacts ansible-runner wrote to dir for the run of u in the
+// sink of r.
+func (r *runner) storeArtifacts(logger logr.Logger, ident string, u *unstructured.Unstructured, dir string) {
+	if r.sink == nil {
+		return
+	}
+	run := artifacts.Run{
+		Ident:     ident,
+		Group:     r.GVK.Group,
+		Version:   r.GVK.Version,
+		Kind:      r.GVK.Kind,
+		Namespace: u.GetNamespace(),
+		Name:      u.GetName(),
+		Status:    artifacts.ReadStatus(dir),
+		Finished:  time.Now(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), artifactStoreTimeout)
+	defer cancel()
+	if err := r.sink.Store(ctx, run, dir); err != nil {
+		logger.Error(err, "Error storing the artifacts of the run")
+	}
+}
+
 func (r *runner) isFinalizerRun(u *unstructured.Unstructured) bool {
-	finalizersSet := r.Finalizer != nil && u.GetFinalizers() != nil
-	// The resource is deleted and our finalizer is present, we need to run the finalizer
-	if finalizersSet && u.GetDeletionTimestamp() != nil {
-		for _, f := range u.GetFinalizers() {
-			if f == r.Finalizer.Name {
-				return true
+	return r.finalizerStage(u) >= 0
+}
+
+// finalizerStage returns the index of the finalizer stage to run for u, which is the first
+// stage whose finalizer is still present once u is deleted, or -1 if there is none.
+func (r *runner) finalizerStage(u *unstructured.Unstructured) int {
+	if u.GetDeletionTimestamp() == nil {
+		return -1
+	}
+	for i, f := range r.Finalizers {
+		for _, name := range u.GetFinalizers() {
+			if name == f.Name {
+				return i
 			}
 		}
 	}
-	return false
+	return -1
 }
 
 // makeParameters - creates the extravars parameters for ansible
@@ -327,7 +532,8 @@ func (r *runner) isFinalizerRun(u *unstructured.Unstructured) bool {
 //   },
 //   <cr_spec_fields_as_snake_case>,
 //   <watch vars>,
-//   <finalizer vars>,
+//   <vars of the finalizer stage>,
+//   <varsFrom>,
 //   _<group_as_snake>_<kind>: {
 //       <cr_object> as is
 //   }
@@ -335,7 +541,8 @@ func (r *runner) isFinalizerRun(u *unstructured.Unstructured) bool {
 //       <cr_object.spec> as is
 //   }
 // }
-func (r *runner) makeParameters(u *unstructured.Unstructured) map[string]interface{} {
+// The varsFrom, read from Secrets and ConfigMaps, are always marked unsafe.
+func (r *runner) makeParameters(u *unstructured.Unstructured, varsFrom map[string]interface{}) map[string]interface{} {
 	s := u.Object["spec"]
 	spec, ok := s.(map[string]interface{})
 	if !ok {
@@ -371,11 +578,14 @@ func (r *runner) makeParameters(u *unstructured.Unstructured) map[string]interfa
 	for k, v := range r.Vars {
 		parameters[k] = v
 	}
-	if r.isFinalizerRun(u) {
-		for k, v := range r.Finalizer.Vars {
+	if stage := r.finalizerStage(u); stage >= 0 {
+		for k, v := range r.Finalizers[stage].Vars {
 			parameters[k] = v
 		}
 	}
+	for k, v := range varsFrom {
+		parameters[k] = markUnsafe(v)
+	}
 	return parameters
 }
 
@@ -417,11 +627,12 @@ func escapeAnsibleKey(key string) string {
 	return key
 }
 
-func (r *runner) GetFinalizer() (string, bool) {
-	if r.Finalizer != nil {
-		return r.Finalizer.Name, true
+func (r *runner) GetFinalizers() []watches.Finalizer {
+	finalizers := make([]watches.Finalizer, 0, len(r.Finalizers))
+	for _, f := range r.Finalizers {
+		finalizers = append(finalizers, *f)
 	}
-	return "", false
+	return finalizers
 }
 
 // RunResult - result of a ansible run
@@ -15,15 +15,23 @@
 package runner
 
 import (
+	"context"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
+	"syscall"
 	"testing"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/operator-framework/operator-sdk/internal/ansible/artifacts"
 	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
 )
 
@@ -159,7 +167,7 @@ func TestNew(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			testWatch := watches.New(tc.gvk, tc.role, tc.playbook, tc.vars, tc.finalizer)
 
-			testRunner, err := New(*testWatch, "")
+			testRunner, err := New(*testWatch, "", nil, VarsFromOptions{}, nil)
 			if err != nil {
 				t.Fatalf("Error occurred unexpectedly: %v", err)
 			}
@@ -181,7 +189,7 @@ func TestNew(t *testing.T) {
 
 			// check that the group + kind are properly formatted into a parameter
 			if tc.desiredObjectKey != "" {
-				parameters := testRunnerStruct.makeParameters(&unstructured.Unstructured{})
+				parameters := testRunnerStruct.makeParameters(&unstructured.Unstructured{}, nil)
 				if _, ok := parameters[tc.desiredObjectKey]; !ok {
 					t.Fatalf("Did not find expected objKey %v in parameters %+v", tc.desiredObjectKey, parameters)
 				}
@@ -201,15 +209,15 @@ func TestNew(t *testing.T) {
 			checkCmdFunc(t, testRunnerStruct.cmdFunc, testWatch.Playbook, testWatch.Role, testWatch.AnsibleVerbosity)
 
 			// Check finalizer
-			if testRunnerStruct.Finalizer != testWatch.Finalizer {
-				t.Fatalf("Unexpected finalizer %v expected finalizer %v", testRunnerStruct.Finalizer,
-					testWatch.Finalizer)
+			if !reflect.DeepEqual(testRunnerStruct.Finalizers, testWatch.FinalizerStages()) {
+				t.Fatalf("Unexpected finalizers %v expected finalizers %v", testRunnerStruct.Finalizers,
+					testWatch.FinalizerStages())
 			}
 
 			if testWatch.Finalizer != nil {
-				if testRunnerStruct.Finalizer.Name != testWatch.Finalizer.Name {
+				if testRunnerStruct.Finalizers[0].Name != testWatch.Finalizer.Name {
 					t.Fatalf("Unexpected finalizer name %v expected finalizer name %v",
-						testRunnerStruct.Finalizer.Name, testWatch.Finalizer.Name)
+						testRunnerStruct.Finalizers[0].Name, testWatch.Finalizer.Name)
 				}
 
 				if len(testWatch.Finalizer.Vars) == 0 {
@@ -217,7 +225,7 @@ func TestNew(t *testing.T) {
 						testWatch.AnsibleVerbosity)
 				} else {
 					// when finalizer vars is set the finalizerCmdFunc should be the same as the cmdFunc
-					checkCmdFunc(t, testRunnerStruct.finalizerCmdFunc, testWatch.Playbook, testWatch.Role,
+					checkCmdFunc(t, testRunnerStruct.finalizerCmdFuncs[0], testWatch.Playbook, testWatch.Role,
 						testWatch.AnsibleVerbosity)
 				}
 			}
@@ -245,6 +253,224 @@ func TestAnsibleVerbosityString(t *testing.T) {
 	}
 }
 
+func TestIsCheckModeRun(t *testing.T) {
+	now := metav1.Now()
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		deleted     bool
+		expected    bool
+	}{
+		{name: "no annotation"},
+		{name: "check mode", annotations: map[string]string{CheckModeAnnotation: "true"}, expected: true},
+		{name: "check mode disabled", annotations: map[string]string{CheckModeAnnotation: "false"}},
+		{name: "invalid annotation", annotations: map[string]string{CheckModeAnnotation: "yes please"}},
+		{name: "finalizer run", annotations: map[string]string{CheckModeAnnotation: "true"}, deleted: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{}
+			u.SetAnnotations(tc.annotations)
+			if tc.deleted {
+				u.SetDeletionTimestamp(&now)
+			}
+			if got := IsCheckModeRun(u); got != tc.expected {
+				t.Fatalf("Unexpected check mode %v, expected %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFinalizerStage(t *testing.T) {
+	now := metav1.Now()
+	r := &runner{Finalizers: []*watches.Finalizer{
+		{Name: "example.com/drain", Vars: map[string]interface{}{"stage": "drain"}},
+		{Name: "example.com/backup", Vars: map[string]interface{}{"stage": "backup"}},
+		{Name: "example.com/delete", Vars: map[string]interface{}{"stage": "delete"}},
+	}}
+	testCases := []struct {
+		name       string
+		finalizers []string
+		deleted    bool
+		expected   int
+	}{
+		{name: "not deleted", finalizers: []string{"example.com/drain"}, expected: -1},
+		{name: "first stage", finalizers: []string{"example.com/delete", "example.com/drain"}, deleted: true,
+			expected: 0},
+		{name: "later stage", finalizers: []string{"other", "example.com/delete", "example.com/backup"},
+			deleted: true, expected: 1},
+		{name: "no stage left", finalizers: []string{"other"}, deleted: true, expected: -1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			u.SetFinalizers(tc.finalizers)
+			if tc.deleted {
+				u.SetDeletionTimestamp(&now)
+			}
+			if got := r.finalizerStage(u); got != tc.expected {
+				t.Fatalf("Unexpected finalizer stage %v, expected %v", got, tc.expected)
+			}
+			parameters := r.makeParameters(u, nil)
+			if tc.expected < 0 {
+				if _, ok := parameters["stage"]; ok {
+					t.Fatalf("Unexpected finalizer vars in parameters %+v", parameters)
+				}
+			} else if parameters["stage"] != r.Finalizers[tc.expected].Vars["stage"] {
+				t.Fatalf("Unexpected stage %v in parameters, expected %v", parameters["stage"],
+					r.Finalizers[tc.expected].Vars["stage"])
+			}
+		})
+	}
+}
+
+type recordingSink struct {
+	runs []artifacts.Run
+	dirs []string
+}
+
+func (s *recordingSink) Store(_ context.Context, run artifacts.Run, dir string) error {
+	s.runs = append(s.runs, run)
+	s.dirs = append(s.dirs, dir)
+	return nil
+}
+
+func TestStoreArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "status"), []byte("failed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	u := &unstructured.Unstructured{}
+	u.SetNamespace("default")
+	u.SetName("example")
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+
+	// Runners without a sink store nothing.
+	(&runner{GVK: gvk}).storeArtifacts(log, "1", u, dir)
+
+	sink := &recordingSink{}
+	(&runner{GVK: gvk, sink: sink}).storeArtifacts(log, "2", u, dir)
+	if len(sink.runs) != 1 || sink.dirs[0] != dir {
+		t.Fatalf("Expected the artifacts of one run to be stored, got %+v", sink.runs)
+	}
+	run := sink.runs[0]
+	if run.Ident != "2" || run.Path() != "cache.example.com/v1alpha1/Memcached/default/example/2" ||
+		run.Status != "failed" || run.Finished.IsZero() {
+		t.Fatalf("Unexpected run %+v", run)
+	}
+}
+
+func TestReadVarsFrom(t *testing.T) {
+	reader := fake.NewClientBuilder().WithObjects(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "operator", Name: "db-credentials"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "settings"},
+			Data:       map[string]string{"size": "3"},
+		},
+	).Build()
+	options := VarsFromOptions{Reader: reader, OperatorNamespace: "operator"}
+	secret := watches.VarsFrom{SecretRef: &watches.ObjectRef{Name: "db-credentials"},
+		Namespace: watches.VarsFromOperatorNamespace, Prefix: "db_"}
+	configMap := watches.VarsFrom{ConfigMapRef: &watches.ObjectRef{Name: "settings"},
+		Namespace: watches.VarsFromCRNamespace}
+	missing := watches.VarsFrom{ConfigMapRef: &watches.ObjectRef{Name: "missing"},
+		Namespace: watches.VarsFromOperatorNamespace}
+	optional := missing
+	optional.Optional = true
+
+	now := metav1.Now()
+	testCases := []struct {
+		name      string
+		runner    runner
+		namespace string
+		deleted   bool
+		expected  map[string]interface{}
+		shouldErr bool
+	}{
+		{
+			name:     "no varsFrom",
+			runner:   runner{},
+			expected: nil,
+		},
+		{
+			name:      "secret in operator namespace and configmap in CR namespace",
+			runner:    runner{VarsFrom: []watches.VarsFrom{secret, configMap}, varsFrom: options},
+			namespace: "default",
+			expected:  map[string]interface{}{"db_password": "s3cr3t", "size": "3"},
+		},
+		{
+			name:      "configmap in namespace of cluster-scoped CR",
+			runner:    runner{VarsFrom: []watches.VarsFrom{configMap}, varsFrom: options},
+			shouldErr: true,
+		},
+		{
+			name:      "missing object",
+			runner:    runner{VarsFrom: []watches.VarsFrom{missing}, varsFrom: options},
+			namespace: "default",
+			shouldErr: true,
+		},
+		{
+			name:      "missing optional object",
+			runner:    runner{VarsFrom: []watches.VarsFrom{optional}, varsFrom: options},
+			namespace: "default",
+			expected:  map[string]interface{}{},
+		},
+		{
+			name:      "no reader",
+			runner:    runner{VarsFrom: []watches.VarsFrom{secret}},
+			namespace: "default",
+			shouldErr: true,
+		},
+		{
+			name: "finalizer varsFrom",
+			runner: runner{VarsFrom: []watches.VarsFrom{secret}, varsFrom: options,
+				Finalizers: []*watches.Finalizer{{Name: "finalizer", VarsFrom: []watches.VarsFrom{configMap}}}},
+			namespace: "default",
+			deleted:   true,
+			expected:  map[string]interface{}{"db_password": "s3cr3t", "size": "3"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{}
+			u.SetNamespace(tc.namespace)
+			u.SetName("example")
+			if tc.deleted {
+				u.SetDeletionTimestamp(&now)
+				u.SetFinalizers([]string{"finalizer"})
+			}
+			vars, err := tc.runner.readVarsFrom(context.TODO(), u)
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatalf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(vars, tc.expected) {
+				t.Fatalf("Unexpected vars %v, expected %v", vars, tc.expected)
+			}
+		})
+	}
+
+	// The varsFrom are marked unsafe even if the watch does not mark the spec unsafe.
+	parameters := (&runner{}).makeParameters(&unstructured.Unstructured{}, map[string]interface{}{"db_password": "s3cr3t"})
+	expected := map[string]interface{}{"__ansible_unsafe": "s3cr3t"}
+	if !reflect.DeepEqual(parameters["db_password"], expected) {
+		t.Fatalf("Unexpected parameter %v, expected %v", parameters["db_password"], expected)
+	}
+}
+
 func TestMakeParameters(t *testing.T) {
 	var (
 		inputSpec string = "testKey"
@@ -358,7 +584,7 @@ func TestMakeParameters(t *testing.T) {
 		testRunner := runner{
 			markUnsafe: true,
 		}
-		parameters := testRunner.makeParameters(&tc.inputParams)
+		parameters := testRunner.makeParameters(&tc.inputParams, nil)
 
 		val, ok := parameters[inputSpec]
 		if !ok {
@@ -371,3 +597,97 @@ func TestMakeParameters(t *testing.T) {
 		}
 	}
 }
+
+func TestNewPoolRunner(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unable to get working director: %v", err)
+	}
+	validPlaybook := filepath.Join(cwd, "testdata", "playbook.yml")
+	validRole := filepath.Join(cwd, "testdata", "roles", "role")
+	gvk := schema.GroupVersionKind{
+		Group:   "operator.example.com",
+		Version: "v1alpha1",
+		Kind:    "Example",
+	}
+	testCases := []struct {
+		name              string
+		playbook          string
+		role              string
+		finalizer         *watches.Finalizer
+		expectedJob       workerJob
+		expectedFinalizer workerJob
+	}{
+		{
+			name:     "playbook with vars finalizer",
+			playbook: validPlaybook,
+			finalizer: &watches.Finalizer{
+				Name: "operator.example.com/finalizer",
+				Vars: map[string]interface{}{"state": "absent"},
+			},
+			expectedJob:       workerJob{Playbook: validPlaybook},
+			expectedFinalizer: workerJob{Playbook: validPlaybook},
+		},
+		{
+			name: "role with playbook finalizer",
+			role: validRole,
+			finalizer: &watches.Finalizer{
+				Name:     "operator.example.com/finalizer",
+				Playbook: validPlaybook,
+			},
+			expectedJob:       workerJob{Role: "role", RolesPath: filepath.Join(cwd, "testdata", "roles") + "/"},
+			expectedFinalizer: workerJob{Playbook: validPlaybook},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testWatch := watches.New(gvk, tc.role, tc.playbook, nil, tc.finalizer)
+			testWatch.WorkerPool = &watches.WorkerPool{Size: 2}
+
+			testRunner, err := New(*testWatch, "", nil, VarsFromOptions{}, nil)
+			if err != nil {
+				t.Fatalf("Error occurred unexpectedly: %v", err)
+			}
+			testPoolRunner, ok := testRunner.(*poolRunner)
+			if !ok {
+				t.Fatalf("Unexpected runner type %T", testRunner)
+			}
+			if testPoolRunner.pool.size != 2 {
+				t.Fatalf("Unexpected pool size %v expected 2", testPoolRunner.pool.size)
+			}
+
+			for stage, expected := range map[int]workerJob{-1: tc.expectedJob, 0: tc.expectedFinalizer} {
+				job := testPoolRunner.newJob("test", "/test/path", 1, 2, "/kubeconfig", stage)
+				if job.Playbook != expected.Playbook || job.Role != expected.Role ||
+					job.RolesPath != expected.RolesPath {
+					t.Fatalf("Unexpected job (finalizer stage: %v) %+v expected %+v", stage, job, expected)
+				}
+				if job.Ident != "test" || job.PrivateDataDir != "/test/path" || job.RotateArtifacts != 1 ||
+					job.Verbosity != 2 || job.Env["KUBECONFIG"] != "/kubeconfig" {
+					t.Fatalf("Unexpected job parameters %+v", job)
+				}
+			}
+		})
+	}
+}
+
+func TestTerminateProcessGroup(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "sleep 60 & wait")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	terminateProcessGroup(cmd.Process.Pid, exited)
+	select {
+	case <-exited:
+	case <-time.After(terminationGracePeriod + 5*time.Second):
+		t.Fatalf("Process group %d was not terminated", cmd.Process.Pid)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+// Results of the tasks of Ansible runs.
+const (
+	TaskResultOk          = "ok"
+	TaskResultChanged     = "changed"
+	TaskResultFailed      = "failed"
+	TaskResultIgnored     = "ignored"
+	TaskResultSkipped     = "skipped"
+	TaskResultUnreachable = "unreachable"
+)
+
+const (
+	// maxTaskNameLength is the number of characters task names are truncated to in the
+	// labels of metrics.
+	maxTaskNameLength = 64
+	// maxTaskNames is the number of distinct task names of a GVK used in the labels of
+	// metrics. The durations of the tasks with other names are recorded as OtherTaskName, so
+	// that templated task names cannot make the number of time series unbounded.
+	maxTaskNames = 200
+	// OtherTaskName is the task label of the tasks beyond maxTaskNames.
+	OtherTaskName = "other"
+)
+
+// taskNames - the task names used in the labels of metrics, by GVK.
+type taskNames struct {
+	mu    sync.Mutex
+	max   int
+	names map[string]map[string]bool
+}
+
+var labelledTaskNames = &taskNames{max: maxTaskNames, names: map[string]map[string]bool{}}
+
+// label returns the label of the task name of a task of gvk: the name truncated to
+// maxTaskNameLength characters, or OtherTaskName once max names of gvk are labelled.
+func (n *taskNames) label(gvk, task string) string {
+	if runes := []rune(task); len(runes) > maxTaskNameLength {
+		task = string(runes[:maxTaskNameLength])
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	names := n.names[gvk]
+	if names == nil {
+		names = map[string]bool{}
+		n.names[gvk] = names
+	}
+	if !names[task] {
+		if len(names) >= n.max {
+			return OtherTaskName
+		}
+		names[task] = true
+	}
+	return task
+}
+
+// TaskMetrics - records the results and durations of the tasks of an Ansible run from
+// its events. A TaskMetrics must only be used for the events of a single run.
+type TaskMetrics struct {
+	gvk string
+	// starts are the times the running tasks started, by task uuid.
+	starts map[string]time.Time
+}
+
+// NewTaskMetrics returns a TaskMetrics for a run for a CR of gvk.
+func NewTaskMetrics(gvk schema.GroupVersionKind) *TaskMetrics {
+	return &TaskMetrics{gvk: gvk.String(), starts: map[string]time.Time{}}
+}
+
+// Observe records the metrics of the task that event reports the start or end of.
+func (t *TaskMetrics) Observe(event eventapi.JobEvent) {
+	if event.Event == eventapi.EventPlaybookOnTaskStart {
+		t.starts[event.TaskUUID()] = event.Created.Time
+		return
+	}
+	result, ok := TaskResult(event)
+	if !ok {
+		return
+	}
+	metrics.TaskFinished(t.gvk, result)
+	if result == TaskResultSkipped {
+		return
+	}
+
+	duration, ok := event.Duration()
+	if !ok {
+		// Older versions of ansible-runner do not report durations.
+		start, started := t.starts[event.TaskUUID()]
+		if !started || start.IsZero() || event.Created.Time.IsZero() {
+			return
+		}
+		duration = event.Created.Time.Sub(start)
+	}
+	metrics.ObserveTaskDuration(t.gvk, event.Role(), labelledTaskNames.label(t.gvk, event.Task()),
+		duration.Seconds())
+}
+
+// TaskResult returns the result of the task that event reports the end of, and whether
+// event reports the end of a task.
+func TaskResult(event eventapi.JobEvent) (string, bool) {
+	switch event.Event {
+	case eventapi.EventRunnerOnOk:
+		if event.Changed() {
+			return TaskResultChanged, true
+		}
+		return TaskResultOk, true
+	case eventapi.EventRunnerOnFailed:
+		if event.IgnoreError() {
+			return TaskResultIgnored, true
+		}
+		return TaskResultFailed, true
+	case eventapi.EventRunnerOnSkipped:
+		return TaskResultSkipped, true
+	case eventapi.EventRunnerOnUnreachable:
+		return TaskResultUnreachable, true
+	}
+	return "", false
+}
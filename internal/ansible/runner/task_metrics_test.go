@@ -0,0 +1,131 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+// gatherMetric returns the metric name with labels from the controller-runtime registry.
+func gatherMetric(t *testing.T, name string, labels map[string]string) *dto.Metric {
+	families, err := crmetrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if v, ok := labels[label.GetName()]; ok && v != label.GetValue() {
+					continue metrics
+				}
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+func TestTaskMetrics(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "tasks.example.com", Version: "v1", Kind: "Tasks"}
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	event := func(name, uuid, task string, created time.Time, data map[string]interface{}) eventapi.JobEvent {
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		data["task_uuid"] = uuid
+		data["task"] = task
+		data["role"] = "memcached"
+		return eventapi.JobEvent{Event: name, EventData: data, Created: eventapi.EventTime{Time: created}}
+	}
+
+	tm := NewTaskMetrics(gvk)
+	for _, e := range []eventapi.JobEvent{
+		event(eventapi.EventPlaybookOnTaskStart, "1", "create deployment", start, nil),
+		event(eventapi.EventRunnerOnOk, "1", "create deployment", start.Add(3*time.Second),
+			map[string]interface{}{"res": map[string]interface{}{"changed": true}}),
+		event(eventapi.EventPlaybookOnTaskStart, "2", "wait", start, nil),
+		event(eventapi.EventRunnerOnFailed, "2", "wait", start.Add(time.Minute),
+			map[string]interface{}{"duration": 30.0}),
+		event(eventapi.EventRunnerOnFailed, "3", "check", start, map[string]interface{}{"ignore_errors": true}),
+		event(eventapi.EventRunnerOnSkipped, "4", "optional", start, nil),
+	} {
+		tm.Observe(e)
+	}
+
+	for result, expected := range map[string]float64{
+		TaskResultChanged: 1,
+		TaskResultFailed:  1,
+		TaskResultIgnored: 1,
+		TaskResultSkipped: 1,
+	} {
+		m := gatherMetric(t, "ansible_operator_task_results_total", map[string]string{"GVK": gvk.String(),
+			"result": result})
+		if m == nil || m.GetCounter().GetValue() != expected {
+			t.Fatalf("Unexpected count of %s tasks: %v", result, m)
+		}
+	}
+
+	for task, expected := range map[string]float64{
+		// Computed from the start event.
+		"create deployment": 3,
+		// Reported by ansible-runner.
+		"wait": 30,
+	} {
+		m := gatherMetric(t, "ansible_operator_task_duration_seconds", map[string]string{"GVK": gvk.String(),
+			"role": "memcached", "task": task})
+		if m == nil || m.GetHistogram().GetSampleSum() != expected {
+			t.Fatalf("Unexpected duration of task %s: %v", task, m)
+		}
+	}
+	if m := gatherMetric(t, "ansible_operator_task_duration_seconds", map[string]string{"GVK": gvk.String(),
+		"task": "optional"}); m != nil {
+		t.Fatalf("Expected no duration for skipped task, got %v", m)
+	}
+}
+
+func TestTaskNameLabels(t *testing.T) {
+	names := &taskNames{max: 2, names: map[string]map[string]bool{}}
+	long := strings.Repeat("é", maxTaskNameLength+10)
+	testCases := []struct {
+		gvk      string
+		task     string
+		expected string
+	}{
+		{gvk: "a", task: "create deployment", expected: "create deployment"},
+		{gvk: "a", task: long, expected: strings.Repeat("é", maxTaskNameLength)},
+		// Names used before keep their label.
+		{gvk: "a", task: "create deployment", expected: "create deployment"},
+		{gvk: "a", task: "wait for pod-1234", expected: OtherTaskName},
+		// Every GVK has names of its own.
+		{gvk: "b", task: "wait for pod-1234", expected: "wait for pod-1234"},
+	}
+	for _, tc := range testCases {
+		if actual := names.label(tc.gvk, tc.task); actual != tc.expected {
+			t.Fatalf("Unexpected label %q of task %q of %s, expected %q", actual, tc.task, tc.gvk, tc.expected)
+		}
+	}
+}
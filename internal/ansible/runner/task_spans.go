@@ -0,0 +1,78 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/tracing"
+)
+
+// TaskSpans - traces the tasks of an Ansible run from its events, as children of the span
+// of ctx. The spans start and end at the times of the events of the tasks. A TaskSpans
+// must only be used for the events of a single run.
+type TaskSpans struct {
+	ctx    context.Context
+	tracer trace.Tracer
+	// starts are the times the running tasks started, by task uuid.
+	starts map[string]time.Time
+}
+
+// NewTaskSpans returns a TaskSpans for a run traced by the span of ctx.
+func NewTaskSpans(ctx context.Context) *TaskSpans {
+	return &TaskSpans{ctx: ctx, tracer: tracing.Tracer(), starts: map[string]time.Time{}}
+}
+
+// Observe records the span of the task that event reports the end of.
+func (t *TaskSpans) Observe(event eventapi.JobEvent) {
+	if event.Event == eventapi.EventPlaybookOnTaskStart {
+		t.starts[event.TaskUUID()] = event.Created.Time
+		return
+	}
+	result, ok := TaskResult(event)
+	if !ok || event.Created.Time.IsZero() {
+		return
+	}
+
+	end := event.Created.Time
+	start, started := t.starts[event.TaskUUID()]
+	if duration, ok := event.Duration(); ok {
+		start = end.Add(-duration)
+	} else if !started {
+		start = end
+	}
+	name := event.Task()
+	if name == "" {
+		name = "task"
+	}
+	_, span := t.tracer.Start(t.ctx, name, trace.WithTimestamp(start), trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			tracing.TaskKey.String(event.Task()),
+			tracing.RoleKey.String(event.Role()),
+			tracing.TaskResultKey.String(result),
+		))
+	if host, ok := event.EventData["host"].(string); ok {
+		span.SetAttributes(tracing.HostKey.String(host))
+	}
+	if result == TaskResultFailed || result == TaskResultUnreachable {
+		span.SetStatus(codes.Error, event.GetFailedPlaybookMessage())
+	}
+	span.End(trace.WithTimestamp(end))
+}
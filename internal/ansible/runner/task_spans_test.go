@@ -0,0 +1,87 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/tracing"
+)
+
+func TestTaskSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	event := func(name, uuid, task string, created time.Time, data map[string]interface{}) eventapi.JobEvent {
+		if data == nil {
+			data = map[string]interface{}{}
+		}
+		data["task_uuid"] = uuid
+		data["task"] = task
+		data["host"] = "localhost"
+		return eventapi.JobEvent{Event: name, EventData: data, Created: eventapi.EventTime{Time: created}}
+	}
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "Reconcile")
+	ts := NewTaskSpans(ctx)
+	for _, e := range []eventapi.JobEvent{
+		event(eventapi.EventPlaybookOnTaskStart, "1", "create deployment", start, nil),
+		event(eventapi.EventRunnerOnOk, "1", "create deployment", start.Add(3*time.Second), nil),
+		event(eventapi.EventPlaybookOnTaskStart, "2", "wait", start, nil),
+		event(eventapi.EventRunnerOnFailed, "2", "wait", start.Add(time.Minute),
+			map[string]interface{}{"duration": 30.0, "res": map[string]interface{}{"msg": "timed out"}}),
+		event(eventapi.EventPlaybookOnStats, "", "", start.Add(time.Minute), nil),
+	} {
+		ts.Observe(e)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("Unexpected number of spans %d, expected 3", len(spans))
+	}
+	testCases := []struct {
+		name   string
+		start  time.Time
+		end    time.Time
+		status codes.Code
+	}{
+		{name: "create deployment", start: start, end: start.Add(3 * time.Second), status: codes.Unset},
+		// Reported by ansible-runner.
+		{name: "wait", start: start.Add(30 * time.Second), end: start.Add(time.Minute), status: codes.Error},
+	}
+	for i, tc := range testCases {
+		span := spans[i]
+		if span.Name != tc.name || !span.StartTime.Equal(tc.start) || !span.EndTime.Equal(tc.end) {
+			t.Fatalf("Unexpected span %s from %v to %v, expected %s from %v to %v", span.Name, span.StartTime,
+				span.EndTime, tc.name, tc.start, tc.end)
+		}
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("Expected span %s to be a child of the reconcile", span.Name)
+		}
+		if span.Status.Code != tc.status {
+			t.Fatalf("Unexpected status %v of span %s, expected %v", span.Status.Code, span.Name, tc.status)
+		}
+	}
+}
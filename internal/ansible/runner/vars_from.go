@@ -0,0 +1,111 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// VarsFromOptions - configure how a Runner reads the Secrets and ConfigMaps of the varsFrom
+// of its watch.
+type VarsFromOptions struct {
+	// Reader reads the Secrets and ConfigMaps, e.g. the API reader of the manager, which
+	// only needs permission to get them.
+	Reader client.Reader
+	// OperatorNamespace is the namespace of the operator.
+	OperatorNamespace string
+}
+
+// readsOperatorNamespace returns whether a varsFrom of watch or its finalizer stages reads
+// from the namespace of the operator.
+func readsOperatorNamespace(watch watches.Watch) bool {
+	sources := append([]watches.VarsFrom{}, watch.VarsFrom...)
+	for _, f := range watch.FinalizerStages() {
+		sources = append(sources, f.VarsFrom...)
+	}
+	for _, s := range sources {
+		if s.Namespace != watches.VarsFromCRNamespace {
+			return true
+		}
+	}
+	return false
+}
+
+// readVarsFrom returns the extra vars of the varsFrom of the watch, and of the finalizer stage
+// for the runs of a finalizer stage, for u.
+func (r *runner) readVarsFrom(ctx context.Context, u *unstructured.Unstructured) (map[string]interface{}, error) {
+	sources := r.VarsFrom
+	if stage := r.finalizerStage(u); stage >= 0 {
+		sources = append(append([]watches.VarsFrom{}, sources...), r.Finalizers[stage].VarsFrom...)
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	if r.varsFrom.Reader == nil {
+		return nil, fmt.Errorf("varsFrom of %s cannot be read without a reader", r.GVK)
+	}
+
+	vars := map[string]interface{}{}
+	for _, source := range sources {
+		key := types.NamespacedName{Namespace: r.varsFrom.OperatorNamespace, Name: source.Name()}
+		if source.Namespace == watches.VarsFromCRNamespace {
+			if u.GetNamespace() == "" {
+				return nil, fmt.Errorf("cannot read %s %s from the namespace of a cluster-scoped CR",
+					source.Kind(), source.Name())
+			}
+			key.Namespace = u.GetNamespace()
+		}
+		data, err := r.readData(ctx, source, key)
+		if apierrors.IsNotFound(err) && source.Optional {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vars from %s %s: %w", source.Kind(), key, err)
+		}
+		for k, v := range data {
+			vars[source.Prefix+k] = v
+		}
+	}
+	return vars, nil
+}
+
+// readData returns the data of the Secret or ConfigMap of source with key.
+func (r *runner) readData(ctx context.Context, source watches.VarsFrom, key types.NamespacedName) (map[string]string, error) {
+	if source.SecretRef != nil {
+		secret := &corev1.Secret{}
+		if err := r.varsFrom.Reader.Get(ctx, key, secret); err != nil {
+			return nil, err
+		}
+		data := make(map[string]string, len(secret.Data))
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+		return data, nil
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := r.varsFrom.Reader.Get(ctx, key, configMap); err != nil {
+		return nil, err
+	}
+	return configMap.Data, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runner
+
+import (
+	"errors"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// AdmissionReviewVar - the extra var that holds the AdmissionReview passed to the playbook
+// or role of a webhook.
+const AdmissionReviewVar = "ansible_operator_admission_review"
+
+// NewWebhook - returns a Runner that runs the playbook or role of hook, a webhook of watch,
+// for the AdmissionReview review.
+func NewWebhook(watch watches.Watch, hook watches.Webhook, review map[string]interface{},
+	runnerArgs string) (Runner, error) {
+	var path string
+	var cmdFunc cmdFuncType
+	switch {
+	case hook.Playbook != "":
+		path = hook.Playbook
+		cmdFunc = playbookCmdFunc(path)
+	case hook.Role != "":
+		path = hook.Role
+		cmdFunc = roleCmdFunc(path)
+	default:
+		return nil, errors.New("webhook must specify Role or Playbook")
+	}
+
+	vars := map[string]interface{}{}
+	for k, v := range watch.Vars {
+		vars[k] = v
+	}
+	for k, v := range hook.Vars {
+		vars[k] = v
+	}
+	if watch.MarkUnsafe {
+		vars[AdmissionReviewVar] = markUnsafe(review)
+	} else {
+		vars[AdmissionReviewVar] = review
+	}
+
+	return &runner{
+		Path:                path,
+		cmdFunc:             cmdFunc,
+		Vars:                vars,
+		GVK:                 watch.GroupVersionKind,
+		maxRunnerArtifacts:  1,
+		ansibleVerbosity:    watch.AnsibleVerbosity,
+		ansibleArgs:         runnerArgs,
+		snakeCaseParameters: watch.SnakeCaseParameters,
+		markUnsafe:          watch.MarkUnsafe,
+		admission:           true,
+	}, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package specvalidation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/paramconv"
+)
+
+// argumentSpecs - the argument specs of a role, as in its meta/argument_specs.yml.
+type argumentSpecs struct {
+	ArgumentSpecs map[string]struct {
+		Options map[string]argumentSpec `json:"options"`
+	} `json:"argument_specs"`
+}
+
+// argumentSpec - the spec of an option of a role.
+type argumentSpec struct {
+	Type     string                  `json:"type"`
+	Required bool                    `json:"required"`
+	Choices  []interface{}           `json:"choices"`
+	Elements string                  `json:"elements"`
+	Options  map[string]argumentSpec `json:"options"`
+}
+
+// argumentTypes are the OpenAPI types of the types of options. The options of types that
+// are not listed, e.g. raw, are not type checked.
+var argumentTypes = map[string]string{
+	"str":   "string",
+	"path":  "string",
+	"int":   "integer",
+	"float": "number",
+	"bool":  "boolean",
+	"list":  "array",
+	"dict":  "object",
+}
+
+// argumentSpecsSchema returns the schema of a spec whose fields are the options of the
+// entry point of the argument specs b. The names of the options are converted to camel
+// case if camelCase is set, as the fields of the spec are converted to snake case for
+// the Ansible runs.
+func argumentSpecsSchema(b []byte, entryPoint string, camelCase bool) (*apiextv1.JSONSchemaProps, error) {
+	specs := argumentSpecs{}
+	if err := yaml.Unmarshal(b, &specs); err != nil {
+		return nil, err
+	}
+	ep, ok := specs.ArgumentSpecs[entryPoint]
+	if !ok {
+		return nil, fmt.Errorf("argument specs have no entry point %q", entryPoint)
+	}
+	props, err := optionsSchema(ep.Options, camelCase, "")
+	if err != nil {
+		return nil, err
+	}
+	return &props, nil
+}
+
+// optionsSchema returns the schema of an object whose fields are options. Unlike the
+// argument specs of Ansible, which allow any variable to be set, fields that are not
+// options are invalid, so that misspelled fields are reported.
+func optionsSchema(options map[string]argumentSpec, camelCase bool, path string) (apiextv1.JSONSchemaProps, error) {
+	props := apiextv1.JSONSchemaProps{
+		Type:                 "object",
+		Properties:           map[string]apiextv1.JSONSchemaProps{},
+		AdditionalProperties: &apiextv1.JSONSchemaPropsOrBool{Allows: false},
+	}
+	for name, option := range options {
+		optionPath := path + name
+		p, err := optionSchema(option, camelCase, optionPath)
+		if err != nil {
+			return props, err
+		}
+		if camelCase {
+			name = paramconv.ToCamel(name)
+		}
+		props.Properties[name] = p
+		if option.Required {
+			props.Required = append(props.Required, name)
+		}
+	}
+	sort.Strings(props.Required)
+	return props, nil
+}
+
+func optionSchema(option argumentSpec, camelCase bool, path string) (apiextv1.JSONSchemaProps, error) {
+	props, err := typeSchema(option.Type, option.Options, camelCase, path)
+	if err != nil {
+		return props, err
+	}
+	if props.Type == "array" && option.Elements != "" {
+		items, err := typeSchema(option.Elements, option.Options, camelCase, path+"[]")
+		if err != nil {
+			return props, err
+		}
+		props.Items = &apiextv1.JSONSchemaPropsOrArray{Schema: &items}
+	}
+	for _, choice := range option.Choices {
+		raw, err := json.Marshal(choice)
+		if err != nil {
+			return props, fmt.Errorf("option %s: invalid choice %v: %w", path, choice, err)
+		}
+		props.Enum = append(props.Enum, apiextv1.JSON{Raw: raw})
+	}
+	return props, nil
+}
+
+// typeSchema returns the schema of an option or element of type typ. The options of a
+// dict are its fields.
+func typeSchema(typ string, options map[string]argumentSpec, camelCase bool, path string) (apiextv1.JSONSchemaProps, error) {
+	switch typ {
+	case "", "raw", "bytes", "bits", "json", "jsonarg":
+		return apiextv1.JSONSchemaProps{}, nil
+	case "dict":
+		if len(options) == 0 {
+			return apiextv1.JSONSchemaProps{
+				Type:                 "object",
+				AdditionalProperties: &apiextv1.JSONSchemaPropsOrBool{Allows: true},
+			}, nil
+		}
+		return optionsSchema(options, camelCase, path+".")
+	}
+	t, ok := argumentTypes[typ]
+	if !ok {
+		return apiextv1.JSONSchemaProps{}, fmt.Errorf("option %s has unknown type %q", path, typ)
+	}
+	return apiextv1.JSONSchemaProps{Type: t}, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package specvalidation validates the spec of CRs against an OpenAPI v3 schema before their
+// Ansible runs, so that invalid specs are reported with the paths of their invalid fields
+// instead of failing a task of the run.
+package specvalidation
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+// Validator - validates the specs of the CRs of a watch against the schema of its
+// SpecValidation. A nil Validator validates nothing.
+type Validator struct {
+	// load reads the schema the first time a spec is validated, unless it is nil.
+	load func(context.Context) (*validate.SchemaValidator, error)
+
+	mu        sync.Mutex
+	validator *validate.SchemaValidator
+}
+
+// New - returns the Validator of the SpecValidation of w, or nil if w does not validate
+// the specs of its CRs. A schema file is read right away, while the CRD of the watched
+// kind is read through reader, with the name mapper maps the kind to, when the first
+// spec is validated.
+func New(w watches.Watch, reader client.Reader, mapper meta.RESTMapper) (*Validator, error) {
+	sv := w.SpecValidation
+	if sv == nil {
+		return nil, nil
+	}
+	switch sv.Source {
+	case watches.SpecValidationSourceCRD:
+		return &Validator{load: func(ctx context.Context) (*validate.SchemaValidator, error) {
+			return loadCRD(ctx, reader, mapper, w.GroupVersionKind)
+		}}, nil
+	case watches.SpecValidationSourceFile:
+		v, err := loadFile(sv.File, sv.EntryPoint, w.GroupVersionKind, w.SnakeCaseParameters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spec schema %s: %w", sv.File, err)
+		}
+		return &Validator{validator: v}, nil
+	}
+	return nil, fmt.Errorf("unknown spec validation source %q", sv.Source)
+}
+
+// Validate - returns the fields of the spec of u that do not match the schema, with their
+// paths, sorted so that the same spec always has the same errors. An error is returned if
+// the schema cannot be read.
+func (v *Validator) Validate(ctx context.Context, u *unstructured.Unstructured) (field.ErrorList, error) {
+	if v == nil {
+		return nil, nil
+	}
+	sv, err := v.schemaValidator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := u.Object["spec"]
+	if !ok {
+		spec = map[string]interface{}{}
+	}
+	errs := validation.ValidateCustomResource(field.NewPath("spec"), spec, sv)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errs, nil
+}
+
+func (v *Validator) schemaValidator(ctx context.Context) (*validate.SchemaValidator, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.validator != nil {
+		return v.validator, nil
+	}
+	sv, err := v.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.validator = sv
+	return sv, nil
+}
+
+// loadCRD returns the validator of the schema of the spec in the CRD of gvk.
+func loadCRD(ctx context.Context, reader client.Reader, mapper meta.RESTMapper,
+	gvk schema.GroupVersionKind) (*validate.SchemaValidator, error) {
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s to its resource: %w", gvk, err)
+	}
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(apiextv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"))
+	name := mapping.Resource.GroupResource().String()
+	if err := reader.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+		return nil, fmt.Errorf("failed to get CRD %s: %w", name, err)
+	}
+	props, err := crdSpecSchema(crd.Object, gvk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRD %s: %w", name, err)
+	}
+	return newSchemaValidator(props)
+}
+
+// loadFile returns the validator of the schema of the spec in the file at path, which is
+// a CRD, an OpenAPI v3 schema or the argument specs of a role.
+func loadFile(path, entryPoint string, gvk schema.GroupVersionKind, camelCase bool) (*validate.SchemaValidator, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	var props *apiextv1.JSONSchemaProps
+	switch {
+	case obj["kind"] == "CustomResourceDefinition":
+		if props, err = crdSpecSchema(obj, gvk); err != nil {
+			return nil, err
+		}
+	case obj["argument_specs"] != nil:
+		if props, err = argumentSpecsSchema(b, entryPoint, camelCase); err != nil {
+			return nil, err
+		}
+	default:
+		props = &apiextv1.JSONSchemaProps{}
+		if err := yaml.UnmarshalStrict(b, props); err != nil {
+			return nil, err
+		}
+	}
+	return newSchemaValidator(props)
+}
+
+// crdSpecSchema returns the schema of the spec of the version of gvk in the CRD obj.
+func crdSpecSchema(obj map[string]interface{}, gvk schema.GroupVersionKind) (*apiextv1.JSONSchemaProps, error) {
+	if obj["apiVersion"] != apiextv1.SchemeGroupVersion.String() {
+		return nil, fmt.Errorf("CRD must have apiVersion %s", apiextv1.SchemeGroupVersion)
+	}
+	crd := &apiextv1.CustomResourceDefinition{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, crd); err != nil {
+		return nil, err
+	}
+	if crd.Spec.Group != gvk.Group || crd.Spec.Names.Kind != gvk.Kind {
+		return nil, fmt.Errorf("CRD does not define %s", gvk.GroupKind())
+	}
+	for _, version := range crd.Spec.Versions {
+		if version.Name != gvk.Version {
+			continue
+		}
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			return nil, fmt.Errorf("version %s has no schema", gvk.Version)
+		}
+		spec, ok := version.Schema.OpenAPIV3Schema.Properties["spec"]
+		if !ok || (len(spec.Properties) == 0 && spec.AdditionalProperties == nil) {
+			return nil, fmt.Errorf("version %s has no schema of the spec", gvk.Version)
+		}
+		return &spec, nil
+	}
+	return nil, fmt.Errorf("CRD does not define version %s", gvk.Version)
+}
+
+func newSchemaValidator(props *apiextv1.JSONSchemaProps) (*validate.SchemaValidator, error) {
+	internal := &apiextensions.JSONSchemaProps{}
+	if err := apiextv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(props, internal, nil); err != nil {
+		return nil, err
+	}
+	sv, _, err := validation.NewSchemaValidator(&apiextensions.CustomResourceValidation{OpenAPIV3Schema: internal})
+	return sv, err
+}
@@ -0,0 +1,217 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package specvalidation
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+var memcachedGVK = schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		validation watches.SpecValidation
+		gvk        schema.GroupVersionKind
+		snakeCase  bool
+		spec       map[string]interface{}
+		// expected are the errors of the spec, or the error of New or Validate if shouldErr.
+		expected  []string
+		shouldErr bool
+	}{
+		{
+			name:       "schema valid",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/schema.yaml"},
+			spec:       map[string]interface{}{"size": int64(3), "image": "memcached:1.6"},
+		},
+		{
+			name:       "schema invalid",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/schema.yaml"},
+			spec:       map[string]interface{}{"size": "three", "image": int64(1)},
+			expected: []string{
+				`spec.image: Invalid value: "integer": image in body must be of type string: "integer"`,
+				`spec.size: Invalid value: "string": size in body must be of type integer: "string"`,
+			},
+		},
+		{
+			name:       "schema without spec",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/schema.yaml"},
+			expected:   []string{"spec.size: Required value"},
+		},
+		{
+			name:       "CRD file invalid",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/crd.yaml"},
+			spec:       map[string]interface{}{"size": int64(0), "tier": "medium"},
+			expected: []string{
+				`spec.size: Invalid value: 0: size in body should be greater than or equal to 1`,
+				`spec.tier: Unsupported value: "medium": supported values: "small", "large"`,
+			},
+		},
+		{
+			name:       "CRD file version without spec schema",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/crd.yaml"},
+			gvk:        schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha2", Kind: "Memcached"},
+			expected:   []string{"invalid spec schema testdata/crd.yaml: version v1alpha2 has no schema of the spec"},
+			shouldErr:  true,
+		},
+		{
+			name:       "CRD file of another kind",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/crd.yaml"},
+			gvk:        schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Redis"},
+			expected:   []string{"invalid spec schema testdata/crd.yaml: CRD does not define Redis.cache.example.com"},
+			shouldErr:  true,
+		},
+		{
+			name: "argument specs valid",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/argument_specs.yml",
+				EntryPoint: "main"},
+			snakeCase: true,
+			spec: map[string]interface{}{
+				"size":          int64(3),
+				"tier":          "small",
+				"cacheSettings": map[string]interface{}{"maxMemory": int64(64)},
+				"extraLabels":   map[string]interface{}{"team": "cache"},
+				"ports":         []interface{}{map[string]interface{}{"containerPort": int64(11211)}},
+			},
+		},
+		{
+			name: "argument specs invalid",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/argument_specs.yml",
+				EntryPoint: "main"},
+			snakeCase: true,
+			spec: map[string]interface{}{
+				"tier":          "medium",
+				"cacheSettings": map[string]interface{}{"maxMemroy": int64(64)},
+				"ports":         []interface{}{map[string]interface{}{"port": int64(11211)}},
+			},
+			expected: []string{
+				`spec.cacheSettings: Invalid value: "maxMemroy": cacheSettings.maxMemroy in body is a forbidden property`,
+				`spec.ports.containerPort: Required value`,
+				`spec.ports: Invalid value: "port": ports.port in body is a forbidden property`,
+				`spec.size: Required value`,
+				`spec.tier: Unsupported value: "medium": supported values: "small", "large"`,
+			},
+		},
+		{
+			name: "argument specs without snake case parameters",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/argument_specs.yml",
+				EntryPoint: "main"},
+			spec: map[string]interface{}{"size": int64(3), "cache_settings": map[string]interface{}{"max_memory": int64(64)}},
+		},
+		{
+			name: "argument specs unknown entry point",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile, File: "testdata/argument_specs.yml",
+				EntryPoint: "upgrade"},
+			expected: []string{
+				`invalid spec schema testdata/argument_specs.yml: argument specs have no entry point "upgrade"`,
+			},
+			shouldErr: true,
+		},
+		{
+			name: "argument specs unknown type",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceFile,
+				File: "testdata/invalid_argument_specs.yml", EntryPoint: "main"},
+			expected: []string{
+				`invalid spec schema testdata/invalid_argument_specs.yml: option size has unknown type "integer"`,
+			},
+			shouldErr: true,
+		},
+		{
+			name:       "CRD on the cluster",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceCRD},
+			spec:       map[string]interface{}{"size": int64(0)},
+			expected:   []string{`spec.size: Invalid value: 0: size in body should be greater than or equal to 1`},
+		},
+		{
+			name:       "CRD not on the cluster",
+			validation: watches.SpecValidation{Source: watches.SpecValidationSourceCRD},
+			gvk:        schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Redis"},
+			expected: []string{`failed to get CRD redises.cache.example.com: ` +
+				`customresourcedefinitions.apiextensions.k8s.io "redises.cache.example.com" not found`},
+			shouldErr: true,
+		},
+	}
+
+	b, err := ioutil.ReadFile("testdata/crd.yaml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	crd := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(b, &crd.Object); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(memcachedGVK, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Redis"},
+		meta.RESTScopeNamespace)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gvk := tc.gvk
+			if gvk.Empty() {
+				gvk = memcachedGVK
+			}
+			w := watches.Watch{GroupVersionKind: gvk, SnakeCaseParameters: tc.snakeCase,
+				SpecValidation: &tc.validation}
+			reader := fakeclient.NewClientBuilder().WithObjects(crd.DeepCopy()).Build()
+			var errs []string
+			v, err := New(w, reader, mapper)
+			if err == nil {
+				u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+				if tc.spec != nil {
+					u.Object["spec"] = tc.spec
+				}
+				fieldErrs, verr := v.Validate(context.TODO(), u)
+				err = verr
+				for _, e := range fieldErrs {
+					errs = append(errs, e.Error())
+				}
+			}
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatalf("Expected an error")
+				}
+				errs = []string{err.Error()}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(errs, tc.expected) {
+				t.Fatalf("Unexpected errors:\n%q\nexpected:\n%q", errs, tc.expected)
+			}
+		})
+	}
+}
+
+func TestNilValidator(t *testing.T) {
+	v, err := New(watches.Watch{GroupVersionKind: memcachedGVK}, nil, nil)
+	if err != nil || v != nil {
+		t.Fatalf("Unexpected validator %v and error %v", v, err)
+	}
+	errs, err := v.Validate(context.TODO(), &unstructured.Unstructured{Object: map[string]interface{}{}})
+	if errs != nil || err != nil {
+		t.Fatalf("Unexpected errors %v and error %v", errs, err)
+	}
+}
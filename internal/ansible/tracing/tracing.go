@@ -0,0 +1,124 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing traces the reconciles of the ansible operator with OpenTelemetry: a span
+// for each reconcile, with child spans for the tasks of its Ansible run and for the API
+// requests the run makes through the proxy.
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("tracing")
+
+// TracerName is the name of the tracer of the ansible operator.
+const TracerName = "github.com/operator-framework/operator-sdk/internal/ansible"
+
+// Attributes of the spans of the ansible operator.
+const (
+	GVKKey        = attribute.Key("ansible.operator.gvk")
+	NamespaceKey  = attribute.Key("ansible.operator.namespace")
+	NameKey       = attribute.Key("ansible.operator.name")
+	JobKey        = attribute.Key("ansible.operator.job")
+	TaskKey       = attribute.Key("ansible.task")
+	RoleKey       = attribute.Key("ansible.role")
+	HostKey       = attribute.Key("ansible.host")
+	TaskResultKey = attribute.Key("ansible.task.result")
+)
+
+// defaultQueueSize is the number of spans buffered for export by default.
+const defaultQueueSize = 8192
+
+// Options - configure the export of the spans.
+type Options struct {
+	// Endpoint is the host and port of the OTLP/HTTP endpoint to export spans to.
+	// Tracing is disabled if it is empty.
+	Endpoint string
+	// Insecure exports spans without TLS.
+	Insecure bool
+	// ServiceName is the name of the service of the spans.
+	ServiceName string
+	// QueueSize is the number of spans buffered until they are exported. Spans are
+	// dropped when the buffer is full, such as when no collector is reachable.
+	QueueSize int
+}
+
+// Setup - configures the global tracer provider to export spans as configured by opts.
+// The returned function flushes the buffered spans and stops the export.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithMaxQueueSize(opts.QueueSize)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(opts.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.V(1).Info("Failed to export spans", "error", err.Error())
+	}))
+	log.Info("Exporting traces", "endpoint", opts.Endpoint)
+	return provider.Shutdown, nil
+}
+
+// Tracer - returns the tracer of the ansible operator.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// runs holds the span contexts of the running Ansible runs by job ident.
+var runs sync.Map
+
+// RegisterRun - records sc as the span context of the Ansible run ident, so that the
+// API requests of the run are traced as its children. The returned function removes it
+// and must be called once the run has finished.
+func RegisterRun(ident string, sc trace.SpanContext) func() {
+	if !sc.IsValid() {
+		return func() {}
+	}
+	runs.Store(ident, sc)
+	return func() { runs.Delete(ident) }
+}
+
+// RunSpanContext - returns the span context of the Ansible run ident, if it is running.
+func RunSpanContext(ident string) (trace.SpanContext, bool) {
+	sc, ok := runs.Load(ident)
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+	return sc.(trace.SpanContext), true
+}
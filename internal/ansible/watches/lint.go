@@ -0,0 +1,240 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watches
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/util/lint"
+)
+
+var (
+	gvkFields      = lint.Fields{"group": nil, "version": nil, "kind": nil}
+	varsFromFields = lint.Fields{
+		"secretRef":         {"name": nil},
+		"configMapRef":      {"name": nil},
+		"namespace":         nil,
+		"prefix":            nil,
+		"optional":          nil,
+		"reconcileOnChange": nil,
+	}
+	webhookFields   = lint.Fields{"playbook": nil, "role": nil, "vars": nil}
+	finalizerFields = lint.Fields{
+		"name":       nil,
+		"playbook":   nil,
+		"role":       nil,
+		"vars":       nil,
+		"varsFrom":   varsFromFields,
+		"runTimeout": nil,
+	}
+
+	// watchFields are the fields of a watch, as in the published JSON schema of the
+	// watches file.
+	watchFields = lint.Fields{
+		"group":                       nil,
+		"version":                     nil,
+		"kind":                        nil,
+		"playbook":                    nil,
+		"role":                        nil,
+		"vars":                        nil,
+		"varsFrom":                    varsFromFields,
+		"maxRunnerArtifacts":          nil,
+		"reconcilePeriod":             nil,
+		"runTimeout":                  nil,
+		"manageStatus":                nil,
+		"watchDependentResources":     nil,
+		"watchClusterScopedResources": nil,
+		"snakeCaseParameters":         nil,
+		"markUnsafe":                  nil,
+		"recordEvents":                nil,
+		"runHistoryLimit":             nil,
+		"skipUnchanged":               nil,
+		"runPriorityClass":            nil,
+		"blacklist":                   gvkFields,
+		"finalizer":                   finalizerFields,
+		"finalizers":                  finalizerFields,
+		"selector": {
+			"matchLabels":      nil,
+			"matchExpressions": {"key": nil, "operator": nil, "values": nil},
+		},
+		"workerPool":     {"size": nil},
+		"webhooks":       {"validating": webhookFields, "mutating": webhookFields},
+		"impersonation":  {"serviceAccountField": nil, "serviceAccount": nil},
+		"apiAllowlist":   {"group": nil, "version": nil, "kind": nil, "verbs": nil, "subresources": nil},
+		"specValidation": {"source": nil, "file": nil, "entryPoint": nil},
+	}
+)
+
+// roleDirs are the directories of a role whose YAML files are parsed by Lint.
+var roleDirs = []string{"tasks", "handlers", "defaults", "vars", "meta"}
+
+// Lint - returns every problem of the watches file at path, with the line it is found at.
+// Unlike Load, which fails on the first invalid watch, Lint reports unknown fields,
+// invalid selectors, duplicate GVKs and the playbooks and roles that do not exist or do
+// not parse of all watches. Relative playbook and role paths are relative to the
+// directory of the watches file.
+func Lint(path string) lint.Problems {
+	problems := lint.Problems{}
+	root := lint.ParseFile(path, &problems)
+	if root == nil {
+		if len(problems) == 0 {
+			problems.Add(path, 0, "no watches found")
+		}
+		return problems
+	}
+	if root.Kind != yaml.SequenceNode {
+		problems.Add(path, root.Line, "watches file must be a list of watches")
+		return problems
+	}
+	rootDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		problems.Add(path, 0, "%v", err)
+		return problems
+	}
+
+	lines := map[schema.GroupVersionKind]int{}
+	for _, node := range root.Content {
+		if node.Kind != yaml.MappingNode {
+			problems.Add(path, node.Line, "watch must be a mapping")
+			continue
+		}
+		lint.CheckKeys(path, node, watchFields, "", &problems)
+		w, ok := lintWatch(path, rootDir, node, &problems)
+		if !ok {
+			continue
+		}
+		if line, ok := lines[w.GroupVersionKind]; ok {
+			problems.Add(path, node.Line, "duplicate GVK: %s, first watched at line %d", w.GroupVersionKind, line)
+		} else {
+			lines[w.GroupVersionKind] = node.Line
+		}
+	}
+	problems.Sort()
+	return problems
+}
+
+// lintWatch adds the problems of the watch of node to problems, and returns the watch if
+// it could be decoded.
+func lintWatch(path, rootDir string, node *yaml.Node, problems *lint.Problems) (*Watch, bool) {
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		problems.Add(path, node.Line, "%v", err)
+		return nil, false
+	}
+	tmp := alias{}
+	if err := sigsyaml.Unmarshal(b, &tmp); err != nil {
+		problems.Add(path, node.Line, "invalid watch: %v", err)
+		return nil, false
+	}
+	w := &Watch{}
+	if err := w.setValuesFromAliasIn(tmp, rootDir); err != nil {
+		problems.Add(path, node.Line, "%v", err)
+		return nil, false
+	}
+
+	for _, err := range w.validate() {
+		problems.Add(path, lint.Line(node, err.path...), "%v", err.err)
+	}
+	if _, err := metav1.LabelSelectorAsSelector(&w.Selector); err != nil {
+		problems.Add(path, lint.Line(node, "selector"), "invalid selector: %v", err)
+	}
+
+	lintAnsiblePath(path, lint.Line(node, "playbook"), lint.Line(node, "role"), w.Playbook, w.Role, problems)
+	for i, f := range w.FinalizerStages() {
+		keys := []string{"finalizers", strconv.Itoa(i)}
+		if w.Finalizer != nil {
+			keys = []string{"finalizer"}
+		}
+		lintAnsiblePath(path, lint.Line(node, append(keys, "playbook")...), lint.Line(node, append(keys, "role")...),
+			f.Playbook, f.Role, problems)
+	}
+	if w.Webhooks != nil {
+		for _, name := range []string{"validating", "mutating"} {
+			hook := w.Webhooks.Validating
+			if name == "mutating" {
+				hook = w.Webhooks.Mutating
+			}
+			if hook != nil {
+				lintAnsiblePath(path, lint.Line(node, "webhooks", name, "playbook"),
+					lint.Line(node, "webhooks", name, "role"), hook.Playbook, hook.Role, problems)
+			}
+		}
+	}
+	return w, true
+}
+
+// lintAnsiblePath reports the playbook, or otherwise the role, that exists but does not
+// parse. The ones that do not exist are reported by validate.
+func lintAnsiblePath(path string, playbookLine, roleLine int, playbook, role string, problems *lint.Problems) {
+	switch {
+	case playbook != "":
+		if _, err := os.Stat(playbook); err != nil {
+			return
+		}
+		if err := parseYAMLFile(playbook, true); err != nil {
+			problems.Add(path, playbookLine, "playbook %s: %v", playbook, err)
+		}
+	case role != "":
+		if info, err := os.Stat(role); err != nil {
+			return
+		} else if !info.IsDir() {
+			problems.Add(path, roleLine, "role: %s is not a directory", role)
+			return
+		}
+		for _, dir := range roleDirs {
+			files, err := ioutil.ReadDir(filepath.Join(role, dir))
+			if err != nil {
+				continue
+			}
+			for _, f := range files {
+				ext := filepath.Ext(f.Name())
+				if f.IsDir() || (ext != ".yml" && ext != ".yaml") {
+					continue
+				}
+				file := filepath.Join(role, dir, f.Name())
+				if err := parseYAMLFile(file, false); err != nil {
+					problems.Add(path, roleLine, "role %s: %s: %v", role, filepath.Join(dir, f.Name()), err)
+				}
+			}
+		}
+	}
+}
+
+// parseYAMLFile returns an error if the file at path is not valid YAML, or if playbook is
+// true and it is not a list of plays.
+func parseYAMLFile(path string, playbook bool) error {
+	ps := lint.Problems{}
+	root := lint.ParseFile(path, &ps)
+	if len(ps) > 0 {
+		p := ps[0]
+		if p.Line > 0 {
+			return fmt.Errorf("line %d: %s", p.Line, p.Message)
+		}
+		return fmt.Errorf("%s", p.Message)
+	}
+	if playbook && (root == nil || root.Kind != yaml.SequenceNode) {
+		return fmt.Errorf("must be a list of plays")
+	}
+	return nil
+}
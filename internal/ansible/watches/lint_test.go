@@ -0,0 +1,177 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watches
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/operator-framework/operator-sdk/internal/util/lint"
+)
+
+func TestLint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watches-lint")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range map[string]string{
+		"playbook.yml":                   "- hosts: localhost\n",
+		"broken.yml":                     "- hosts: localhost\n  tasks: [\n",
+		"roles/memcached/tasks/main.yml": "- debug:\n    msg: hello\n",
+		"roles/broken/tasks/main.yml":    "- debug: {\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		watches string
+		// expected are the prefixes of the problems, without the path of the watches file.
+		expected []string
+	}{
+		{
+			name: "valid",
+			watches: `---
+- version: v1alpha1
+  group: cache.example.com
+  kind: Memcached
+  role: memcached
+  reconcilePeriod: 1m
+  finalizer:
+    name: cache.example.com/finalizer
+    vars:
+      state: absent
+- version: v1alpha1
+  group: cache.example.com
+  kind: Other
+  playbook: playbook.yml
+  selector:
+    matchExpressions:
+    - key: tier
+      operator: In
+      values: [cache]
+`,
+		},
+		{
+			name:     "not a list",
+			watches:  "version: v1alpha1\n",
+			expected: []string{"1: watches file must be a list of watches"},
+		},
+		{
+			name:     "invalid YAML",
+			watches:  "- version: v1alpha1\n  kind: [\n",
+			expected: []string{"2: invalid YAML: did not find expected node content"},
+		},
+		{
+			name: "all problems",
+			watches: `---
+- version: v1alpha1
+  group: cache.example.com
+  kind: Memcached
+  role: missing
+  finalizer:
+    name: cache.example.com/finalizer
+  runHistoryLimit: -1
+- version: v1alpha1
+  group: cache.example.com
+  kind: Memcached
+  playbok: playbook.yml
+  selector:
+    matchExpressions:
+    - key: tier
+      operator: Within
+      values: [cache]
+- group: cache.example.com
+  kind: Broken
+  playbook: broken.yml
+- version: v1alpha1
+  group: cache.example.com
+  kind: Broken
+  role: broken
+  webhooks:
+    validating:
+      playbook: broken.yml
+- version: v1alpha1
+  kind: Bad
+  playbook: playbook.yml
+  maxRunnerArtifacts: many
+`,
+			expected: []string{
+				"5: role: missing was not found",
+				"6: must specify Role or Playbook",
+				"8: run history limit must not be negative",
+				"9: must specify Role or Playbook",
+				"9: duplicate GVK: cache.example.com/v1alpha1, Kind=Memcached, first watched at line 2",
+				`12: unknown field "playbok"`,
+				`13: invalid selector: "Within" is not a valid pod selector operator`,
+				"18: invalid GVK: cache.example.com/, Kind=Broken: version must not be empty",
+				"24: role " + filepath.Join(dir, "roles/broken") + ": " + filepath.Join("tasks", "main.yml") +
+					": line 1: invalid YAML: did not find expected node content",
+				"27: playbook " + filepath.Join(dir, "broken.yml") +
+					": line 2: invalid YAML: did not find expected node content",
+				"28: invalid watch: error unmarshaling JSON: while decoding JSON: json: cannot unmarshal string",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, "watches.yaml")
+			if err := ioutil.WriteFile(path, []byte(tc.watches), 0644); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var problems []string
+			for _, p := range Lint(path) {
+				if p.File != path {
+					t.Fatalf("Unexpected file %q of problem %s", p.File, p)
+				}
+				problems = append(problems, p.String()[len(path)+1:])
+			}
+			if len(problems) != len(tc.expected) {
+				t.Fatalf("Unexpected problems:\n%q\nexpected:\n%q", problems, tc.expected)
+			}
+			for i := range problems {
+				if !strings.HasPrefix(problems[i], tc.expected[i]) {
+					t.Fatalf("Unexpected problems:\n%q\nexpected:\n%q", problems, tc.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestLintFieldsMatchSchema(t *testing.T) {
+	b, err := ioutil.ReadFile("../../../website/static/schemas/ansible-watches.schema.json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fields, err := lint.SchemaFields(b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(fields, watchFields) {
+		t.Fatalf("The fields of the JSON schema %v do not match the fields checked %v", fields, watchFields)
+	}
+}
@@ -19,6 +19,7 @@ package watches
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -28,7 +29,9 @@ import (
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	yaml "sigs.k8s.io/yaml"
 
@@ -45,27 +48,254 @@ type Watch struct {
 	Playbook                    string                    `yaml:"playbook"`
 	Role                        string                    `yaml:"role"`
 	Vars                        map[string]interface{}    `yaml:"vars"`
+	VarsFrom                    []VarsFrom                `yaml:"varsFrom"`
 	MaxRunnerArtifacts          int                       `yaml:"maxRunnerArtifacts"`
 	ReconcilePeriod             time.Duration             `yaml:"reconcilePeriod"`
+	RunTimeout                  time.Duration             `yaml:"runTimeout"`
 	Finalizer                   *Finalizer                `yaml:"finalizer"`
+	Finalizers                  []Finalizer               `yaml:"finalizers"`
 	ManageStatus                bool                      `yaml:"manageStatus"`
 	WatchDependentResources     bool                      `yaml:"watchDependentResources"`
 	WatchClusterScopedResources bool                      `yaml:"watchClusterScopedResources"`
 	SnakeCaseParameters         bool                      `yaml:"snakeCaseParameters"`
 	MarkUnsafe                  bool                      `yaml:"markUnsafe"`
+	RecordEvents                bool                      `yaml:"recordEvents"`
+	RunHistoryLimit             int                       `yaml:"runHistoryLimit"`
+	SkipUnchanged               bool                      `yaml:"skipUnchanged"`
 	Selector                    metav1.LabelSelector      `yaml:"selector"`
+	WorkerPool                  *WorkerPool               `yaml:"workerPool"`
+	Webhooks                    *Webhooks                 `yaml:"webhooks"`
+	Impersonation               *Impersonation            `yaml:"impersonation"`
+	APIAllowlist                []APIRule                 `yaml:"apiAllowlist"`
+	SpecValidation              *SpecValidation           `yaml:"specValidation"`
+	RunPriorityClass            string                    `yaml:"runPriorityClass"`
 
 	// Not configurable via watches.yaml
 	MaxConcurrentReconciles int `yaml:"-"`
 	AnsibleVerbosity        int `yaml:"-"`
 }
 
-// Finalizer - Expose finalizer to be used by a user.
+// Finalizer - Expose finalizer to be used by a user. A watch has either a single Finalizer,
+// or Finalizers whose stages run one after the other when a CR is deleted.
 type Finalizer struct {
 	Name     string                 `yaml:"name"`
 	Playbook string                 `yaml:"playbook"`
 	Role     string                 `yaml:"role"`
 	Vars     map[string]interface{} `yaml:"vars"`
+	VarsFrom []VarsFrom             `yaml:"varsFrom"`
+	// RunTimeout is the maximum duration of the runs of the finalizer. Defaults to the run
+	// timeout of the watch.
+	RunTimeout metav1.Duration `yaml:"runTimeout"`
+}
+
+// VarsFrom - Passes the data of a Secret or ConfigMap to the watch's Ansible runs as extra
+// vars. The values are read at the start of every run and marked unsafe.
+type VarsFrom struct {
+	// SecretRef names the Secret to read. Exactly one of SecretRef and ConfigMapRef is set.
+	SecretRef *ObjectRef `yaml:"secretRef,omitempty"`
+	// ConfigMapRef names the ConfigMap to read.
+	ConfigMapRef *ObjectRef `yaml:"configMapRef,omitempty"`
+	// Namespace is where the object is read from, VarsFromOperatorNamespace or
+	// VarsFromCRNamespace. Defaults to VarsFromOperatorNamespace.
+	Namespace string `yaml:"namespace,omitempty"`
+	// Prefix is prepended to the keys of the data to get the names of the extra vars.
+	Prefix string `yaml:"prefix,omitempty"`
+	// Optional makes runs proceed without the extra vars when the object does not exist.
+	Optional bool `yaml:"optional,omitempty"`
+	// ReconcileOnChange re-reconciles the affected CRs when the object changes.
+	ReconcileOnChange bool `yaml:"reconcileOnChange,omitempty"`
+}
+
+// ObjectRef - Refers to an object by name.
+type ObjectRef struct {
+	Name string `yaml:"name"`
+}
+
+const (
+	// VarsFromOperatorNamespace reads the object of a VarsFrom from the namespace of the
+	// operator.
+	VarsFromOperatorNamespace = "operator"
+	// VarsFromCRNamespace reads the object of a VarsFrom from the namespace of the CR.
+	VarsFromCRNamespace = "cr"
+)
+
+// Kind returns the kind of the object of v, "Secret" or "ConfigMap".
+func (v VarsFrom) Kind() string {
+	if v.SecretRef != nil {
+		return "Secret"
+	}
+	return "ConfigMap"
+}
+
+// Name returns the name of the object of v.
+func (v VarsFrom) Name() string {
+	if v.SecretRef != nil {
+		return v.SecretRef.Name
+	}
+	if v.ConfigMapRef != nil {
+		return v.ConfigMapRef.Name
+	}
+	return ""
+}
+
+func (v VarsFrom) validate() error {
+	if (v.SecretRef == nil) == (v.ConfigMapRef == nil) {
+		return fmt.Errorf("exactly one of secretRef and configMapRef must be set")
+	}
+	if errs := validation.IsDNS1123Subdomain(v.Name()); len(errs) > 0 {
+		return fmt.Errorf("%s name %q is invalid: %s", v.Kind(), v.Name(), strings.Join(errs, ", "))
+	}
+	if v.Namespace != VarsFromOperatorNamespace && v.Namespace != VarsFromCRNamespace {
+		return fmt.Errorf("namespace %q is invalid, must be %q or %q", v.Namespace,
+			VarsFromOperatorNamespace, VarsFromCRNamespace)
+	}
+	return nil
+}
+
+// setVarsFromDefaults sets the defaults of the optional fields of varsFrom.
+func setVarsFromDefaults(varsFrom []VarsFrom) {
+	for i := range varsFrom {
+		if varsFrom[i].Namespace == "" {
+			varsFrom[i].Namespace = VarsFromOperatorNamespace
+		}
+	}
+}
+
+// WorkerPool - Runs the watch's playbook or role on a pool of persistent ansible-runner
+// worker processes instead of starting a new ansible-runner process for every run.
+type WorkerPool struct {
+	// Size is the number of worker processes. Defaults to the max concurrent reconciles
+	// of the watch.
+	Size int `yaml:"size"`
+}
+
+// Webhooks - Maps the validating and mutating admission of the watch's GVK to a
+// playbook or role.
+type Webhooks struct {
+	Validating *Webhook `yaml:"validating"`
+	Mutating   *Webhook `yaml:"mutating"`
+}
+
+// Webhook - The playbook or role run for every admission request of a webhook. The
+// AdmissionReview is passed to it in the extra var ansible_operator_admission_review.
+type Webhook struct {
+	Playbook string                 `yaml:"playbook"`
+	Role     string                 `yaml:"role"`
+	Vars     map[string]interface{} `yaml:"vars"`
+}
+
+// Impersonation - Makes the proxy send the API requests of the watch's Ansible runs as a
+// ServiceAccount in the namespace of the CR instead of as the operator.
+type Impersonation struct {
+	// ServiceAccountField is the dot-separated path of the field of the CR that names the
+	// ServiceAccount, e.g. spec.serviceAccountName.
+	ServiceAccountField string `yaml:"serviceAccountField"`
+	// ServiceAccount is the ServiceAccount used when the CR does not name one. Defaults
+	// to "default".
+	ServiceAccount string `yaml:"serviceAccount"`
+}
+
+// ServiceAccountOf returns the name of the ServiceAccount to impersonate for the CR u: the
+// value of its ServiceAccountField, or ServiceAccount if the field is unset.
+func (i *Impersonation) ServiceAccountOf(u *unstructured.Unstructured) (string, error) {
+	name := i.ServiceAccount
+	if i.ServiceAccountField != "" {
+		v, found, err := unstructured.NestedString(u.Object, strings.Split(i.ServiceAccountField, ".")...)
+		if err != nil {
+			return "", fmt.Errorf("field %s must be a string", i.ServiceAccountField)
+		}
+		if found && v != "" {
+			name = v
+		}
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", fmt.Errorf("invalid ServiceAccount name %q: %s", name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
+
+// APIRule - Allows the watch's Ansible runs to use verbs on the resources of a kind
+// through the proxy. When a watch has rules, all other requests are denied.
+type APIRule struct {
+	// Group is the API group of the kind, "" for the core group. "*" matches all groups.
+	Group string `yaml:"group"`
+	// Version is the API version of the kind. Matches all versions if empty.
+	Version string `yaml:"version,omitempty"`
+	// Kind is the kind of the resources. "*" matches all kinds.
+	Kind string `yaml:"kind"`
+	// Verbs are the allowed verbs, e.g. get, list, watch, create, update, patch, delete and
+	// deletecollection. "*" matches all verbs.
+	Verbs []string `yaml:"verbs"`
+	// Subresources are the subresources, e.g. status, the verbs are allowed on. Requests for
+	// a subresource are only allowed if it is listed. "*" matches all subresources.
+	Subresources []string `yaml:"subresources,omitempty"`
+}
+
+// SpecValidation - Validates the spec of each CR against an OpenAPI v3 schema before its
+// Ansible runs. The runs of a CR whose spec is invalid are skipped.
+type SpecValidation struct {
+	// Source is where the schema is read from, SpecValidationSourceCRD or
+	// SpecValidationSourceFile.
+	Source string `yaml:"source"`
+	// File is the path of a CRD, of an OpenAPI v3 schema of the spec, or of the argument
+	// specs of a role, e.g. roles/memcached/meta/argument_specs.yml, if Source is
+	// SpecValidationSourceFile.
+	File string `yaml:"file,omitempty"`
+	// EntryPoint is the entry point of the argument specs of File. Defaults to "main".
+	EntryPoint string `yaml:"entryPoint,omitempty"`
+}
+
+const (
+	// SpecValidationSourceCRD reads the schema of the spec from the CRD of the watched kind
+	// on the cluster.
+	SpecValidationSourceCRD = "crd"
+	// SpecValidationSourceFile reads the schema of the spec from the File of a
+	// SpecValidation.
+	SpecValidationSourceFile = "file"
+)
+
+const (
+	// RunPriorityClassHigh runs the Ansible runs of a watch before those of other classes
+	// when the runs of the operator are limited.
+	RunPriorityClassHigh = "high"
+	// RunPriorityClassNormal is the default priority class of the Ansible runs of a watch.
+	RunPriorityClassNormal = "normal"
+	// RunPriorityClassLow runs the Ansible runs of a watch after those of other classes
+	// when the runs of the operator are limited.
+	RunPriorityClassLow = "low"
+)
+
+// Allows - returns whether the rule allows verb on subresource, or on the resource itself
+// if subresource is empty, of a kind gvk.
+func (r APIRule) Allows(gvk schema.GroupVersionKind, subresource, verb string) bool {
+	if r.Group != "*" && r.Group != gvk.Group {
+		return false
+	}
+	if r.Version != "" && r.Version != gvk.Version {
+		return false
+	}
+	if r.Kind != "*" && r.Kind != gvk.Kind {
+		return false
+	}
+	if subresource != "" && !matchesAny(r.Subresources, subresource) {
+		return false
+	}
+	return matchesAny(r.Verbs, verb)
+}
+
+func matchesAny(values []string, value string) bool {
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// apiRuleVerbs are the verbs of API rules, see k8s.io/apiserver/pkg/endpoints/request.
+var apiRuleVerbs = map[string]bool{
+	"*": true, "get": true, "list": true, "watch": true, "create": true, "update": true,
+	"patch": true, "delete": true, "deletecollection": true, "proxy": true,
 }
 
 // Default values for optional fields on Watch
@@ -73,12 +303,18 @@ var (
 	blacklistDefault                   = []schema.GroupVersionKind{}
 	maxRunnerArtifactsDefault          = 20
 	reconcilePeriodDefault             = metav1.Duration{Duration: time.Duration(0)}
+	runTimeoutDefault                  = metav1.Duration{Duration: time.Duration(0)}
 	manageStatusDefault                = true
 	watchDependentResourcesDefault     = true
 	watchClusterScopedResourcesDefault = false
 	snakeCaseParametersDefault         = true
 	markUnsafeDefault                  = false
+	recordEventsDefault                = false
+	skipUnchangedDefault               = false
 	selectorDefault                    = metav1.LabelSelector{}
+	impersonationServiceAccountDefault = "default"
+	specValidationEntryPointDefault    = "main"
+	runPriorityClassDefault            = RunPriorityClassNormal
 
 	// these are overridden by cmdline flags
 	maxConcurrentReconcilesDefault = runtime.NumCPU()
@@ -123,20 +359,42 @@ type alias struct {
 	Playbook                    string                    `yaml:"playbook"`
 	Role                        string                    `yaml:"role"`
 	Vars                        map[string]interface{}    `yaml:"vars"`
+	VarsFrom                    []VarsFrom                `yaml:"varsFrom,omitempty"`
 	MaxRunnerArtifacts          int                       `yaml:"maxRunnerArtifacts"`
 	ReconcilePeriod             *metav1.Duration          `yaml:"reconcilePeriod,omitempty"`
+	RunTimeout                  *metav1.Duration          `yaml:"runTimeout,omitempty"`
 	ManageStatus                *bool                     `yaml:"manageStatus,omitempty"`
 	WatchDependentResources     *bool                     `yaml:"watchDependentResources,omitempty"`
 	WatchClusterScopedResources *bool                     `yaml:"watchClusterScopedResources,omitempty"`
 	SnakeCaseParameters         *bool                     `yaml:"snakeCaseParameters"`
 	MarkUnsafe                  *bool                     `yaml:"markUnsafe"`
+	RecordEvents                *bool                     `yaml:"recordEvents,omitempty"`
+	RunHistoryLimit             int                       `yaml:"runHistoryLimit,omitempty"`
+	SkipUnchanged               *bool                     `yaml:"skipUnchanged,omitempty"`
 	Blacklist                   []schema.GroupVersionKind `yaml:"blacklist,omitempty"`
 	Finalizer                   *Finalizer                `yaml:"finalizer"`
+	Finalizers                  []Finalizer               `yaml:"finalizers,omitempty"`
 	Selector                    tempLabelSelector         `yaml:"selector"`
+	WorkerPool                  *WorkerPool               `yaml:"workerPool,omitempty"`
+	Webhooks                    *Webhooks                 `yaml:"webhooks,omitempty"`
+	Impersonation               *Impersonation            `yaml:"impersonation,omitempty"`
+	APIAllowlist                []APIRule                 `yaml:"apiAllowlist,omitempty"`
+	SpecValidation              *SpecValidation           `yaml:"specValidation,omitempty"`
+	RunPriorityClass            string                    `yaml:"runPriorityClass,omitempty"`
 }
 
 // buildWatch will build Watch based on the values parsed from alias
 func (w *Watch) setValuesFromAlias(tmp alias) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	return w.setValuesFromAliasIn(tmp, wd)
+}
+
+// setValuesFromAliasIn is setValuesFromAlias, with relative role and playbook paths
+// relative to rootDir.
+func (w *Watch) setValuesFromAliasIn(tmp alias, rootDir string) error {
 	// by default, the operator will manage status and watch dependent resources
 	if tmp.ManageStatus == nil {
 		tmp.ManageStatus = &manageStatusDefault
@@ -153,6 +411,10 @@ func (w *Watch) setValuesFromAlias(tmp alias) error {
 		tmp.ReconcilePeriod = &reconcilePeriodDefault
 	}
 
+	if tmp.RunTimeout == nil {
+		tmp.RunTimeout = &runTimeoutDefault
+	}
+
 	if tmp.WatchClusterScopedResources == nil {
 		tmp.WatchClusterScopedResources = &watchClusterScopedResourcesDefault
 	}
@@ -169,6 +431,18 @@ func (w *Watch) setValuesFromAlias(tmp alias) error {
 		tmp.MarkUnsafe = &markUnsafeDefault
 	}
 
+	if tmp.RecordEvents == nil {
+		tmp.RecordEvents = &recordEventsDefault
+	}
+
+	if tmp.SkipUnchanged == nil {
+		tmp.SkipUnchanged = &skipUnchangedDefault
+	}
+
+	if tmp.RunPriorityClass == "" {
+		tmp.RunPriorityClass = runPriorityClassDefault
+	}
+
 	gvk := schema.GroupVersionKind{
 		Group:   tmp.Group,
 		Version: tmp.Version,
@@ -184,28 +458,70 @@ func (w *Watch) setValuesFromAlias(tmp alias) error {
 	w.Playbook = tmp.Playbook
 	w.Role = tmp.Role
 	w.Vars = tmp.Vars
+	w.VarsFrom = tmp.VarsFrom
+	setVarsFromDefaults(w.VarsFrom)
 	w.MaxRunnerArtifacts = tmp.MaxRunnerArtifacts
 	w.MaxConcurrentReconciles = getMaxConcurrentReconciles(gvk, maxConcurrentReconcilesDefault)
 	w.ReconcilePeriod = tmp.ReconcilePeriod.Duration
+	w.RunTimeout = tmp.RunTimeout.Duration
 	w.ManageStatus = *tmp.ManageStatus
 	w.WatchDependentResources = *tmp.WatchDependentResources
 	w.SnakeCaseParameters = *tmp.SnakeCaseParameters
 	w.MarkUnsafe = *tmp.MarkUnsafe
+	w.RecordEvents = *tmp.RecordEvents
+	w.RunHistoryLimit = tmp.RunHistoryLimit
+	w.SkipUnchanged = *tmp.SkipUnchanged
+	w.RunPriorityClass = tmp.RunPriorityClass
 	w.WatchClusterScopedResources = *tmp.WatchClusterScopedResources
 	w.Finalizer = tmp.Finalizer
+	w.Finalizers = tmp.Finalizers
+	for _, f := range w.FinalizerStages() {
+		setVarsFromDefaults(f.VarsFrom)
+	}
 	w.AnsibleVerbosity = getAnsibleVerbosity(gvk, ansibleVerbosityDefault)
 	w.Blacklist = tmp.Blacklist
-
-	wd, err := os.Getwd()
-	if err != nil {
-		return err
+	w.WorkerPool = tmp.WorkerPool
+	if w.WorkerPool != nil && w.WorkerPool.Size == 0 {
+		w.WorkerPool.Size = w.MaxConcurrentReconciles
 	}
-	w.addRolePlaybookPaths(wd)
+	w.Webhooks = tmp.Webhooks
+	w.Impersonation = tmp.Impersonation
+	w.APIAllowlist = tmp.APIAllowlist
+	if w.Impersonation != nil && w.Impersonation.ServiceAccount == "" {
+		w.Impersonation.ServiceAccount = impersonationServiceAccountDefault
+	}
+	w.SpecValidation = tmp.SpecValidation
+	if w.SpecValidation != nil && w.SpecValidation.EntryPoint == "" {
+		w.SpecValidation.EntryPoint = specValidationEntryPointDefault
+	}
+
+	w.addRolePlaybookPaths(rootDir)
 	w.Selector = parseLabelSelector(tmp.Selector)
 
 	return nil
 }
 
+// FinalizerStages - returns the stages of the finalizer of the watch in the order they run:
+// the Finalizers, or the single Finalizer. It returns nil if the watch has no finalizer.
+func (w *Watch) FinalizerStages() []*Finalizer {
+	if w.Finalizer != nil {
+		return []*Finalizer{w.Finalizer}
+	}
+	var stages []*Finalizer
+	for i := range w.Finalizers {
+		stages = append(stages, &w.Finalizers[i])
+	}
+	return stages
+}
+
+// RequiresProxyTokenAuth - returns whether the proxy must authenticate the requests of the
+// Ansible runs of the watch, which it does unless --proxy-token-auth=false. The impersonation and
+// API allowlist of the watch only apply to requests attributed to its CRs, which other
+// processes could otherwise bypass by not naming an owner or naming another one.
+func (w *Watch) RequiresProxyTokenAuth() bool {
+	return w.Impersonation != nil || len(w.APIAllowlist) > 0
+}
+
 // addRolePlaybookPaths will add the full path based on the current dir
 func (w *Watch) addRolePlaybookPaths(rootDir string) {
 	if len(w.Playbook) > 0 {
@@ -221,17 +537,40 @@ func (w *Watch) addRolePlaybookPaths(rootDir string) {
 			}
 		}
 	}
-	if w.Finalizer != nil && len(w.Finalizer.Role) > 0 {
-		possibleRolePaths := getPossibleRolePaths(rootDir, w.Finalizer.Role)
-		for _, possiblePath := range possibleRolePaths {
-			if _, err := os.Stat(possiblePath); err == nil {
-				w.Finalizer.Role = possiblePath
-				break
+	for _, f := range w.FinalizerStages() {
+		if len(f.Role) > 0 {
+			possibleRolePaths := getPossibleRolePaths(rootDir, f.Role)
+			for _, possiblePath := range possibleRolePaths {
+				if _, err := os.Stat(possiblePath); err == nil {
+					f.Role = possiblePath
+					break
+				}
 			}
 		}
+		if len(f.Playbook) > 0 {
+			f.Playbook = getFullPath(rootDir, f.Playbook)
+		}
+	}
+	if w.SpecValidation != nil && len(w.SpecValidation.File) > 0 {
+		w.SpecValidation.File = getFullPath(rootDir, w.SpecValidation.File)
 	}
-	if w.Finalizer != nil && len(w.Finalizer.Playbook) > 0 {
-		w.Finalizer.Playbook = getFullPath(rootDir, w.Finalizer.Playbook)
+	if w.Webhooks != nil {
+		for _, hook := range []*Webhook{w.Webhooks.Validating, w.Webhooks.Mutating} {
+			if hook == nil {
+				continue
+			}
+			if len(hook.Playbook) > 0 {
+				hook.Playbook = getFullPath(rootDir, hook.Playbook)
+			}
+			if len(hook.Role) > 0 {
+				for _, possiblePath := range getPossibleRolePaths(rootDir, hook.Role) {
+					if _, err := os.Stat(possiblePath); err == nil {
+						hook.Role = possiblePath
+						break
+					}
+				}
+			}
+		}
 	}
 }
 
@@ -284,29 +623,177 @@ func getPossibleRolePaths(rootDir, path string) []string {
 // A Watch is considered valid if it:
 // - Specifies a valid path to a Role||Playbook
 // - If a Finalizer is non-nil, it must have a name + valid path to a Role||Playbook or Vars
+// - Does not have both a Finalizer and Finalizers, which must have distinct names
+// - Has a RunTimeout that is not negative
+// - If a WorkerPool is non-nil, its size must not be negative
+// - If a SpecValidation is non-nil, it has a known source and its file exists
+// - Has a known RunPriorityClass
 func (w *Watch) Validate() error {
-	err := verifyAnsiblePath(w.Playbook, w.Role)
-	if err != nil {
-		log.Error(err, fmt.Sprintf("Invalid ansible path for GVK: %v", w.GroupVersionKind.String()))
-		return err
+	errs := w.validate()
+	if len(errs) == 0 {
+		return nil
 	}
+	log.Error(errs[0].err, fmt.Sprintf("%s for GVK: %v", errs[0].reason, w.GroupVersionKind.String()))
+	return errs[0].err
+}
 
-	if w.Finalizer != nil {
-		if w.Finalizer.Name == "" {
-			err = fmt.Errorf("finalizer must have name")
-			log.Error(err, fmt.Sprintf("Invalid finalizer for GVK: %v", w.GroupVersionKind.String()))
-			return err
+// fieldError - a reason a Watch is invalid, found at the path of keys and indexes of a
+// field in the watches file.
+type fieldError struct {
+	path   []string
+	reason string
+	err    error
+}
+
+// validate returns every reason w is invalid, in the order Validate checks them.
+func (w *Watch) validate() []fieldError {
+	var errs []fieldError
+	add := func(reason string, err error, path ...string) {
+		errs = append(errs, fieldError{path: path, reason: reason, err: err})
+	}
+
+	if err := verifyAnsiblePath(w.Playbook, w.Role); err != nil {
+		add("Invalid ansible path", err, ansiblePathKey(w.Playbook, w.Role))
+	}
+
+	if w.Finalizer != nil && len(w.Finalizers) > 0 {
+		add("Invalid finalizer", fmt.Errorf("finalizer and finalizers must not both be set"), "finalizers")
+	}
+	// The stages are reported at the key of the single finalizer, or at their index in the
+	// finalizers.
+	stage := func(i int, keys ...string) (string, []string) {
+		if w.Finalizer != nil {
+			return "finalizer", append([]string{"finalizer"}, keys...)
 		}
+		return fmt.Sprintf("finalizer %d", i), append([]string{"finalizers", strconv.Itoa(i)}, keys...)
+	}
+	names := map[string]bool{}
+	for i, f := range w.FinalizerStages() {
+		desc, path := stage(i)
+		if f.Name == "" {
+			add("Invalid finalizer", fmt.Errorf("%s must have name", desc), path...)
+		} else if names[f.Name] {
+			_, path := stage(i, "name")
+			add("Invalid finalizer", fmt.Errorf("%s has duplicate name %q", desc, f.Name), path...)
+		}
+		names[f.Name] = true
 		// only fail if Vars not set
-		err = verifyAnsiblePath(w.Finalizer.Playbook, w.Finalizer.Role)
-		if err != nil && len(w.Finalizer.Vars) == 0 {
-			log.Error(err, fmt.Sprintf("Invalid ansible path on Finalizer for GVK: %v",
-				w.GroupVersionKind.String()))
-			return err
+		err := verifyAnsiblePath(f.Playbook, f.Role)
+		if err != nil && len(f.Vars) == 0 && len(f.VarsFrom) == 0 {
+			_, path := stage(i, ansiblePathKey(f.Playbook, f.Role))
+			add("Invalid ansible path on Finalizer", err, path...)
+		}
+		if f.RunTimeout.Duration < 0 {
+			_, path := stage(i, "runTimeout")
+			add("Invalid finalizer", fmt.Errorf("%s run timeout must not be negative", desc), path...)
 		}
 	}
 
-	return nil
+	for i, v := range w.VarsFrom {
+		if err := v.validate(); err != nil {
+			add("Invalid varsFrom", fmt.Errorf("varsFrom %d: %w", i, err), "varsFrom", strconv.Itoa(i))
+		}
+	}
+	for i, f := range w.FinalizerStages() {
+		for j, v := range f.VarsFrom {
+			if err := v.validate(); err != nil {
+				desc, path := stage(i, "varsFrom", strconv.Itoa(j))
+				add("Invalid varsFrom", fmt.Errorf("%s varsFrom %d: %w", desc, j, err), path...)
+			}
+		}
+	}
+
+	if w.RunTimeout < 0 {
+		add("Invalid run timeout", fmt.Errorf("run timeout must not be negative"), "runTimeout")
+	}
+
+	if w.RunHistoryLimit < 0 {
+		add("Invalid run history limit", fmt.Errorf("run history limit must not be negative"), "runHistoryLimit")
+	}
+
+	if w.SkipUnchanged && !w.ManageStatus {
+		add("Invalid skipUnchanged", fmt.Errorf("skipping unchanged runs requires manageStatus"), "skipUnchanged")
+	}
+
+	if w.WorkerPool != nil && w.WorkerPool.Size < 0 {
+		add("Invalid worker pool", fmt.Errorf("worker pool size must not be negative"), "workerPool", "size")
+	}
+
+	if w.Webhooks != nil {
+		for _, hook := range []struct {
+			name    string
+			webhook *Webhook
+		}{{"validating", w.Webhooks.Validating}, {"mutating", w.Webhooks.Mutating}} {
+			if hook.webhook == nil {
+				continue
+			}
+			if err := verifyAnsiblePath(hook.webhook.Playbook, hook.webhook.Role); err != nil {
+				add(fmt.Sprintf("Invalid ansible path on %s webhook", hook.name), err, "webhooks", hook.name,
+					ansiblePathKey(hook.webhook.Playbook, hook.webhook.Role))
+			}
+		}
+	}
+
+	if w.Impersonation != nil {
+		if msgs := validation.IsDNS1123Subdomain(w.Impersonation.ServiceAccount); len(msgs) > 0 {
+			add("Invalid impersonation", fmt.Errorf("impersonation service account %q is invalid: %s",
+				w.Impersonation.ServiceAccount, strings.Join(msgs, ", ")), "impersonation", "serviceAccount")
+		}
+	}
+
+	for i, rule := range w.APIAllowlist {
+		path := []string{"apiAllowlist", strconv.Itoa(i)}
+		if rule.Kind == "" {
+			add("Invalid API allowlist", fmt.Errorf("API allowlist rule %d must have a kind", i), path...)
+		} else if len(rule.Verbs) == 0 {
+			add("Invalid API allowlist", fmt.Errorf("API allowlist rule %d must have verbs", i), path...)
+		}
+		for _, verb := range rule.Verbs {
+			if !apiRuleVerbs[verb] {
+				add("Invalid API allowlist", fmt.Errorf("API allowlist rule %d has unknown verb %q", i, verb),
+					append(path, "verbs")...)
+			}
+		}
+	}
+
+	if v := w.SpecValidation; v != nil {
+		switch v.Source {
+		case SpecValidationSourceCRD:
+			if v.File != "" {
+				add("Invalid spec validation", fmt.Errorf("spec validation file must only be set for source %q",
+					SpecValidationSourceFile), "specValidation", "file")
+			}
+		case SpecValidationSourceFile:
+			if v.File == "" {
+				add("Invalid spec validation", fmt.Errorf("spec validation file must be set for source %q",
+					SpecValidationSourceFile), "specValidation", "source")
+			} else if _, err := os.Stat(v.File); err != nil {
+				add("Invalid spec validation", fmt.Errorf("spec validation file: %s was not found", v.File),
+					"specValidation", "file")
+			}
+		default:
+			add("Invalid spec validation", fmt.Errorf("spec validation source %q must be %q or %q", v.Source,
+				SpecValidationSourceCRD, SpecValidationSourceFile), "specValidation", "source")
+		}
+	}
+
+	switch w.RunPriorityClass {
+	case RunPriorityClassHigh, RunPriorityClassNormal, RunPriorityClassLow:
+	default:
+		add("Invalid run priority class", fmt.Errorf("run priority class %q must be %q, %q or %q",
+			w.RunPriorityClass, RunPriorityClassHigh, RunPriorityClassNormal, RunPriorityClassLow),
+			"runPriorityClass")
+	}
+
+	return errs
+}
+
+// ansiblePathKey returns the key of the watches file verifyAnsiblePath reports about.
+func ansiblePathKey(playbook, role string) string {
+	if playbook == "" && role != "" {
+		return "role"
+	}
+	return "playbook"
 }
 
 // New - returns a Watch with sensible defaults.
@@ -320,11 +807,15 @@ func New(gvk schema.GroupVersionKind, role, playbook string, vars map[string]int
 		MaxRunnerArtifacts:          maxRunnerArtifactsDefault,
 		MaxConcurrentReconciles:     maxConcurrentReconcilesDefault,
 		ReconcilePeriod:             reconcilePeriodDefault.Duration,
+		RunTimeout:                  runTimeoutDefault.Duration,
 		ManageStatus:                manageStatusDefault,
 		WatchDependentResources:     watchDependentResourcesDefault,
 		WatchClusterScopedResources: watchClusterScopedResourcesDefault,
 		SnakeCaseParameters:         snakeCaseParametersDefault,
 		MarkUnsafe:                  markUnsafeDefault,
+		RecordEvents:                recordEventsDefault,
+		SkipUnchanged:               skipUnchangedDefault,
+		RunPriorityClass:            runPriorityClassDefault,
 		Finalizer:                   finalizer,
 		AnsibleVerbosity:            ansibleVerbosityDefault,
 		Selector:                    selectorDefault,
@@ -333,11 +824,28 @@ func New(gvk schema.GroupVersionKind, role, playbook string, vars map[string]int
 
 // Load - loads a slice of Watches from the watches file from the CLI
 func Load(path string, maxReconciler, ansibleVerbosity int) ([]Watch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Error(err, "Failed to get config file")
+		return nil, err
+	}
+	w, err := LoadReader(f, maxReconciler, ansibleVerbosity)
+
+	// Make sure to close the file, regardless of the error returned by
+	// LoadReader.
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("could not close watches file: %w", err)
+	}
+	return w, err
+}
+
+// LoadReader - loads the watches from reader, like Load does from a file.
+func LoadReader(reader io.Reader, maxReconciler, ansibleVerbosity int) ([]Watch, error) {
 	maxConcurrentReconcilesDefault = maxReconciler
 	ansibleVerbosityDefault = ansibleVerbosity
-	b, err := ioutil.ReadFile(path)
+	b, err := ioutil.ReadAll(reader)
 	if err != nil {
-		log.Error(err, "Failed to get config file")
+		log.Error(err, "Failed to read config")
 		return nil, err
 	}
 
@@ -22,6 +22,7 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -85,6 +86,9 @@ func TestNew(t *testing.T) {
 			if watch.MarkUnsafe != markUnsafeDefault {
 				t.Fatalf("Unexpected markUnsafe %v expected %v", watch.MarkUnsafe, markUnsafeDefault)
 			}
+			if watch.RecordEvents != recordEventsDefault {
+				t.Fatalf("Unexpected recordEvents %v expected %v", watch.RecordEvents, recordEventsDefault)
+			}
 			if watch.WatchClusterScopedResources != watchClusterScopedResourcesDefault {
 				t.Fatalf("Unexpected watchClusterScopedResources %v expected %v",
 					watch.WatchClusterScopedResources, watchClusterScopedResourcesDefault)
@@ -382,6 +386,155 @@ func TestLoad(t *testing.T) { //nolint:gocyclo
 			},
 			ManageStatus: true,
 		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "WorkerPoolTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			WorkerPool:   &WorkerPool{Size: 3},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "WorkerPoolDefaultSize",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			WorkerPool:   &WorkerPool{Size: 1},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "RunTimeoutTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			RunTimeout:   twoSeconds,
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "WebhooksTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			Webhooks: &Webhooks{
+				Validating: &Webhook{Playbook: validTemplate.ValidPlaybook},
+				Mutating: &Webhook{
+					Role: validTemplate.ValidRole,
+					Vars: map[string]interface{}{"sentinel": "mutating"},
+				},
+			},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "ImpersonationTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			Impersonation: &Impersonation{
+				ServiceAccountField: "spec.serviceAccountName",
+				ServiceAccount:      "default",
+			},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "APIAllowlistTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			APIAllowlist: []APIRule{
+				{Kind: "ConfigMap", Verbs: []string{"get", "list", "watch", "create"}},
+				{Group: "apps", Version: "v1", Kind: "Deployment", Verbs: []string{"*"},
+					Subresources: []string{"status"}},
+			},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "RecordEventsTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			RecordEvents: true,
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "RunHistoryTest",
+			},
+			Role:            validTemplate.ValidRole,
+			ManageStatus:    true,
+			RunHistoryLimit: 5,
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "SkipUnchangedTest",
+			},
+			Role:          validTemplate.ValidRole,
+			ManageStatus:  true,
+			SkipUnchanged: true,
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "VarsFromTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			VarsFrom: []VarsFrom{
+				{
+					SecretRef:         &ObjectRef{Name: "db-credentials"},
+					Namespace:         VarsFromOperatorNamespace,
+					Prefix:            "db_",
+					ReconcileOnChange: true,
+				},
+				{
+					ConfigMapRef: &ObjectRef{Name: "settings"},
+					Namespace:    VarsFromCRNamespace,
+					Optional:     true,
+				},
+			},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "SpecValidationTest",
+			},
+			Role:         validTemplate.ValidRole,
+			ManageStatus: true,
+			SpecValidation: &SpecValidation{
+				Source:     SpecValidationSourceFile,
+				File:       filepath.Join(cwd, "testdata", "spec_schema.yaml"),
+				EntryPoint: "main",
+			},
+		},
+		Watch{
+			GroupVersionKind: schema.GroupVersionKind{
+				Version: "v1alpha1",
+				Group:   "app.example.com",
+				Kind:    "RunPriorityClassTest",
+			},
+			Role:             validTemplate.ValidRole,
+			ManageStatus:     true,
+			RunPriorityClass: RunPriorityClassHigh,
+		},
 	}
 
 	testCases := []struct {
@@ -419,6 +572,66 @@ func TestLoad(t *testing.T) { //nolint:gocyclo
 			path:        "testdata/invalid_finalizer_playbook_path.yaml",
 			shouldError: true,
 		},
+		{
+			name:        "error invalid playbook webhook path",
+			path:        "testdata/invalid_webhook_playbook_path.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error invalid impersonation service account",
+			path:        "testdata/invalid_impersonation_service_account.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error negative run history limit",
+			path:        "testdata/invalid_run_history_limit.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error skip unchanged without managed status",
+			path:        "testdata/invalid_skip_unchanged.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error invalid varsFrom",
+			path:        "testdata/invalid_vars_from.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error duplicate finalizer stage names",
+			path:        "testdata/invalid_finalizers_duplicate_name.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error finalizer and finalizers",
+			path:        "testdata/invalid_finalizer_and_finalizers.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error negative finalizer stage run timeout",
+			path:        "testdata/invalid_finalizers_run_timeout.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error unknown spec validation source",
+			path:        "testdata/invalid_spec_validation_source.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error spec validation file not found",
+			path:        "testdata/invalid_spec_validation_file.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error unknown run priority class",
+			path:        "testdata/invalid_run_priority_class.yaml",
+			shouldError: true,
+		},
+		{
+			name:        "error invalid API allowlist verb",
+			path:        "testdata/invalid_api_allowlist_verb.yaml",
+			shouldError: true,
+		},
 		{
 			name:        "error invalid finalizer whithout name",
 			path:        "testdata/invalid_finalizer_whithout_name.yaml",
@@ -551,10 +764,35 @@ func TestLoad(t *testing.T) { //nolint:gocyclo
 					t.Fatalf("The GVK: %v unexpected reconcile period: %v expected reconcile period: %v", gvk,
 						gotWatch.ReconcilePeriod, expectedWatch.ReconcilePeriod)
 				}
+				if gotWatch.RunTimeout != expectedWatch.RunTimeout {
+					t.Fatalf("The GVK: %v unexpected run timeout: %v expected run timeout: %v", gvk,
+						gotWatch.RunTimeout, expectedWatch.RunTimeout)
+				}
 				if gotWatch.MarkUnsafe != expectedWatch.MarkUnsafe {
 					t.Fatalf("The GVK: %v unexpected mark unsafe: %v expected mark unsafe: %v", gvk,
 						gotWatch.MarkUnsafe, expectedWatch.MarkUnsafe)
 				}
+				if gotWatch.RecordEvents != expectedWatch.RecordEvents {
+					t.Fatalf("The GVK: %v unexpected record events: %v expected record events: %v", gvk,
+						gotWatch.RecordEvents, expectedWatch.RecordEvents)
+				}
+				if gotWatch.RunHistoryLimit != expectedWatch.RunHistoryLimit {
+					t.Fatalf("The GVK: %v unexpected run history limit: %v expected run history limit: %v", gvk,
+						gotWatch.RunHistoryLimit, expectedWatch.RunHistoryLimit)
+				}
+				if gotWatch.SkipUnchanged != expectedWatch.SkipUnchanged {
+					t.Fatalf("The GVK: %v unexpected skip unchanged: %v expected skip unchanged: %v", gvk,
+						gotWatch.SkipUnchanged, expectedWatch.SkipUnchanged)
+				}
+
+				expectedClass := expectedWatch.RunPriorityClass
+				if expectedClass == "" {
+					expectedClass = RunPriorityClassNormal
+				}
+				if gotWatch.RunPriorityClass != expectedClass {
+					t.Fatalf("The GVK: %v unexpected run priority class: %v expected run priority class: %v", gvk,
+						gotWatch.RunPriorityClass, expectedClass)
+				}
 
 				for i, val := range expectedWatch.Blacklist {
 					if val != gotWatch.Blacklist[i] {
@@ -563,6 +801,36 @@ func TestLoad(t *testing.T) { //nolint:gocyclo
 					}
 				}
 
+				if !reflect.DeepEqual(gotWatch.WorkerPool, expectedWatch.WorkerPool) {
+					t.Fatalf("Incorrect worker pool GVK %s:\n\tgot %+v\n\texpected %+v", gvk,
+						gotWatch.WorkerPool, expectedWatch.WorkerPool)
+				}
+
+				if !reflect.DeepEqual(gotWatch.Webhooks, expectedWatch.Webhooks) {
+					t.Fatalf("Incorrect webhooks GVK %s:\n\tgot %+v\n\texpected %+v", gvk,
+						gotWatch.Webhooks, expectedWatch.Webhooks)
+				}
+
+				if !reflect.DeepEqual(gotWatch.Impersonation, expectedWatch.Impersonation) {
+					t.Fatalf("Incorrect impersonation GVK %s:\n\tgot %+v\n\texpected %+v", gvk,
+						gotWatch.Impersonation, expectedWatch.Impersonation)
+				}
+
+				if !reflect.DeepEqual(gotWatch.VarsFrom, expectedWatch.VarsFrom) {
+					t.Fatalf("Incorrect varsFrom GVK %s:\n\tgot %+v\n\texpected %+v", gvk,
+						gotWatch.VarsFrom, expectedWatch.VarsFrom)
+				}
+
+				if !reflect.DeepEqual(gotWatch.APIAllowlist, expectedWatch.APIAllowlist) {
+					t.Fatalf("Incorrect API allowlist GVK %s:\n\tgot %+v\n\texpected %+v", gvk,
+						gotWatch.APIAllowlist, expectedWatch.APIAllowlist)
+				}
+
+				if !reflect.DeepEqual(gotWatch.SpecValidation, expectedWatch.SpecValidation) {
+					t.Fatalf("Incorrect spec validation GVK %s:\n\tgot %+v\n\texpected %+v", gvk,
+						gotWatch.SpecValidation, expectedWatch.SpecValidation)
+				}
+
 				if !reflect.DeepEqual(gotWatch.Selector, expectedWatch.Selector) {
 					t.Fatalf("Incorrect selector GVK %s:\n\tgot %s\n\texpected %s", gvk,
 						gotWatch.Selector, expectedWatch.Selector)
@@ -892,3 +1160,166 @@ func TestGetPossibleRolePaths(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIRuleAllows(t *testing.T) {
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	testCases := []struct {
+		name        string
+		rule        APIRule
+		gvk         schema.GroupVersionKind
+		subresource string
+		verb        string
+		expected    bool
+	}{
+		{
+			name:     "allowed verb",
+			rule:     APIRule{Kind: "ConfigMap", Verbs: []string{"get", "list"}},
+			gvk:      configMap,
+			verb:     "list",
+			expected: true,
+		},
+		{
+			name: "other verb",
+			rule: APIRule{Kind: "ConfigMap", Verbs: []string{"get", "list"}},
+			gvk:  configMap,
+			verb: "delete",
+		},
+		{
+			name: "other group",
+			rule: APIRule{Kind: "Deployment", Verbs: []string{"*"}},
+			gvk:  deployment,
+			verb: "get",
+		},
+		{
+			name: "other version",
+			rule: APIRule{Group: "apps", Version: "v1beta1", Kind: "Deployment", Verbs: []string{"*"}},
+			gvk:  deployment,
+			verb: "get",
+		},
+		{
+			name:     "wildcards",
+			rule:     APIRule{Group: "*", Kind: "*", Verbs: []string{"*"}},
+			gvk:      deployment,
+			verb:     "patch",
+			expected: true,
+		},
+		{
+			name:        "unlisted subresource",
+			rule:        APIRule{Group: "apps", Kind: "Deployment", Verbs: []string{"*"}},
+			gvk:         deployment,
+			subresource: "scale",
+			verb:        "update",
+		},
+		{
+			name:        "listed subresource",
+			rule:        APIRule{Group: "apps", Kind: "Deployment", Verbs: []string{"update"}, Subresources: []string{"status"}},
+			gvk:         deployment,
+			subresource: "status",
+			verb:        "update",
+			expected:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if allowed := tc.rule.Allows(tc.gvk, tc.subresource, tc.verb); allowed != tc.expected {
+				t.Fatalf("Unexpected result %v, expected %v", allowed, tc.expected)
+			}
+		})
+	}
+}
+
+func TestFinalizerStages(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watches, err := LoadReader(strings.NewReader(`---
+- version: v1alpha1
+  group: app.example.com
+  kind: Database
+  playbook: testdata/playbook.yml
+  runTimeout: 5m
+  finalizers:
+    - name: app.example.com/drain
+      vars:
+        state: drained
+    - name: app.example.com/backup
+      role: `+filepath.Join(wd, "testdata", "roles", "role")+`
+      runTimeout: 30m
+    - name: app.example.com/delete
+      playbook: testdata/playbook.yml
+      varsFrom:
+        - secretRef:
+            name: credentials
+`), 1, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stages := watches[0].FinalizerStages()
+	var names []string
+	for _, f := range stages {
+		names = append(names, f.Name)
+	}
+	expected := []string{"app.example.com/drain", "app.example.com/backup", "app.example.com/delete"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Unexpected stages %v, expected %v", names, expected)
+	}
+	if stages[1].Role != filepath.Join(wd, "testdata", "roles", "role") {
+		t.Fatalf("Unexpected role %q of stage %q", stages[1].Role, stages[1].Name)
+	}
+	if stages[1].RunTimeout.Duration != 30*time.Minute || stages[0].RunTimeout.Duration != 0 {
+		t.Fatalf("Unexpected run timeouts %v and %v", stages[0].RunTimeout, stages[1].RunTimeout)
+	}
+	if stages[2].Playbook != filepath.Join(wd, "testdata", "playbook.yml") {
+		t.Fatalf("Unexpected playbook %q of stage %q", stages[2].Playbook, stages[2].Name)
+	}
+	if stages[2].VarsFrom[0].Namespace != VarsFromOperatorNamespace {
+		t.Fatalf("Unexpected varsFrom namespace %q", stages[2].VarsFrom[0].Namespace)
+	}
+
+	single := New(schema.GroupVersionKind{Version: "v1", Kind: "Single"}, "", "playbook.yml", nil,
+		&Finalizer{Name: "finalizer"})
+	if stages := single.FinalizerStages(); len(stages) != 1 || stages[0] != single.Finalizer {
+		t.Fatalf("Unexpected stages %v of single finalizer", stages)
+	}
+	if stages := New(schema.GroupVersionKind{Version: "v1", Kind: "None"}, "", "playbook.yml", nil,
+		nil).FinalizerStages(); stages != nil {
+		t.Fatalf("Unexpected stages %v without finalizer", stages)
+	}
+}
+
+func TestRequiresProxyTokenAuth(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "app.example.com", Version: "v1alpha1", Kind: "Database"}
+	testCases := []struct {
+		name     string
+		modify   func(*Watch)
+		expected bool
+	}{
+		{
+			name:   "no impersonation or allowlist",
+			modify: func(*Watch) {},
+		},
+		{
+			name:     "impersonation",
+			modify:   func(w *Watch) { w.Impersonation = &Impersonation{ServiceAccount: "default"} },
+			expected: true,
+		},
+		{
+			name: "API allowlist",
+			modify: func(w *Watch) {
+				w.APIAllowlist = []APIRule{{Group: "", Kind: "ConfigMap", Verbs: []string{"get"}}}
+			},
+			expected: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := New(gvk, "", "playbook.yml", nil, nil)
+			tc.modify(w)
+			if actual := w.RequiresProxyTokenAuth(); actual != tc.expected {
+				t.Fatalf("Unexpected %v, expected %v", actual, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webhook serves admission webhooks backed by Ansible playbooks and roles.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+var log = logf.Log.WithName("webhook")
+
+// AdmissionStatsKey is the key of the set_stats data through which the playbook or role
+// of a webhook returns its admission decision, e.g. with the arguments of set_stats:
+//
+//	data:
+//	  admission:
+//	    allowed: false
+//	    message: "spec.size must not exceed 10"
+//
+// A mutating webhook returns the JSON patch to apply to the object in "patch".
+const AdmissionStatsKey = "admission"
+
+// ValidatingPath returns the path the validating webhook of gvk is served at.
+func ValidatingPath(gvk schema.GroupVersionKind) string {
+	return "/validate-" + pathSuffix(gvk)
+}
+
+// MutatingPath returns the path the mutating webhook of gvk is served at.
+func MutatingPath(gvk schema.GroupVersionKind) string {
+	return "/mutate-" + pathSuffix(gvk)
+}
+
+func pathSuffix(gvk schema.GroupVersionKind) string {
+	return fmt.Sprintf("%s-%s-%s", strings.Replace(gvk.Group, ".", "-", -1), gvk.Version, strings.ToLower(gvk.Kind))
+}
+
+// Server serves the webhooks of the watches from the webhook server of a manager.
+type Server struct {
+	mgr         manager.Manager
+	ansibleArgs string
+	kubeconfigs *kubeconfig.Issuer
+
+	mu         sync.RWMutex
+	watches    map[schema.GroupVersionKind]watches.Watch
+	registered map[string]bool
+}
+
+// NewServer returns a Server that serves webhooks from the webhook server of mgr.
+// ansibleArgs are passed to every run, like the --ansible-args of reconciles. Runs reach
+// the proxy through the kubeconfigs of kubeconfigs, or of the default proxy if nil.
+func NewServer(mgr manager.Manager, ansibleArgs string, kubeconfigs *kubeconfig.Issuer) *Server {
+	if kubeconfigs == nil {
+		kubeconfigs = &kubeconfig.Issuer{}
+	}
+	return &Server{
+		mgr:         mgr,
+		ansibleArgs: ansibleArgs,
+		kubeconfigs: kubeconfigs,
+		watches:     map[schema.GroupVersionKind]watches.Watch{},
+		registered:  map[string]bool{},
+	}
+}
+
+// Update serves the webhooks of ws. The webhook server is only started once a watch has
+// webhooks. Since paths cannot be unregistered, the paths of removed webhooks stay
+// registered and allow all requests.
+func (s *Server) Update(ws []watches.Watch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watches = map[schema.GroupVersionKind]watches.Watch{}
+	for _, w := range ws {
+		if w.Webhooks == nil {
+			continue
+		}
+		s.watches[w.GroupVersionKind] = w
+		if w.Webhooks.Validating != nil {
+			s.register(ValidatingPath(w.GroupVersionKind), &handler{server: s, gvk: w.GroupVersionKind,
+				newRunner: runner.NewWebhook})
+		}
+		if w.Webhooks.Mutating != nil {
+			s.register(MutatingPath(w.GroupVersionKind), &handler{server: s, gvk: w.GroupVersionKind, mutating: true,
+				newRunner: runner.NewWebhook})
+		}
+	}
+}
+
+func (s *Server) register(path string, h *handler) {
+	if s.registered[path] {
+		return
+	}
+	s.registered[path] = true
+	s.mgr.GetWebhookServer().Register(path, &webhook.Admission{Handler: h})
+}
+
+// get returns the current watch of gvk and its validating or mutating webhook.
+func (s *Server) get(gvk schema.GroupVersionKind, mutating bool) (watches.Watch, *watches.Webhook) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, ok := s.watches[gvk]
+	if !ok {
+		return w, nil
+	}
+	if mutating {
+		return w, w.Webhooks.Mutating
+	}
+	return w, w.Webhooks.Validating
+}
+
+// decision is the admission decision returned by a playbook or role in the set_stats
+// data AdmissionStatsKey.
+type decision struct {
+	Allowed *bool           `json:"allowed"`
+	Message string          `json:"message"`
+	Patch   json.RawMessage `json:"patch"`
+}
+
+// handler handles the admission requests of the validating or mutating webhook of a GVK.
+type handler struct {
+	server   *Server
+	gvk      schema.GroupVersionKind
+	mutating bool
+	// newRunner returns the Runner of a request, runner.NewWebhook outside of tests.
+	newRunner func(watches.Watch, watches.Webhook, map[string]interface{}, string) (runner.Runner, error)
+}
+
+func (h *handler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	watch, hook := h.server.get(h.gvk, h.mutating)
+	if hook == nil {
+		return admission.Allowed("no webhook is configured")
+	}
+	ident := strconv.Itoa(rand.Int())
+	logger := log.WithValues(
+		"job", ident,
+		"webhook", h.kind(),
+		"GVK", h.gvk.String(),
+		"name", req.Name,
+		"namespace", req.Namespace,
+		"operation", req.Operation,
+	)
+
+	// The object being deleted is only available as the old object.
+	raw := req.Object.Raw
+	if req.Operation == admissionv1.Delete {
+		raw = req.OldObject.Raw
+	}
+	u := &unstructured.Unstructured{}
+	if len(raw) > 0 {
+		if err := u.UnmarshalJSON(raw); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	review, err := reviewToMap(req)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	r, err := h.newRunner(watch, *hook, review, h.server.ansibleArgs)
+	if err != nil {
+		logger.Error(err, "Failed to create webhook runner")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	// The kubeconfig names the watch and the object, so that the proxy applies the
+	// impersonation and the API allowlist of the watch to the requests of the run.
+	owner := &kubeconfig.NamespacedOwnerReference{
+		OwnerReference: metav1.OwnerReference{
+			APIVersion: h.gvk.GroupVersion().String(),
+			Kind:       h.gvk.Kind,
+			Name:       req.Name,
+			UID:        u.GetUID(),
+		},
+		Namespace: req.Namespace,
+		Ident:     ident,
+		Webhook:   true,
+	}
+	if watch.Impersonation != nil {
+		if owner.ServiceAccount, err = watch.Impersonation.ServiceAccountOf(u); err != nil {
+			logger.Info("Denied admission request", "reason", err.Error())
+			return admission.Denied(fmt.Sprintf("unable to determine the ServiceAccount to impersonate: %v", err))
+		}
+	}
+	kc, revoke, err := h.server.kubeconfigs.CreateForOwner(owner, req.Namespace)
+	if err != nil {
+		logger.Error(err, "Failed to create kubeconfig")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	defer revoke()
+	defer func() {
+		if err := os.Remove(kc.Name()); err != nil {
+			logger.Error(err, "Failed to remove generated kubeconfig file")
+		}
+	}()
+
+	result, err := r.Run(ctx, ident, u, kc.Name())
+	if err != nil {
+		logger.Error(err, "Failed to run webhook")
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// Drain all the events of the run, which blocks until the run has finished.
+	d := decision{}
+	var decisionErr error
+	statsEvent := false
+	failureMessages := eventapi.FailureMessages{}
+	taskMetrics := runner.NewTaskMetrics(h.gvk)
+	for event := range result.Events() {
+		taskMetrics.Observe(event)
+		if event.Event == eventapi.EventRunnerOnFailed && !event.IgnoreError() && !event.Rescued() {
+			failureMessages = append(failureMessages, event.GetFailedPlaybookMessage())
+		}
+		if event.Event == eventapi.EventPlaybookOnStats {
+			statsEvent = true
+			decisionErr = decisionFromStats(event, &d)
+		}
+	}
+	if decisionErr != nil {
+		logger.Error(decisionErr, "Invalid admission decision")
+		return admission.Errored(http.StatusInternalServerError, decisionErr)
+	}
+	if ctx.Err() != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("webhook run was cancelled: %w", ctx.Err()))
+	}
+	// Without the stats event the run did not finish, e.g. because ansible-runner crashed
+	// or could not start the playbook. The request is errored rather than allowed, so that
+	// the API server applies the failurePolicy of the webhook.
+	if !statsEvent {
+		err := errors.New("did not receive playbook_on_stats event")
+		if stdout, stdoutErr := result.Stdout(); stdoutErr == nil && stdout != "" {
+			logger.Error(err, "Failed to run webhook", "stdout", stdout)
+		} else {
+			logger.Error(err, "Failed to run webhook")
+		}
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if len(failureMessages) > 0 {
+		logger.Info("Denied admission request", "reason", failureMessages[0])
+		return admission.Denied(strings.Join(failureMessages, "; "))
+	}
+	if d.Allowed != nil && !*d.Allowed {
+		logger.Info("Denied admission request", "reason", d.Message)
+		return admission.Denied(d.Message)
+	}
+	resp := admission.Allowed(d.Message)
+	if h.mutating && len(d.Patch) > 0 {
+		patchType := admissionv1.PatchTypeJSONPatch
+		resp.Patch = d.Patch
+		resp.PatchType = &patchType
+	}
+	return resp
+}
+
+func (h *handler) kind() string {
+	if h.mutating {
+		return "mutating"
+	}
+	return "validating"
+}
+
+// reviewToMap returns the AdmissionReview of req as the map passed to Ansible.
+func reviewToMap(req admission.Request) (map[string]interface{}, error) {
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionv1.SchemeGroupVersion.String(),
+			Kind:       "AdmissionReview",
+		},
+		Request: &req.AdmissionRequest,
+	}
+	data, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decisionFromStats reads the decision set by set_stats from the playbook_on_stats event.
+func decisionFromStats(event eventapi.JobEvent, d *decision) error {
+	artifactData, ok := event.EventData["artifact_data"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	data, ok := artifactData[AdmissionStatsKey]
+	if !ok {
+		return nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, d); err != nil {
+		return fmt.Errorf("invalid set_stats data %q: %w", AdmissionStatsKey, err)
+	}
+	if len(d.Patch) > 0 && string(d.Patch) != "null" {
+		ops := []interface{}{}
+		if err := json.Unmarshal(d.Patch, &ops); err != nil {
+			return fmt.Errorf("patch must be a list of JSON patch operations: %w", err)
+		}
+	} else {
+		d.Patch = nil
+	}
+	return nil
+}
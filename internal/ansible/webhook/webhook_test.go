@@ -0,0 +1,319 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/fake"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+func TestPaths(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	if p := ValidatingPath(gvk); p != "/validate-cache-example-com-v1alpha1-memcached" {
+		t.Fatalf("Unexpected validating path %q", p)
+	}
+	if p := MutatingPath(gvk); p != "/mutate-cache-example-com-v1alpha1-memcached" {
+		t.Fatalf("Unexpected mutating path %q", p)
+	}
+}
+
+func TestDecisionFromStats(t *testing.T) {
+	testCases := []struct {
+		name            string
+		eventData       map[string]interface{}
+		expectedAllowed *bool
+		expectedMessage string
+		expectedPatch   string
+		expectErr       bool
+	}{
+		{
+			name:      "no artifact data",
+			eventData: map[string]interface{}{},
+		},
+		{
+			name: "no admission decision",
+			eventData: map[string]interface{}{
+				"artifact_data": map[string]interface{}{"other": "value"},
+			},
+		},
+		{
+			name: "denied",
+			eventData: map[string]interface{}{
+				"artifact_data": map[string]interface{}{
+					AdmissionStatsKey: map[string]interface{}{"allowed": false, "message": "too large"},
+				},
+			},
+			expectedAllowed: boolPtr(false),
+			expectedMessage: "too large",
+		},
+		{
+			name: "patch",
+			eventData: map[string]interface{}{
+				"artifact_data": map[string]interface{}{
+					AdmissionStatsKey: map[string]interface{}{
+						"patch": []interface{}{
+							map[string]interface{}{"op": "add", "path": "/spec/size", "value": 3},
+						},
+					},
+				},
+			},
+			expectedPatch: `[{"op":"add","path":"/spec/size","value":3}]`,
+		},
+		{
+			name: "invalid patch",
+			eventData: map[string]interface{}{
+				"artifact_data": map[string]interface{}{
+					AdmissionStatsKey: map[string]interface{}{"patch": "not a patch"},
+				},
+			},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := decision{}
+			err := decisionFromStats(eventapi.JobEvent{Event: eventapi.EventPlaybookOnStats, EventData: tc.eventData}, &d)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (d.Allowed == nil) != (tc.expectedAllowed == nil) ||
+				(d.Allowed != nil && *d.Allowed != *tc.expectedAllowed) {
+				t.Fatalf("Unexpected allowed %v, expected %v", d.Allowed, tc.expectedAllowed)
+			}
+			if d.Message != tc.expectedMessage {
+				t.Fatalf("Unexpected message %q, expected %q", d.Message, tc.expectedMessage)
+			}
+			if string(d.Patch) != tc.expectedPatch {
+				t.Fatalf("Unexpected patch %s, expected %s", d.Patch, tc.expectedPatch)
+			}
+		})
+	}
+}
+
+func TestHandleWithoutWebhook(t *testing.T) {
+	s := NewServer(nil, "", nil)
+	h := &handler{
+		server: s,
+		gvk:    schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"},
+	}
+	resp := h.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+	}})
+	if !resp.Allowed {
+		t.Fatalf("Expected requests to be allowed without a webhook, got %+v", resp.Result)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	testCases := []struct {
+		name            string
+		runner          *fake.Runner
+		expectedAllowed bool
+		expectedCode    int32
+	}{
+		{
+			name: "allowed",
+			runner: &fake.Runner{JobEvents: []eventapi.JobEvent{
+				{Event: eventapi.EventPlaybookOnStats, EventData: map[string]interface{}{}},
+			}},
+			expectedAllowed: true,
+			expectedCode:    http.StatusOK,
+		},
+		{
+			name: "denied",
+			runner: &fake.Runner{JobEvents: []eventapi.JobEvent{
+				{Event: eventapi.EventPlaybookOnStats, EventData: map[string]interface{}{
+					"artifact_data": map[string]interface{}{
+						AdmissionStatsKey: map[string]interface{}{"allowed": false, "message": "too large"},
+					},
+				}},
+			}},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "no stats event",
+			runner:       &fake.Runner{},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewServer(nil, "", nil)
+			s.watches[gvk] = watches.Watch{
+				GroupVersionKind: gvk,
+				Webhooks:         &watches.Webhooks{Validating: &watches.Webhook{Playbook: "validate.yml"}},
+			}
+			h := &handler{
+				server: s,
+				gvk:    gvk,
+				newRunner: func(watches.Watch, watches.Webhook, map[string]interface{}, string) (runner.Runner, error) {
+					return tc.runner, nil
+				},
+			}
+			resp := h.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Namespace: "default",
+				Name:      "example",
+			}})
+			if resp.Allowed != tc.expectedAllowed {
+				t.Fatalf("Unexpected allowed %v, expected %v: %+v", resp.Allowed, tc.expectedAllowed, resp.Result)
+			}
+			if resp.Result.Code != tc.expectedCode {
+				t.Fatalf("Unexpected code %d, expected %d: %+v", resp.Result.Code, tc.expectedCode, resp.Result)
+			}
+		})
+	}
+}
+
+// kubeconfigRunner - a fake runner that records the owner of the token of the kubeconfig
+// of its run.
+type kubeconfigRunner struct {
+	*fake.Runner
+	tokens *kubeconfig.Tokens
+	owner  *kubeconfig.NamespacedOwnerReference
+}
+
+func (r *kubeconfigRunner) Run(ctx context.Context, ident string, u *unstructured.Unstructured,
+	kubeconfigPath string) (runner.RunResult, error) {
+	b, err := ioutil.ReadFile(kubeconfigPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if token := strings.TrimPrefix(strings.TrimSpace(line), "token: "); token != strings.TrimSpace(line) {
+			r.owner, _ = r.tokens.Lookup(token)
+		}
+	}
+	return r.Runner.Run(ctx, ident, u, kubeconfigPath)
+}
+
+func TestHandleKubeconfigOwner(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "cache.example.com", Version: "v1alpha1", Kind: "Memcached"}
+	testCases := []struct {
+		name                   string
+		object                 string
+		expectedServiceAccount string
+		expectedDenied         bool
+	}{
+		{
+			name:                   "service account named in object",
+			object:                 `{"apiVersion":"cache.example.com/v1alpha1","kind":"Memcached","metadata":{"name":"example","namespace":"tenant"},"spec":{"serviceAccountName":"deployer"}}`,
+			expectedServiceAccount: "deployer",
+		},
+		{
+			name:                   "default service account",
+			object:                 `{"apiVersion":"cache.example.com/v1alpha1","kind":"Memcached","metadata":{"name":"example","namespace":"tenant"},"spec":{}}`,
+			expectedServiceAccount: "default",
+		},
+		{
+			name:           "invalid service account",
+			object:         `{"apiVersion":"cache.example.com/v1alpha1","kind":"Memcached","metadata":{"name":"example","namespace":"tenant"},"spec":{"serviceAccountName":"Not_Valid"}}`,
+			expectedDenied: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := kubeconfig.NewTokens()
+			s := NewServer(nil, "", &kubeconfig.Issuer{Tokens: tokens})
+			s.watches[gvk] = watches.Watch{
+				GroupVersionKind: gvk,
+				Webhooks:         &watches.Webhooks{Validating: &watches.Webhook{Playbook: "validate.yml"}},
+				Impersonation: &watches.Impersonation{
+					ServiceAccountField: "spec.serviceAccountName",
+					ServiceAccount:      "default",
+				},
+			}
+			r := &kubeconfigRunner{
+				Runner: &fake.Runner{JobEvents: []eventapi.JobEvent{
+					{Event: eventapi.EventPlaybookOnStats, EventData: map[string]interface{}{}},
+				}},
+				tokens: tokens,
+			}
+			h := &handler{
+				server: s,
+				gvk:    gvk,
+				newRunner: func(watches.Watch, watches.Webhook, map[string]interface{}, string) (runner.Runner, error) {
+					return r, nil
+				},
+			}
+			resp := h.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Namespace: "tenant",
+				Name:      "example",
+				Object:    runtime.RawExtension{Raw: []byte(tc.object)},
+			}})
+			if tc.expectedDenied {
+				if resp.Allowed || resp.Result.Code != http.StatusForbidden || r.owner != nil {
+					t.Fatalf("Expected request to be denied without a run, got %+v", resp.Result)
+				}
+				return
+			}
+			if !resp.Allowed {
+				t.Fatalf("Expected request to be allowed, got %+v", resp.Result)
+			}
+			// The token names the watch and the object, so that the proxy applies the
+			// impersonation and the API allowlist of the watch.
+			if r.owner == nil || !r.owner.Webhook || r.owner.Kind != gvk.Kind ||
+				r.owner.APIVersion != gvk.GroupVersion().String() || r.owner.Namespace != "tenant" ||
+				r.owner.Name != "example" || r.owner.ServiceAccount != tc.expectedServiceAccount {
+				t.Fatalf("Unexpected owner of the token of the run: %+v", r.owner)
+			}
+		})
+	}
+}
+
+func TestReviewToMap(t *testing.T) {
+	review, err := reviewToMap(admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:       "uid",
+		Operation: admissionv1.Update,
+		Name:      "example",
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if review["kind"] != "AdmissionReview" || review["apiVersion"] != "admission.k8s.io/v1" {
+		t.Fatalf("Unexpected type of review: %v", review)
+	}
+	req, ok := review["request"].(map[string]interface{})
+	if !ok || req["operation"] != "UPDATE" || req["name"] != "example" {
+		t.Fatalf("Unexpected request of review: %v", review["request"])
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
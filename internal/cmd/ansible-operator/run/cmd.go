@@ -15,20 +15,25 @@
 package run
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"net/url"
+	"io"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	zapf "sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -36,14 +41,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
+	"github.com/operator-framework/operator-sdk/internal/ansible/artifacts"
 	"github.com/operator-framework/operator-sdk/internal/ansible/controller"
+	"github.com/operator-framework/operator-sdk/internal/ansible/eventstream"
 	"github.com/operator-framework/operator-sdk/internal/ansible/flags"
+	"github.com/operator-framework/operator-sdk/internal/ansible/handler"
 	"github.com/operator-framework/operator-sdk/internal/ansible/metrics"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy"
 	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
 	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/specvalidation"
+	"github.com/operator-framework/operator-sdk/internal/ansible/tracing"
 	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+	"github.com/operator-framework/operator-sdk/internal/ansible/webhook"
 	"github.com/operator-framework/operator-sdk/internal/clientbuilder"
+	"github.com/operator-framework/operator-sdk/internal/reload"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 	sdkVersion "github.com/operator-framework/operator-sdk/internal/version"
 )
@@ -96,7 +109,6 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 			os.Exit(1)
 		}
 	}
-	exitIfUnsupported(options)
 
 	cfg, err := config.GetConfig()
 	if err != nil {
@@ -104,18 +116,6 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		os.Exit(1)
 	}
 
-	urlPath, err := url.Parse(cfg.Host)
-
-	if err != nil {
-		log.Error(err, "Failed to Parse the Path URL.")
-		os.Exit(1)
-	}
-
-	if urlPath != nil && urlPath.Path != "" {
-		log.Error(fmt.Errorf("api endpoint '%s' contains a path component, which the proxy server is currently unable to handle properly. Work on this issue is being tracked here: https://github.com/operator-framework/operator-sdk/issues/4925", cfg.Host), "")
-		os.Exit(1)
-	}
-
 	// TODO(2.0.0): remove
 	// Deprecated: OPERATOR_NAME environment variable is an artifact of the
 	// legacy operator-sdk project scaffolding. Flag `--leader-election-id`
@@ -177,6 +177,23 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := tracing.Setup(context.TODO(), tracing.Options{
+		Endpoint:    f.TracingEndpoint,
+		Insecure:    f.TracingInsecure,
+		ServiceName: "ansible-operator",
+	})
+	if err != nil {
+		log.Error(err, "Failed to set up tracing.")
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error(err, "Failed to flush traces.")
+		}
+	}()
+
 	// Create a new manager to provide shared dependencies and start components
 	mgr, err := manager.New(cfg, options)
 	if err != nil {
@@ -184,6 +201,21 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		os.Exit(1)
 	}
 
+	// The dependent kinds of the controllers are read and watched through informer caches of
+	// their own, which are created like the cache of the manager, so that they can be stopped.
+	newCache := options.NewCache
+	if newCache == nil {
+		newCache = cache.New
+	}
+	dependentCaches := controllermap.NewDependentCaches(func() (cache.Cache, error) {
+		return newCache(mgr.GetConfig(), cache.Options{
+			Scheme:    mgr.GetScheme(),
+			Mapper:    mgr.GetRESTMapper(),
+			Resync:    options.SyncPeriod,
+			Namespace: options.Namespace,
+		})
+	})
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		log.Error(err, "Unable to set up health check")
 		os.Exit(1)
@@ -193,64 +225,122 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		os.Exit(1)
 	}
 
-	cMap := controllermap.NewControllerMap()
-	watches, err := watches.Load(f.WatchesFile, f.MaxConcurrentReconciles, f.AnsibleVerbosity)
-	if err != nil {
-		log.Error(err, "Failed to load watches.")
-		os.Exit(1)
-	}
-	for _, w := range watches {
-		runner, err := runner.New(w, f.AnsibleArgs)
+	var stream *eventstream.Broker
+	if f.EventStream {
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
 		if err != nil {
-			log.Error(err, "Failed to create runner")
+			log.Error(err, "Failed to create client for the event stream.")
 			os.Exit(1)
 		}
-
-		ctr := controller.Add(mgr, controller.Options{
-			GVK:                     w.GroupVersionKind,
-			Runner:                  runner,
-			ManageStatus:            w.ManageStatus,
-			AnsibleDebugLogs:        getAnsibleDebugLog(),
-			MaxConcurrentReconciles: w.MaxConcurrentReconciles,
-			ReconcilePeriod:         w.ReconcilePeriod,
-			Selector:                w.Selector,
-		})
-		if ctr == nil {
-			log.Error(fmt.Errorf("failed to add controller for GVK %v", w.GroupVersionKind.String()), "")
+		stream = eventstream.NewBroker()
+		streamHandler := eventstream.NewHandler(stream, eventstream.NewKubeAuthorizer(clientset, mgr.GetRESTMapper()))
+		if err := mgr.AddMetricsExtraHandler(eventstream.Path, streamHandler); err != nil {
+			log.Error(err, "Unable to set up the event stream.")
 			os.Exit(1)
 		}
+	}
 
-		cMap.Store(w.GroupVersionKind, &controllermap.Contents{Controller: *ctr, //nolint:staticcheck
-			WatchDependentResources:     w.WatchDependentResources,
-			WatchClusterScopedResources: w.WatchClusterScopedResources,
-			OwnerWatchMap:               controllermap.NewWatchMap(),
-			AnnotationWatchMap:          controllermap.NewWatchMap(),
-		}, w.Blacklist)
+	// The operator namespace is only needed by the varsFrom that read from it, so the runners
+	// of those report when it is unknown.
+	operatorNamespace, err := k8sutil.GetOperatorNamespace()
+	if err != nil {
+		log.V(1).Info("Unable to determine the operator namespace", "error", err.Error())
 	}
+	varsFrom := runner.VarsFromOptions{Reader: mgr.GetAPIReader(), OperatorNamespace: operatorNamespace}
+	// The budget is shared by the runners of all watches, including those of reloaded watches.
+	budget := runner.NewRunBudget(f.MaxConcurrentRuns)
 
-	// TODO(2.0.0): remove
-	err = mgr.AddHealthzCheck("ping", healthz.Ping)
+	sink, err := newArtifactSink(f)
 	if err != nil {
-		log.Error(err, "Failed to add Healthz check.")
+		log.Error(err, "Failed to create the artifact sink.")
+		os.Exit(1)
 	}
 
+	cMap := controllermap.NewControllerMap()
 	done := make(chan error)
 
 	// start the proxy
-	err = proxy.Run(done, proxy.Options{
+	var tokens *kubeconfig.Tokens
+	if f.ProxyTokenAuth {
+		tokens = kubeconfig.NewTokens()
+	}
+	proxyAddr, err := proxy.Run(done, proxy.Options{
 		Address:           "localhost",
-		Port:              8888,
+		Port:              f.ProxyListenPort(),
 		KubeConfig:        mgr.GetConfig(),
 		Cache:             mgr.GetCache(),
 		RESTMapper:        mgr.GetRESTMapper(),
 		ControllerMap:     cMap,
 		OwnerInjection:    f.InjectOwnerRef,
 		WatchedNamespaces: strings.Split(namespace, ","),
+		Tokens:            tokens,
 	})
 	if err != nil {
 		log.Error(err, "Error starting proxy.")
 		os.Exit(1)
 	}
+	kubeconfigs := &kubeconfig.Issuer{ProxyURL: "http://" + proxyAddr.String(), Tokens: tokens}
+
+	var watchesSource reload.Source = reload.NewFileSource(f.WatchesFile)
+	if f.WatchesConfigMap != "" {
+		watchesSource, err = reload.NewConfigMapSource(mgr.GetAPIReader(), f.WatchesConfigMap)
+		if err != nil {
+			log.Error(err, "Invalid watches ConfigMap.")
+			os.Exit(1)
+		}
+	}
+	watchesData, err := watchesSource.Read(context.TODO())
+	if err != nil {
+		log.Error(err, "Failed to read watches.", "source", watchesSource.String())
+		os.Exit(1)
+	}
+	controllers := reload.NewControllers()
+	webhooks := webhook.NewServer(mgr, f.AnsibleArgs, kubeconfigs)
+	applyWatches := func(data []byte) error {
+		ws, err := watches.LoadReader(bytes.NewReader(data), f.MaxConcurrentReconciles, f.AnsibleVerbosity)
+		if err != nil {
+			return err
+		}
+		if !f.ProxyTokenAuth {
+			for _, w := range ws {
+				if w.RequiresProxyTokenAuth() {
+					return fmt.Errorf("watch %v sets impersonation or apiAllowlist, which require proxy "+
+						"token auth, disabled by --proxy-token-auth=false", w.GroupVersionKind)
+				}
+			}
+		}
+		specs := make([]reload.Spec, 0, len(ws))
+		for _, w := range ws {
+			specs = append(specs, controllerSpec(mgr, cMap, kubeconfigs, stream, sink, varsFrom, budget,
+				dependentCaches, f, w))
+		}
+		if err := controllers.Apply(specs); err != nil {
+			return err
+		}
+		webhooks.Update(ws)
+		return nil
+	}
+	if err := applyWatches(watchesData); err != nil {
+		log.Error(err, "Failed to load watches.")
+		os.Exit(1)
+	}
+	if err := mgr.Add(controllers); err != nil {
+		log.Error(err, "Failed to add controllers to the manager.")
+		os.Exit(1)
+	}
+	if f.WatchesReloadInterval > 0 {
+		watcher := reload.NewWatcher(watchesSource, f.WatchesReloadInterval, watchesData, applyWatches)
+		if err := mgr.Add(watcher); err != nil {
+			log.Error(err, "Failed to add watches reloader to the manager.")
+			os.Exit(1)
+		}
+	}
+
+	// TODO(2.0.0): remove
+	err = mgr.AddHealthzCheck("ping", healthz.Ping)
+	if err != nil {
+		log.Error(err, "Failed to add Healthz check.")
+	}
 
 	// start the operator
 	go func() {
@@ -266,24 +356,122 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 	log.Info("Exiting.")
 }
 
-// exitIfUnsupported prints an error containing unsupported field names and exits
-// if any of those fields are not their default values.
-func exitIfUnsupported(options manager.Options) {
-	var keys []string
-	// The below options are webhook-specific, which is not supported by ansible.
-	if options.CertDir != "" {
-		keys = append(keys, "certDir")
+// newArtifactSink returns the sink the artifacts of Ansible runs are stored in, or nil if
+// they are not stored.
+func newArtifactSink(f *flags.Flags) (artifacts.Sink, error) {
+	var sink artifacts.Sink
+	var err error
+	switch f.ArtifactSink {
+	case "":
+		return nil, nil
+	case "local":
+		sink, err = artifacts.NewLocal(artifacts.LocalOptions{
+			Dir:     f.ArtifactSinkDir,
+			MaxAge:  f.ArtifactSinkMaxAge,
+			MaxSize: f.ArtifactSinkMaxSize,
+		})
+	case "s3":
+		sink, err = artifacts.NewS3(artifacts.S3Options{
+			Endpoint:        f.ArtifactSinkS3Endpoint,
+			Bucket:          f.ArtifactSinkS3Bucket,
+			Region:          f.ArtifactSinkS3Region,
+			Prefix:          f.ArtifactSinkS3Prefix,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown artifact sink %q, must be one of \"local\" or \"s3\"", f.ArtifactSink)
 	}
-	if options.Host != "" {
-		keys = append(keys, "host")
+	if err != nil {
+		return nil, err
 	}
-	if options.Port != 0 {
-		keys = append(keys, "port")
+	if f.ArtifactSinkFailedOnly {
+		sink = artifacts.FailedOnly(sink)
 	}
+	return sink, nil
+}
 
-	if len(keys) > 0 {
-		log.Error(fmt.Errorf("%s set in manager options", strings.Join(keys, ", ")), "unsupported fields")
-		os.Exit(1)
+// watchedVarsFrom returns the varsFrom of w and of its finalizer stages.
+func watchedVarsFrom(w watches.Watch) []watches.VarsFrom {
+	stages := w.FinalizerStages()
+	if len(stages) == 0 {
+		return w.VarsFrom
+	}
+	varsFrom := append([]watches.VarsFrom{}, w.VarsFrom...)
+	for _, f := range stages {
+		varsFrom = append(varsFrom, f.VarsFrom...)
+	}
+	return varsFrom
+}
+
+// controllerSpec returns the reload.Spec of the controller for the watch w, which is
+// registered in cMap while it runs and whose Ansible runs use kubeconfigs of kubeconfigs
+// and publish their events to stream, whose artifacts are stored in sink, whose varsFrom
+// are read as varsFrom configures, which wait for their share of budget, and whose
+// dependent kinds are watched through the caches of dependentCaches.
+func controllerSpec(mgr manager.Manager, cMap *controllermap.ControllerMap, kubeconfigs *kubeconfig.Issuer,
+	stream *eventstream.Broker, sink artifacts.Sink, varsFrom runner.VarsFromOptions, budget *runner.RunBudget,
+	dependentCaches *controllermap.DependentCaches, f *flags.Flags, w watches.Watch) reload.Spec {
+	var r runner.Runner
+	var dependents *controllermap.Dependents
+	var triggers *handler.DependentTriggers
+	if w.SkipUnchanged {
+		triggers = handler.NewDependentTriggers()
+	}
+	return reload.Spec{
+		GVK:    w.GroupVersionKind,
+		Config: w,
+		New: func() (crcontroller.Controller, error) {
+			var err error
+			r, err = runner.New(w, f.AnsibleArgs, sink, varsFrom, budget)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create runner: %w", err)
+			}
+			specValidator, err := specvalidation.New(w, mgr.GetAPIReader(), mgr.GetRESTMapper())
+			if err != nil {
+				return nil, fmt.Errorf("failed to create spec validator: %w", err)
+			}
+			dependents = controllermap.NewDependents(w.GroupVersionKind, dependentCaches)
+			return controller.NewUnmanaged(mgr, controller.Options{
+				GVK:                     w.GroupVersionKind,
+				Runner:                  r,
+				ManageStatus:            w.ManageStatus,
+				RecordEvents:            w.RecordEvents,
+				RunHistoryLimit:         w.RunHistoryLimit,
+				SkipUnchanged:           w.SkipUnchanged,
+				DependentTriggers:       triggers,
+				EventStream:             stream,
+				Dependents:              dependents,
+				AnsibleDebugLogs:        getAnsibleDebugLog(),
+				MaxConcurrentReconciles: w.MaxConcurrentReconciles,
+				ReconcilePeriod:         w.ReconcilePeriod,
+				RunTimeout:              w.RunTimeout,
+				Selector:                w.Selector,
+				Kubeconfigs:             kubeconfigs,
+				VarsFrom:                watchedVarsFrom(w),
+				OperatorNamespace:       varsFrom.OperatorNamespace,
+				SpecValidator:           specValidator,
+			})
+		},
+		OnStart: func(c crcontroller.Controller) {
+			cMap.Store(w.GroupVersionKind, &controllermap.Contents{Controller: c,
+				WatchDependentResources:     w.WatchDependentResources,
+				WatchClusterScopedResources: w.WatchClusterScopedResources,
+				Dependents:                  dependents,
+				Impersonation:               w.Impersonation,
+				APIAllowlist:                w.APIAllowlist,
+				DependentTriggers:           triggers,
+			}, w.Blacklist)
+		},
+		OnStop: func() {
+			cMap.Delete(w.GroupVersionKind)
+			dependents.Stop()
+			if closer, ok := r.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					log.Error(err, "Failed to close runner", "GVK", w.GroupVersionKind.String())
+				}
+			}
+		},
 	}
 }
 
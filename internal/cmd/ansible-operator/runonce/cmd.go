@@ -0,0 +1,283 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runonce
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	zapf "sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/controller"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/controllermap"
+	"github.com/operator-framework/operator-sdk/internal/ansible/proxy/kubeconfig"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/specvalidation"
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
+)
+
+type runOnceCmd struct {
+	watchesFile      string
+	ansibleVerbosity int
+	ansibleArgs      string
+	namespace        string
+	injectOwnerRef   bool
+}
+
+func NewCmd() *cobra.Command {
+	c := &runOnceCmd{}
+	zapfs := flag.NewFlagSet("zap", flag.ExitOnError)
+	opts := &zapf.Options{}
+	opts.BindFlags(zapfs)
+
+	cmd := &cobra.Command{
+		Use:   "run-once <cr-file>",
+		Short: "Reconcile a custom resource once and report the Ansible run",
+		Long: `Reconcile the custom resource in the given file once, with the role or playbook of its watch,
+and exit. The custom resource is created, or its spec, labels and annotations are updated,
+in the cluster of the kubeconfig, for example an envtest API server. The Ansible run reaches
+the cluster through the proxy, without informer cache, and the status is managed as by the
+operator.
+
+The tasks of the run with their results, the stats of the run, the status written to the
+custom resource and the API calls the proxy passed along to the API server are printed.
+The command exits non-zero if the reconcile failed.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logf.SetLogger(zapf.New(zapf.UseFlagOptions(opts)))
+			return c.run(cmd.OutOrStdout(), args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&c.watchesFile, "watches-file", "./watches.yaml", "Path to the watches file to use")
+	cmd.Flags().IntVar(&c.ansibleVerbosity, "ansible-verbosity", 2,
+		"Ansible verbosity. Overridden by environment variable.")
+	cmd.Flags().StringVar(&c.ansibleArgs, "ansible-args", "",
+		"Ansible args. Allows user to specify arbitrary arguments for ansible-based operators.")
+	cmd.Flags().StringVar(&c.namespace, "namespace", "",
+		"Namespace of the custom resource if the file does not set one. Defaults to \"default\".")
+	cmd.Flags().BoolVar(&c.injectOwnerRef, "inject-owner-ref", true,
+		"The proxy will inject owner references unless this flag is false")
+	if kubeconfigFlag := flag.CommandLine.Lookup("kubeconfig"); kubeconfigFlag != nil {
+		cmd.Flags().AddGoFlag(kubeconfigFlag)
+	}
+	cmd.Flags().AddGoFlagSet(zapfs)
+	return cmd
+}
+
+func (c *runOnceCmd) run(out io.Writer, crFile string) error {
+	cr, err := readCR(crFile)
+	if err != nil {
+		return err
+	}
+	ws, err := watches.Load(c.watchesFile, 1, c.ansibleVerbosity)
+	if err != nil {
+		return fmt.Errorf("failed to load watches: %w", err)
+	}
+	var w *watches.Watch
+	for i := range ws {
+		if ws[i].GroupVersionKind == cr.GroupVersionKind() {
+			w = &ws[i]
+			break
+		}
+	}
+	if w == nil {
+		return fmt.Errorf("no watch for %s in %s", cr.GroupVersionKind(), c.watchesFile)
+	}
+
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	mapper, err := apiutil.NewDynamicRESTMapper(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create REST mapper: %w", err)
+	}
+	cl, err := client.New(cfg, client.Options{Mapper: mapper})
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	ctx := context.TODO()
+	if err := c.applyCR(ctx, cl, mapper, cr); err != nil {
+		return err
+	}
+
+	calls := &apiCallRecorder{}
+	cMap := controllermap.NewControllerMap()
+	// Dependent resources are not watched, as the CR is only reconciled once.
+	cMap.Store(w.GroupVersionKind, &controllermap.Contents{
+		Impersonation: w.Impersonation,
+		APIAllowlist:  w.APIAllowlist,
+	}, w.Blacklist)
+	// Every run gets a token, so that the impersonation and API allowlist of the watch apply
+	// to all requests through the proxy.
+	tokens := kubeconfig.NewTokens()
+	done := make(chan error, 1)
+	proxyAddr, err := proxy.Run(done, proxy.Options{
+		Address:           "localhost",
+		Handler:           calls.Handler,
+		KubeConfig:        cfg,
+		RESTMapper:        mapper,
+		ControllerMap:     cMap,
+		WatchedNamespaces: []string{metav1.NamespaceAll},
+		DisableCache:      true,
+		OwnerInjection:    c.injectOwnerRef,
+		Tokens:            tokens,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start proxy: %w", err)
+	}
+
+	// The operator namespace is only needed by the varsFrom that read from it.
+	operatorNamespace, _ := k8sutil.GetOperatorNamespace()
+	r, err := runner.New(*w, c.ansibleArgs, nil, runner.VarsFromOptions{
+		Reader:            cl,
+		OperatorNamespace: operatorNamespace,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create runner: %w", err)
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	specValidator, err := specvalidation.New(*w, cl, mapper)
+	if err != nil {
+		return fmt.Errorf("failed to create spec validator: %w", err)
+	}
+	recorder := &recordingRunner{Runner: r}
+	statusClient := &statusRecordingClient{Client: cl}
+	reconciler := &controller.AnsibleOperatorReconciler{
+		GVK:             w.GroupVersionKind,
+		Runner:          recorder,
+		Client:          statusClient,
+		APIReader:       cl,
+		ReconcilePeriod: w.ReconcilePeriod,
+		RunTimeout:      w.RunTimeout,
+		ManageStatus:    w.ManageStatus,
+		Kubeconfigs:     &kubeconfig.Issuer{ProxyURL: "http://" + proxyAddr.String(), Tokens: tokens},
+		RunHistoryLimit: w.RunHistoryLimit,
+		SpecValidator:   specValidator,
+	}
+
+	_, reconcileErr := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{
+		Namespace: cr.GetNamespace(),
+		Name:      cr.GetName(),
+	}})
+	select {
+	case err := <-done:
+		if reconcileErr == nil && err != nil {
+			reconcileErr = fmt.Errorf("proxy exited: %w", err)
+		}
+	default:
+	}
+
+	rep := report{
+		Events:   recorder.Events(),
+		Status:   statusClient.Written(),
+		APICalls: calls.Calls(),
+		Err:      reconcileErr,
+	}
+	if err := rep.print(out); err != nil {
+		return err
+	}
+	if reconcileErr != nil {
+		return errors.New("reconcile failed")
+	}
+	return nil
+}
+
+// readCR reads the custom resource in path.
+func readCR(path string) (*unstructured.Unstructured, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read custom resource: %w", err)
+	}
+	j, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse custom resource %s: %w", path, err)
+	}
+	cr := &unstructured.Unstructured{}
+	if err := cr.UnmarshalJSON(j); err != nil {
+		return nil, fmt.Errorf("failed to parse custom resource %s: %w", path, err)
+	}
+	if cr.GetAPIVersion() == "" || cr.GetKind() == "" || cr.GetName() == "" {
+		return nil, fmt.Errorf("custom resource %s must set apiVersion, kind and metadata.name", path)
+	}
+	return cr, nil
+}
+
+// applyCR creates cr, or updates the spec, labels and annotations of the existing custom
+// resource to those of cr. cr is updated to the custom resource in the cluster.
+func (c *runOnceCmd) applyCR(ctx context.Context, cl client.Client, mapper meta.RESTMapper,
+	cr *unstructured.Unstructured) error {
+	gvk := cr.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("failed to get REST mapping of %s: %w", gvk, err)
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		cr.SetNamespace("")
+	} else if cr.GetNamespace() == "" {
+		cr.SetNamespace(c.namespace)
+		if cr.GetNamespace() == "" {
+			cr.SetNamespace(metav1.NamespaceDefault)
+		}
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	err = cl.Get(ctx, client.ObjectKeyFromObject(cr), existing)
+	if apierrors.IsNotFound(err) {
+		if err := cl.Create(ctx, cr); err != nil {
+			return fmt.Errorf("failed to create custom resource: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get custom resource: %w", err)
+	}
+	if spec, ok := cr.Object["spec"]; ok {
+		existing.Object["spec"] = spec
+	} else {
+		delete(existing.Object, "spec")
+	}
+	existing.SetLabels(cr.GetLabels())
+	existing.SetAnnotations(cr.GetAnnotations())
+	if err := cl.Update(ctx, existing); err != nil {
+		return fmt.Errorf("failed to update custom resource: %w", err)
+	}
+	*cr = *existing
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runonce
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+	fakerunner "github.com/operator-framework/operator-sdk/internal/ansible/runner/fake"
+)
+
+var _ = Describe("Running a run-once command", func() {
+	Describe("NewCmd", func() {
+		It("builds a cobra command", func() {
+			cmd := NewCmd()
+			Expect(cmd).NotTo(BeNil())
+			Expect(cmd.Use).NotTo(Equal(""))
+			Expect(cmd.Short).NotTo(Equal(""))
+			Expect(cmd.Args(cmd, nil)).NotTo(Succeed())
+			Expect(cmd.Args(cmd, []string{"cr.yaml"})).To(Succeed())
+		})
+	})
+
+	Describe("readCR", func() {
+		var dir string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "run-once")
+			Expect(err).NotTo(HaveOccurred())
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("reads a custom resource", func() {
+			path := filepath.Join(dir, "cr.yaml")
+			Expect(ioutil.WriteFile(path, []byte(`apiVersion: cache.example.com/v1alpha1
+kind: Memcached
+metadata:
+  name: example
+spec:
+  size: 3
+`), 0600)).To(Succeed())
+			cr, err := readCR(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cr.GetKind()).To(Equal("Memcached"))
+			Expect(cr.GetName()).To(Equal("example"))
+			Expect(cr.Object["spec"]).To(Equal(map[string]interface{}{"size": int64(3)}))
+		})
+		It("fails for a custom resource without name", func() {
+			path := filepath.Join(dir, "cr.yaml")
+			Expect(ioutil.WriteFile(path, []byte("apiVersion: cache.example.com/v1alpha1\nkind: Memcached\n"),
+				0600)).To(Succeed())
+			_, err := readCR(path)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("recordingRunner", func() {
+		It("records the events of the run in order", func() {
+			r := &recordingRunner{Runner: &fakerunner.Runner{JobEvents: []eventapi.JobEvent{
+				{Counter: 2, Event: eventapi.EventPlaybookOnStats},
+				{Counter: 1, Event: eventapi.EventRunnerOnOk},
+			}}}
+			result, err := r.Run(context.TODO(), "1", &unstructured.Unstructured{}, "")
+			Expect(err).NotTo(HaveOccurred())
+			received := 0
+			for range result.Events() {
+				received++
+			}
+			Expect(received).To(Equal(2))
+			events := r.Events()
+			Expect(events).To(HaveLen(2))
+			Expect(events[0].Counter).To(Equal(1))
+			Expect(events[1].Counter).To(Equal(2))
+		})
+	})
+
+	Describe("statusRecordingClient", func() {
+		It("records the last status written", func() {
+			u := &unstructured.Unstructured{}
+			u.SetAPIVersion("v1")
+			u.SetKind("ConfigMap")
+			u.SetNamespace("default")
+			u.SetName("example")
+			c := &statusRecordingClient{Client: fake.NewClientBuilder().WithObjects(u.DeepCopy()).Build()}
+			Expect(c.Written()).To(BeNil())
+
+			u.Object["status"] = map[string]interface{}{"phase": "Running"}
+			Expect(c.Status().Update(context.TODO(), u)).To(Succeed())
+			u.Object["status"] = map[string]interface{}{"phase": "Done"}
+			Expect(c.Written()).To(Equal(map[string]interface{}{"phase": "Running"}))
+			Expect(c.Status().Update(context.TODO(), u)).To(Succeed())
+			Expect(c.Written()).To(Equal(map[string]interface{}{"phase": "Done"}))
+		})
+	})
+
+	Describe("apiCallRecorder", func() {
+		It("records the requests and the status of their responses", func() {
+			calls := &apiCallRecorder{}
+			h := calls.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Method == http.MethodPost {
+					w.WriteHeader(http.StatusCreated)
+				}
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet,
+				"/api/v1/namespaces/default/configmaps?limit=1", nil))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost,
+				"/api/v1/namespaces/default/configmaps", nil))
+			Expect(calls.Calls()).To(Equal([]apiCall{
+				{Method: http.MethodGet, URI: "/api/v1/namespaces/default/configmaps?limit=1", Status: http.StatusOK},
+				{Method: http.MethodPost, URI: "/api/v1/namespaces/default/configmaps", Status: http.StatusCreated},
+			}))
+		})
+	})
+
+	Describe("report", func() {
+		It("prints the tasks, stats, status and API calls", func() {
+			rep := report{
+				Events: []eventapi.JobEvent{
+					{Event: eventapi.EventRunnerOnOk, EventData: map[string]interface{}{
+						"task": "create deployment", "role": "memcached", "res": map[string]interface{}{"changed": true},
+					}},
+					{Event: eventapi.EventRunnerOnFailed, EventData: map[string]interface{}{
+						"task": "wait", "res": map[string]interface{}{"msg": "timed out"},
+					}},
+					{Event: eventapi.EventPlaybookOnStats, EventData: map[string]interface{}{
+						"ok":       map[string]interface{}{"localhost": float64(1)},
+						"failures": map[string]interface{}{"localhost": float64(1)},
+					}},
+				},
+				Status:   map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Failure"}}},
+				APICalls: []apiCall{{Method: http.MethodPost, URI: "/apis/apps/v1/namespaces/default/deployments", Status: 201}},
+				Err:      errors.New("event runner on failed"),
+			}
+			out := &bytes.Buffer{}
+			Expect(rep.print(out)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("changed     memcached : create deployment\n"))
+			Expect(out.String()).To(ContainSubstring("failed      wait\n"))
+			Expect(out.String()).To(ContainSubstring("timed out"))
+			Expect(out.String()).To(ContainSubstring("failures:   1\n"))
+			Expect(out.String()).To(ContainSubstring("  - type: Failure\n"))
+			Expect(out.String()).To(ContainSubstring("POST    /apis/apps/v1/namespaces/default/deployments 201\n"))
+			Expect(out.String()).To(ContainSubstring("Result: failed: event runner on failed\n"))
+		})
+		It("reports runs that did not finish", func() {
+			out := &bytes.Buffer{}
+			Expect(report{}.print(out)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("none, the run did not finish"))
+			Expect(out.String()).To(ContainSubstring("not written"))
+			Expect(out.String()).To(ContainSubstring("Result: succeeded"))
+		})
+	})
+})
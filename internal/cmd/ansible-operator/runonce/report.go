@@ -0,0 +1,276 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runonce
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner"
+	"github.com/operator-framework/operator-sdk/internal/ansible/runner/eventapi"
+)
+
+// recordingRunner - a runner.Runner that records the events of the runs of its Runner.
+type recordingRunner struct {
+	runner.Runner
+
+	mu     sync.Mutex
+	events []eventapi.JobEvent
+}
+
+func (r *recordingRunner) Run(ctx context.Context, ident string, u *unstructured.Unstructured,
+	kubeconfig string) (runner.RunResult, error) {
+	result, err := r.Runner.Run(ctx, ident, u, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan eventapi.JobEvent)
+	go func() {
+		defer close(events)
+		for event := range result.Events() {
+			r.mu.Lock()
+			r.events = append(r.events, event)
+			r.mu.Unlock()
+			events <- event
+		}
+	}()
+	return &recordedResult{RunResult: result, events: events}, nil
+}
+
+// Events returns the recorded events, in the order of their counter.
+func (r *recordingRunner) Events() []eventapi.JobEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := append([]eventapi.JobEvent{}, r.events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Counter < events[j].Counter })
+	return events
+}
+
+// recordedResult - the result of a run whose events are recorded.
+type recordedResult struct {
+	runner.RunResult
+	events <-chan eventapi.JobEvent
+}
+
+func (r *recordedResult) Events() <-chan eventapi.JobEvent {
+	return r.events
+}
+
+// statusRecordingClient - a client.Client that records the last status it wrote.
+type statusRecordingClient struct {
+	client.Client
+
+	mu     sync.Mutex
+	status interface{}
+}
+
+func (c *statusRecordingClient) Status() client.StatusWriter {
+	return &statusRecordingWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+// Written returns the last status written, or nil if none was.
+func (c *statusRecordingClient) Written() interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func (c *statusRecordingClient) record(obj client.Object) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if status, ok := u.Object["status"]; ok {
+		c.status = runtime.DeepCopyJSONValue(status)
+	}
+}
+
+type statusRecordingWriter struct {
+	client.StatusWriter
+	client *statusRecordingClient
+}
+
+func (w *statusRecordingWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := w.StatusWriter.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	w.client.record(obj)
+	return nil
+}
+
+func (w *statusRecordingWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	if err := w.StatusWriter.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	w.client.record(obj)
+	return nil
+}
+
+// apiCall - an API request the proxy passed along to the API server.
+type apiCall struct {
+	Method string
+	URI    string
+	Status int
+}
+
+// apiCallRecorder - records the API requests the proxy passes along to the API server.
+type apiCallRecorder struct {
+	mu    sync.Mutex
+	calls []apiCall
+}
+
+// Handler returns h, recording the requests it serves. It is a proxy.HandlerChain.
+func (a *apiCallRecorder) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, req)
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.calls = append(a.calls, apiCall{Method: req.Method, URI: req.URL.RequestURI(), Status: sw.status})
+	})
+}
+
+// Calls returns the recorded requests, in the order they were answered.
+func (a *apiCallRecorder) Calls() []apiCall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]apiCall{}, a.calls...)
+}
+
+// statusResponseWriter - an http.ResponseWriter that records the status code of the response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// report - the outcome of a single reconcile of a CR.
+type report struct {
+	Events   []eventapi.JobEvent
+	Status   interface{}
+	APICalls []apiCall
+	Err      error
+}
+
+// print writes the tasks of the run with their results, the stats of the run, the status
+// written and the API calls made to w.
+func (r report) print(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("Tasks:\n")
+	tasks := 0
+	for _, event := range r.Events {
+		result, ok := runner.TaskResult(event)
+		if !ok {
+			continue
+		}
+		tasks++
+		name := event.Task()
+		if role := event.Role(); role != "" {
+			name = role + " : " + name
+		}
+		fmt.Fprintf(&b, "  %-11s %s\n", result, name)
+		if result == runner.TaskResultFailed || result == runner.TaskResultUnreachable {
+			if msg := event.GetFailedPlaybookMessage(); msg != "" {
+				fmt.Fprintf(&b, "  %-11s %s\n", "", msg)
+			}
+		}
+	}
+	if tasks == 0 {
+		b.WriteString("  none\n")
+	}
+
+	b.WriteString("\nStats:\n")
+	stats := false
+	for _, event := range r.Events {
+		if event.Event != eventapi.EventPlaybookOnStats {
+			continue
+		}
+		stats = true
+		for _, key := range []string{"ok", "changed", "failures", "skipped"} {
+			fmt.Fprintf(&b, "  %-11s %d\n", key+":", sumStat(event.EventData[key]))
+		}
+	}
+	if !stats {
+		b.WriteString("  none, the run did not finish\n")
+	}
+
+	b.WriteString("\nStatus:\n")
+	if r.Status == nil {
+		b.WriteString("  not written\n")
+	} else {
+		out, err := yaml.Marshal(r.Status)
+		if err != nil {
+			return fmt.Errorf("failed to marshal status: %w", err)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	b.WriteString("\nAPI calls:\n")
+	if len(r.APICalls) == 0 {
+		b.WriteString("  none\n")
+	}
+	for _, call := range r.APICalls {
+		fmt.Fprintf(&b, "  %-7s %s %d\n", call.Method, call.URI, call.Status)
+	}
+
+	b.WriteString("\nResult: ")
+	if r.Err != nil {
+		b.WriteString("failed: " + r.Err.Error() + "\n")
+	} else {
+		b.WriteString("succeeded\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sumStat returns the sum of the counts by host of a stat of a playbook_on_stats event.
+func sumStat(v interface{}) int {
+	hosts, ok := v.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	sum := 0
+	for _, count := range hosts {
+		if n, ok := count.(float64); ok {
+			sum += int(n)
+		}
+	}
+	return sum
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/operator-framework/operator-sdk/internal/ansible/watches"
+)
+
+func NewCmd() *cobra.Command {
+	var watchesFile string
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the watches file and the playbooks and roles it references",
+		Long: `Validate the watches file, and check that the playbooks and roles its watches reference
+exist and parse. Every problem found is printed with the file and line it is found at, and
+the command exits non-zero if there are any, e.g. to validate the watches file in CI.
+Relative paths in the watches file are relative to its directory.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd.OutOrStdout(), watchesFile)
+		},
+	}
+	cmd.Flags().StringVar(&watchesFile, "watches-file", "./watches.yaml", "Path to the watches file to validate")
+	return cmd
+}
+
+func run(out io.Writer, watchesFile string) error {
+	problems := watches.Lint(watchesFile)
+	if err := problems.Print(out); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s) in %s", len(problems), watchesFile)
+	}
+	_, err := fmt.Fprintf(out, "%s is valid\n", watchesFile)
+	return err
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Running a validate command", func() {
+	Describe("NewCmd", func() {
+		It("builds a cobra command", func() {
+			cmd := NewCmd()
+			Expect(cmd).NotTo(BeNil())
+			Expect(cmd.Use).NotTo(Equal(""))
+			Expect(cmd.Short).NotTo(Equal(""))
+			Expect(cmd.Flags().Lookup("watches-file")).NotTo(BeNil())
+		})
+	})
+
+	Describe("run", func() {
+		var dir, watchesFile string
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "validate")
+			Expect(err).NotTo(HaveOccurred())
+			watchesFile = filepath.Join(dir, "watches.yaml")
+			Expect(ioutil.WriteFile(filepath.Join(dir, "playbook.yml"), []byte("- hosts: localhost\n"),
+				0600)).To(Succeed())
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("succeeds for a valid watches file", func() {
+			Expect(ioutil.WriteFile(watchesFile, []byte(`---
+- version: v1alpha1
+  group: cache.example.com
+  kind: Memcached
+  playbook: playbook.yml
+`), 0600)).To(Succeed())
+			out := &bytes.Buffer{}
+			Expect(run(out, watchesFile)).To(Succeed())
+			Expect(out.String()).To(Equal(watchesFile + " is valid\n"))
+		})
+		It("prints every problem and fails", func() {
+			Expect(ioutil.WriteFile(watchesFile, []byte(`---
+- version: v1alpha1
+  group: cache.example.com
+  kind: Memcached
+  playbook: missing.yml
+- version: v1alpha1
+  group: cache.example.com
+  kind: Memcached
+  playbook: playbook.yml
+`), 0600)).To(Succeed())
+			out := &bytes.Buffer{}
+			err := run(out, watchesFile)
+			Expect(err).To(MatchError("found 2 problem(s) in " + watchesFile))
+			Expect(out.String()).To(Equal(
+				watchesFile + ":5: playbook: " + filepath.Join(dir, "missing.yml") + " was not found\n" +
+					watchesFile + ":6: duplicate GVK: cache.example.com/v1alpha1, Kind=Memcached, first watched at line 2\n"))
+		})
+	})
+})
@@ -15,6 +15,8 @@
 package run
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -27,6 +29,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	crcontroller "sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	zapf "sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -40,6 +43,7 @@ import (
 	"github.com/operator-framework/operator-sdk/internal/helm/metrics"
 	"github.com/operator-framework/operator-sdk/internal/helm/release"
 	"github.com/operator-framework/operator-sdk/internal/helm/watches"
+	"github.com/operator-framework/operator-sdk/internal/reload"
 	"github.com/operator-framework/operator-sdk/internal/util/k8sutil"
 	sdkVersion "github.com/operator-framework/operator-sdk/internal/version"
 )
@@ -169,24 +173,43 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 		os.Exit(1)
 	}
 
-	ws, err := watches.Load(f.WatchesFile)
+	var watchesSource reload.Source = reload.NewFileSource(f.WatchesFile)
+	if f.WatchesConfigMap != "" {
+		watchesSource, err = reload.NewConfigMapSource(mgr.GetAPIReader(), f.WatchesConfigMap)
+		if err != nil {
+			log.Error(err, "Invalid watches ConfigMap.")
+			os.Exit(1)
+		}
+	}
+	watchesData, err := watchesSource.Read(context.TODO())
 	if err != nil {
-		log.Error(err, "Failed to create new manager factories.")
+		log.Error(err, "Failed to read watches.", "source", watchesSource.String())
 		os.Exit(1)
 	}
-	for _, w := range ws {
-		// Register the controller with the factory.
-		err := controller.Add(mgr, controller.WatchOptions{
-			Namespace:               namespace,
-			GVK:                     w.GroupVersionKind,
-			ManagerFactory:          release.NewManagerFactory(mgr, w.ChartDir),
-			ReconcilePeriod:         f.ReconcilePeriod,
-			WatchDependentResources: *w.WatchDependentResources,
-			OverrideValues:          w.OverrideValues,
-			MaxConcurrentReconciles: f.MaxConcurrentReconciles,
-		})
+	controllers := reload.NewControllers()
+	applyWatches := func(data []byte) error {
+		ws, err := watches.LoadReader(bytes.NewReader(data))
 		if err != nil {
-			log.Error(err, "Failed to add manager factory to controller.")
+			return err
+		}
+		specs := make([]reload.Spec, 0, len(ws))
+		for _, w := range ws {
+			specs = append(specs, controllerSpec(mgr, f, namespace, w))
+		}
+		return controllers.Apply(specs)
+	}
+	if err := applyWatches(watchesData); err != nil {
+		log.Error(err, "Failed to create new manager factories.")
+		os.Exit(1)
+	}
+	if err := mgr.Add(controllers); err != nil {
+		log.Error(err, "Failed to add controllers to the manager.")
+		os.Exit(1)
+	}
+	if f.WatchesReloadInterval > 0 {
+		watcher := reload.NewWatcher(watchesSource, f.WatchesReloadInterval, watchesData, applyWatches)
+		if err := mgr.Add(watcher); err != nil {
+			log.Error(err, "Failed to add watches reloader to the manager.")
 			os.Exit(1)
 		}
 	}
@@ -198,6 +221,25 @@ func run(cmd *cobra.Command, f *flags.Flags) {
 	}
 }
 
+// controllerSpec returns the reload.Spec of the controller for the watch w.
+func controllerSpec(mgr manager.Manager, f *flags.Flags, namespace string, w watches.Watch) reload.Spec {
+	return reload.Spec{
+		GVK:    w.GroupVersionKind,
+		Config: w,
+		New: func() (crcontroller.Controller, error) {
+			return controller.NewUnmanaged(mgr, controller.WatchOptions{
+				Namespace:               namespace,
+				GVK:                     w.GroupVersionKind,
+				ManagerFactory:          release.NewManagerFactory(mgr, w.ChartDir),
+				ReconcilePeriod:         f.ReconcilePeriod,
+				WatchDependentResources: *w.WatchDependentResources,
+				OverrideValues:          w.OverrideValues,
+				MaxConcurrentReconciles: f.MaxConcurrentReconciles,
+			})
+		},
+	}
+}
+
 // exitIfUnsupported prints an error containing unsupported field names and exits
 // if any of those fields are not their default values.
 func exitIfUnsupported(options manager.Options) {
@@ -53,8 +53,9 @@ type WatchOptions struct {
 	MaxConcurrentReconciles int
 }
 
-// Add creates a new helm operator controller and adds it to the manager
-func Add(mgr manager.Manager, options WatchOptions) error {
+// NewUnmanaged creates a new helm operator controller that is not added to the manager,
+// so that the caller can start and stop it.
+func NewUnmanaged(mgr manager.Manager, options WatchOptions) (controller.Controller, error) {
 	controllerName := fmt.Sprintf("%v-controller", strings.ToLower(options.GVK.Kind))
 
 	r := &HelmOperatorReconciler{
@@ -70,18 +71,18 @@ func Add(mgr manager.Manager, options WatchOptions) error {
 	mgr.GetScheme().AddKnownTypeWithName(options.GVK, &unstructured.Unstructured{})
 	metav1.AddToGroupVersion(mgr.GetScheme(), options.GVK.GroupVersion())
 
-	c, err := controller.New(controllerName, mgr, controller.Options{
+	c, err := controller.NewUnmanaged(controllerName, mgr, controller.Options{
 		Reconciler:              r,
 		MaxConcurrentReconciles: options.MaxConcurrentReconciles,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	o := &unstructured.Unstructured{}
 	o.SetGroupVersionKind(options.GVK)
 	if err := c.Watch(&source.Kind{Type: o}, &libhandler.InstrumentedEnqueueRequestForObject{}); err != nil {
-		return err
+		return nil, err
 	}
 
 	if options.WatchDependentResources {
@@ -90,7 +91,7 @@ func Add(mgr manager.Manager, options WatchOptions) error {
 
 	log.Info("Watching resource", "apiVersion", options.GVK.GroupVersion(), "kind",
 		options.GVK.Kind, "namespace", options.Namespace, "reconcilePeriod", options.ReconcilePeriod.String())
-	return nil
+	return c, nil
 }
 
 // watchDependentResources adds a release hook function to the HelmOperatorReconciler
@@ -27,6 +27,8 @@ import (
 type Flags struct {
 	ReconcilePeriod         time.Duration
 	WatchesFile             string
+	WatchesConfigMap        string
+	WatchesReloadInterval   time.Duration
 	MetricsBindAddress      string
 	LeaderElection          bool
 	LeaderElectionID        string
@@ -53,6 +55,18 @@ func (f *Flags) AddTo(flagSet *pflag.FlagSet) {
 		"./watches.yaml",
 		"Path to the watches file to use",
 	)
+	flagSet.StringVar(&f.WatchesConfigMap,
+		"watches-configmap",
+		"",
+		"ConfigMap, in the form <namespace>/<name>, whose \"watches.yaml\" key holds the watches to use "+
+			"instead of the watches file",
+	)
+	flagSet.DurationVar(&f.WatchesReloadInterval,
+		"watches-reload-interval",
+		0,
+		"How often to check the watches file, or ConfigMap, for changes and reload it. "+
+			"Set to 0 to disable reloading.",
+	)
 
 	// Controller flags.
 	flagSet.DurationVar(&f.ReconcilePeriod,
@@ -0,0 +1,132 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watches
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/strvals"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/operator-framework/operator-sdk/internal/util/lint"
+)
+
+// watchFields are the fields of a watch, as in the published JSON schema of the watches
+// file.
+var watchFields = lint.Fields{
+	"group":                   nil,
+	"version":                 nil,
+	"kind":                    nil,
+	"chart":                   nil,
+	"watchDependentResources": nil,
+	"overrideValues":          nil,
+}
+
+// Lint returns every problem of the watches file at path, with the line it is found at.
+// Unlike Load, which fails on the first invalid watch, Lint reports unknown fields,
+// duplicate GVKs, override values that do not parse and the charts that do not exist or
+// do not load of all watches. Relative chart paths are relative to the directory of the
+// watches file.
+func Lint(path string) lint.Problems {
+	problems := lint.Problems{}
+	root := lint.ParseFile(path, &problems)
+	if root == nil {
+		if len(problems) == 0 {
+			problems.Add(path, 0, "no watches found")
+		}
+		return problems
+	}
+	if root.Kind != yaml.SequenceNode {
+		problems.Add(path, root.Line, "watches file must be a list of watches")
+		return problems
+	}
+	rootDir := filepath.Dir(path)
+
+	lines := map[schema.GroupVersionKind]int{}
+	for _, node := range root.Content {
+		if node.Kind != yaml.MappingNode {
+			problems.Add(path, node.Line, "watch must be a mapping")
+			continue
+		}
+		lint.CheckKeys(path, node, watchFields, "", &problems)
+		w, ok := lintWatch(path, rootDir, node, &problems)
+		if !ok {
+			continue
+		}
+		if line, ok := lines[w.GroupVersionKind]; ok {
+			problems.Add(path, node.Line, "duplicate GVK: %s, first watched at line %d", w.GroupVersionKind, line)
+		} else {
+			lines[w.GroupVersionKind] = node.Line
+		}
+	}
+	problems.Sort()
+	return problems
+}
+
+// lintWatch adds the problems of the watch of node to problems, and returns the watch if
+// it could be decoded and has a valid GVK.
+func lintWatch(path, rootDir string, node *yaml.Node, problems *lint.Problems) (*Watch, bool) {
+	b, err := yaml.Marshal(node)
+	if err != nil {
+		problems.Add(path, node.Line, "%v", err)
+		return nil, false
+	}
+	w := &Watch{}
+	if err := sigsyaml.Unmarshal(b, w); err != nil {
+		problems.Add(path, node.Line, "invalid watch: %v", err)
+		return nil, false
+	}
+
+	chartLine := lint.Line(node, "chart")
+	chartDir := w.ChartDir
+	if chartDir != "" && !filepath.IsAbs(chartDir) {
+		chartDir = filepath.Join(rootDir, chartDir)
+	}
+	switch {
+	case w.ChartDir == "":
+		problems.Add(path, chartLine, "chart must not be empty")
+	default:
+		if _, err := chartutil.IsChartDir(chartDir); err != nil {
+			problems.Add(path, chartLine, "invalid chart directory %s: %v", w.ChartDir, err)
+		} else if _, err := loader.LoadDir(chartDir); err != nil {
+			problems.Add(path, chartLine, "chart %s does not load: %v", w.ChartDir, err)
+		}
+	}
+
+	keys := make([]string, 0, len(w.OverrideValues))
+	for k := range w.OverrideValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val := fmt.Sprintf("%s=%s", k, os.ExpandEnv(w.OverrideValues[k]))
+		if err := strvals.ParseIntoString(val, map[string]interface{}{}); err != nil {
+			problems.Add(path, lint.Line(node, "overrideValues", k), "invalid override value %q: %v", k, err)
+		}
+	}
+
+	if err := verifyGVK(w.GroupVersionKind); err != nil {
+		problems.Add(path, node.Line, "invalid GVK: %s: %v", w.GroupVersionKind, err)
+		return nil, false
+	}
+	return w, true
+}
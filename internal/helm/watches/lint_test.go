@@ -0,0 +1,133 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watches
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/operator-framework/operator-sdk/internal/util/lint"
+)
+
+func TestLint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watches-lint")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	testChart, err := filepath.Abs("../../../internal/plugins/helm/v1/chartutil/testdata/test-chart")
+	if err != nil {
+		t.Fatalf("Failed to get path of test chart: %v", err)
+	}
+	brokenChart := filepath.Join(dir, "broken-chart")
+	if err := os.MkdirAll(brokenChart, 0755); err != nil {
+		t.Fatalf("Failed to create broken chart: %v", err)
+	}
+	for name, content := range map[string]string{
+		"Chart.yaml":  "apiVersion: v2\nname: broken\nversion: 0.1.0\n",
+		"values.yaml": "image: [\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(brokenChart, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create broken chart: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		data string
+		// expectProblems are the prefixes of the problems, without the path of the watches file.
+		expectProblems []string
+	}{
+		{
+			name: "valid",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: ` + testChart + `
+  overrideValues:
+    image.tag: $MY_TAG
+`,
+		},
+		{
+			name: "all problems",
+			data: `---
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: missing-chart
+- group: mygroup
+  version: v1alpha1
+  kind: MyKind
+  chart: broken-chart
+  watchDependentResource: false
+- group: mygroup
+  kind: NoVersion
+  chart: ` + testChart + `
+  overrideValues:
+    image[tag: value
+`,
+			expectProblems: []string{
+				"5: invalid chart directory missing-chart",
+				"6: duplicate GVK: mygroup/v1alpha1, Kind=MyKind, first watched at line 2",
+				"9: chart broken-chart does not load",
+				`10: unknown field "watchDependentResource"`,
+				"11: invalid GVK: mygroup/, Kind=NoVersion: version must not be empty",
+				`15: invalid override value "image[tag"`,
+			},
+		},
+		{
+			name:           "not a list",
+			data:           "foo: bar\n",
+			expectProblems: []string{"1: watches file must be a list of watches"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, WatchesFile)
+			if err := ioutil.WriteFile(path, []byte(tc.data), 0644); err != nil {
+				t.Fatalf("Failed to write watches file: %v", err)
+			}
+			problems := Lint(path)
+			if len(problems) != len(tc.expectProblems) {
+				t.Fatalf("Expected problems %q; got %v", tc.expectProblems, problems)
+			}
+			for i, p := range problems {
+				assert.Equal(t, path, p.File)
+				if s := strings.TrimPrefix(p.String(), path+":"); !strings.HasPrefix(s, tc.expectProblems[i]) {
+					t.Fatalf("Expected problems %q; got %v", tc.expectProblems, problems)
+				}
+			}
+		})
+	}
+}
+
+func TestLintFieldsMatchSchema(t *testing.T) {
+	b, err := ioutil.ReadFile("../../../website/static/schemas/helm-watches.schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read JSON schema: %v", err)
+	}
+	fields, err := lint.SchemaFields(b)
+	if err != nil {
+		t.Fatalf("Failed to parse JSON schema: %v", err)
+	}
+	assert.Equal(t, watchFields, fields)
+}
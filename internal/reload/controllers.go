@@ -0,0 +1,149 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// Spec describes the controller of a single watch.
+type Spec struct {
+	GVK schema.GroupVersionKind
+	// Config is the watch the controller is created from. The controller is replaced when
+	// its Config changes.
+	Config interface{}
+	// New creates the unmanaged controller of the watch. The controller is discarded
+	// without being started if another watch is invalid, so New should have no other
+	// side effects.
+	New func() (controller.Controller, error)
+	// OnStart, if set, is called with the controller before it is started.
+	OnStart func(controller.Controller)
+	// OnStop, if set, is called once the controller has been stopped and its Start has
+	// returned.
+	OnStop func()
+}
+
+type entry struct {
+	spec   Spec
+	ctrl   controller.Controller
+	cancel context.CancelFunc
+	// done is closed once the Start of the controller has returned, if it was started.
+	done chan struct{}
+}
+
+// Controllers is the set of controllers of the current watches. Each controller runs
+// unmanaged under its own context, so that it can be stopped when its watch is removed
+// or changed. Controllers implements manager.Runnable, and no controller is started
+// before the manager starts it.
+type Controllers struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	entries map[schema.GroupVersionKind]*entry
+}
+
+// NewControllers returns an empty set of controllers.
+func NewControllers() *Controllers {
+	return &Controllers{entries: map[schema.GroupVersionKind]*entry{}}
+}
+
+// Apply makes specs the watches of the set. Controllers are created for new and changed
+// watches, and the controllers of removed and changed watches are stopped. A changed
+// watch's controller is only started once the controller it replaces has stopped. If any
+// new controller cannot be created, an error is returned and the set is left unchanged.
+func (c *Controllers) Apply(specs []Spec) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	desired := map[schema.GroupVersionKind]bool{}
+	created := []*entry{}
+	for _, spec := range specs {
+		if desired[spec.GVK] {
+			return fmt.Errorf("duplicate GVK: %v", spec.GVK)
+		}
+		desired[spec.GVK] = true
+		if e, ok := c.entries[spec.GVK]; ok && reflect.DeepEqual(e.spec.Config, spec.Config) {
+			continue
+		}
+		ctrl, err := spec.New()
+		if err != nil {
+			return fmt.Errorf("failed to create controller for GVK %v: %w", spec.GVK, err)
+		}
+		created = append(created, &entry{spec: spec, ctrl: ctrl})
+	}
+
+	for gvk, e := range c.entries {
+		if !desired[gvk] {
+			log.Info("Removing controller", "GVK", gvk.String())
+			c.stop(e)
+		}
+	}
+	for _, e := range created {
+		if old, ok := c.entries[e.spec.GVK]; ok {
+			log.Info("Replacing controller", "GVK", e.spec.GVK.String())
+			c.stop(old)
+		}
+		c.entries[e.spec.GVK] = e
+		if e.spec.OnStart != nil {
+			e.spec.OnStart(e.ctrl)
+		}
+		if c.ctx != nil {
+			c.start(e)
+		}
+	}
+	return nil
+}
+
+// Start starts the controllers of the set, and those added later, until ctx is done.
+func (c *Controllers) Start(ctx context.Context) error {
+	c.mu.Lock()
+	c.ctx = ctx
+	for _, e := range c.entries {
+		c.start(e)
+	}
+	c.mu.Unlock()
+
+	<-ctx.Done()
+	return nil
+}
+
+func (c *Controllers) start(e *entry) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	go func() {
+		defer close(e.done)
+		if err := e.ctrl.Start(ctx); err != nil {
+			log.Error(err, "Controller exited with error", "GVK", e.spec.GVK.String())
+		}
+	}()
+}
+
+// stop stops the controller of e and waits for its Start to return.
+func (c *Controllers) stop(e *entry) {
+	if e.cancel != nil {
+		e.cancel()
+		<-e.done
+	}
+	delete(c.entries, e.spec.GVK)
+	if e.spec.OnStop != nil {
+		e.spec.OnStop()
+	}
+}
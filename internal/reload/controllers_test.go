@@ -0,0 +1,228 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+type fakeController struct {
+	mu      sync.Mutex
+	started bool
+	stopped bool
+}
+
+func (c *fakeController) Reconcile(context.Context, reconcile.Request) (reconcile.Result, error) {
+	return reconcile.Result{}, nil
+}
+
+func (c *fakeController) Watch(source.Source, handler.EventHandler, ...predicate.Predicate) error {
+	return nil
+}
+
+func (c *fakeController) Start(ctx context.Context) error {
+	c.mu.Lock()
+	c.started = true
+	c.mu.Unlock()
+	<-ctx.Done()
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *fakeController) GetLogger() logr.Logger {
+	return logf.Log
+}
+
+func (c *fakeController) state() (started, stopped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.started, c.stopped
+}
+
+// waitFor polls cond until it is true or a second has passed.
+func waitFor(cond func() bool) bool {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestControllersApply(t *testing.T) {
+	gvkA := schema.GroupVersionKind{Group: "app.example.com", Version: "v1alpha1", Kind: "A"}
+	gvkB := schema.GroupVersionKind{Group: "app.example.com", Version: "v1alpha1", Kind: "B"}
+
+	created := map[schema.GroupVersionKind][]*fakeController{}
+	stops := map[schema.GroupVersionKind]int{}
+	spec := func(gvk schema.GroupVersionKind, config string) Spec {
+		return Spec{
+			GVK:    gvk,
+			Config: config,
+			New: func() (controller.Controller, error) {
+				c := &fakeController{}
+				created[gvk] = append(created[gvk], c)
+				return c, nil
+			},
+			OnStop: func() { stops[gvk]++ },
+		}
+	}
+
+	cs := NewControllers()
+	if err := cs.Apply([]Spec{spec(gvkA, "a")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if started, _ := created[gvkA][0].state(); started {
+		t.Fatalf("Controller started before the set was started")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = cs.Start(ctx) }()
+	if !waitFor(func() bool { started, _ := created[gvkA][0].state(); return started }) {
+		t.Fatalf("Controller was not started with the set")
+	}
+
+	// Unchanged watches keep their controller, new watches get one.
+	if err := cs.Apply([]Spec{spec(gvkA, "a"), spec(gvkB, "b")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(created[gvkA]) != 1 || len(created[gvkB]) != 1 {
+		t.Fatalf("Unexpected controllers created: %v", created)
+	}
+
+	// Changed watches replace their controller, removed watches stop theirs.
+	if err := cs.Apply([]Spec{spec(gvkA, "changed")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(created[gvkA]) != 2 {
+		t.Fatalf("Expected changed watch to create a new controller, got %d", len(created[gvkA]))
+	}
+	for _, c := range []*fakeController{created[gvkA][0], created[gvkB][0]} {
+		if !waitFor(func() bool { _, stopped := c.state(); return stopped }) {
+			t.Fatalf("Controller was not stopped")
+		}
+	}
+	if stops[gvkA] != 1 || stops[gvkB] != 1 {
+		t.Fatalf("Unexpected OnStop calls: %v", stops)
+	}
+	if !waitFor(func() bool { started, _ := created[gvkA][1].state(); return started }) {
+		t.Fatalf("Replacement controller was not started")
+	}
+
+	// An invalid watch leaves the set unchanged.
+	invalid := spec(gvkB, "b")
+	invalid.New = func() (controller.Controller, error) { return nil, errors.New("invalid") }
+	if err := cs.Apply([]Spec{invalid}); err == nil {
+		t.Fatalf("Expected error for invalid watch")
+	}
+	if _, stopped := created[gvkA][1].state(); stopped || stops[gvkA] != 1 {
+		t.Fatalf("Controller was stopped by an invalid watch")
+	}
+
+	if err := cs.Apply([]Spec{spec(gvkA, "changed"), spec(gvkA, "changed")}); err == nil {
+		t.Fatalf("Expected error for duplicate GVK")
+	}
+}
+
+// drainingController is a fakeController whose Start only returns once release is closed.
+type drainingController struct {
+	fakeController
+	release chan struct{}
+}
+
+func (c *drainingController) Start(ctx context.Context) error {
+	err := c.fakeController.Start(ctx)
+	<-c.release
+	return err
+}
+
+func TestControllersApplyWaitsForStop(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "app.example.com", Version: "v1alpha1", Kind: "A"}
+	old := &drainingController{release: make(chan struct{})}
+	replacement := &fakeController{}
+	var mu sync.Mutex
+	stopped := false
+	spec := func(config string, c controller.Controller) Spec {
+		return Spec{
+			GVK:    gvk,
+			Config: config,
+			New:    func() (controller.Controller, error) { return c, nil },
+			OnStop: func() {
+				mu.Lock()
+				defer mu.Unlock()
+				stopped = true
+			},
+		}
+	}
+
+	cs := NewControllers()
+	if err := cs.Apply([]Spec{spec("old", old)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = cs.Start(ctx) }()
+	if !waitFor(func() bool { started, _ := old.state(); return started }) {
+		t.Fatalf("Controller was not started with the set")
+	}
+
+	applied := make(chan error)
+	go func() { applied <- cs.Apply([]Spec{spec("new", replacement)}) }()
+	if !waitFor(func() bool { _, stopped := old.state(); return stopped }) {
+		t.Fatalf("Controller was not stopped")
+	}
+	// The old controller is still draining.
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	onStopCalled := stopped
+	mu.Unlock()
+	if onStopCalled {
+		t.Fatalf("OnStop was called before the controller's Start returned")
+	}
+	if started, _ := replacement.state(); started {
+		t.Fatalf("Replacement controller was started before the old controller's Start returned")
+	}
+
+	close(old.release)
+	if err := <-applied; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	mu.Lock()
+	onStopCalled = stopped
+	mu.Unlock()
+	if !onStopCalled {
+		t.Fatalf("OnStop was not called")
+	}
+	if !waitFor(func() bool { started, _ := replacement.state(); return started }) {
+		t.Fatalf("Replacement controller was not started")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigMapKey is the key of the ConfigMap data that holds the watches file.
+const ConfigMapKey = "watches.yaml"
+
+// Source is the location a watches file is read from.
+type Source interface {
+	// Read returns the current contents of the watches file.
+	Read(ctx context.Context) ([]byte, error)
+	String() string
+}
+
+type fileSource struct {
+	path string
+}
+
+// NewFileSource returns a Source that reads the watches file at path.
+func NewFileSource(path string) Source {
+	return fileSource{path: path}
+}
+
+func (s fileSource) Read(_ context.Context) ([]byte, error) {
+	return ioutil.ReadFile(s.path)
+}
+
+func (s fileSource) String() string {
+	return s.path
+}
+
+type configMapSource struct {
+	reader client.Reader
+	key    types.NamespacedName
+}
+
+// NewConfigMapSource returns a Source that reads the watches file from the ConfigMapKey
+// of the ConfigMap ref, in the form "<namespace>/<name>".
+func NewConfigMapSource(reader client.Reader, ref string) (Source, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid ConfigMap %q: must be of the form <namespace>/<name>", ref)
+	}
+	return configMapSource{
+		reader: reader,
+		key:    types.NamespacedName{Namespace: parts[0], Name: parts[1]},
+	}, nil
+}
+
+func (s configMapSource) Read(ctx context.Context) ([]byte, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.reader.Get(ctx, s.key, cm); err != nil {
+		return nil, err
+	}
+	data, ok := cm.Data[ConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s has no key %q", s.key, ConfigMapKey)
+	}
+	return []byte(data), nil
+}
+
+func (s configMapSource) String() string {
+	return fmt.Sprintf("configmap/%s", s.key)
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("reload")
+
+// Watcher polls a Source and calls OnChange whenever the contents of the watches
+// file change. Watcher implements manager.Runnable.
+type Watcher struct {
+	Source   Source
+	Interval time.Duration
+	// OnChange applies the new contents of the watches file. If it returns an error the
+	// contents are rejected, and OnChange is not called again until they change.
+	OnChange func(data []byte) error
+
+	last []byte
+}
+
+// NewWatcher returns a Watcher of src, which was last read with the contents initial.
+func NewWatcher(src Source, interval time.Duration, initial []byte, onChange func([]byte) error) *Watcher {
+	return &Watcher{
+		Source:   src,
+		Interval: interval,
+		OnChange: onChange,
+		last:     initial,
+	}
+}
+
+// Start polls the Source until ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	log.Info("Watching for changes of the watches file", "source", w.Source.String(), "interval", w.Interval)
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+func (w *Watcher) poll(ctx context.Context) {
+	data, err := w.Source.Read(ctx)
+	if err != nil {
+		log.Error(err, "Failed to read watches file", "source", w.Source.String())
+		return
+	}
+	if bytes.Equal(data, w.last) {
+		return
+	}
+	w.last = data
+	if err := w.OnChange(data); err != nil {
+		log.Error(err, "Rejected invalid watches file, keeping the previous watches", "source", w.Source.String())
+		return
+	}
+	log.Info("Reloaded watches file", "source", w.Source.String())
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reload
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watches.yaml")
+	if err := ioutil.WriteFile(path, []byte("initial"), 0600); err != nil {
+		t.Fatalf("Failed to write watches file: %v", err)
+	}
+
+	var applied []string
+	var reject bool
+	w := NewWatcher(NewFileSource(path), 0, []byte("initial"), func(data []byte) error {
+		applied = append(applied, string(data))
+		if reject {
+			return errors.New("invalid")
+		}
+		return nil
+	})
+
+	w.poll(context.TODO())
+	if len(applied) != 0 {
+		t.Fatalf("Unchanged watches file was applied: %v", applied)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("changed"), 0600); err != nil {
+		t.Fatalf("Failed to write watches file: %v", err)
+	}
+	w.poll(context.TODO())
+	w.poll(context.TODO())
+	if len(applied) != 1 || applied[0] != "changed" {
+		t.Fatalf("Expected changed watches file to be applied once, got %v", applied)
+	}
+
+	// Rejected contents are not applied again until they change.
+	reject = true
+	if err := ioutil.WriteFile(path, []byte("invalid"), 0600); err != nil {
+		t.Fatalf("Failed to write watches file: %v", err)
+	}
+	w.poll(context.TODO())
+	w.poll(context.TODO())
+	if len(applied) != 2 {
+		t.Fatalf("Expected invalid watches file to be applied once, got %v", applied)
+	}
+}
+
+func TestConfigMapSource(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "watches"},
+		Data:       map[string]string{ConfigMapKey: "- kind: Foo"},
+	}
+	reader := fake.NewClientBuilder().WithObjects(cm).Build()
+
+	testCases := []struct {
+		name      string
+		ref       string
+		expected  string
+		expectErr bool
+	}{
+		{name: "existing ConfigMap", ref: "ns/watches", expected: "- kind: Foo"},
+		{name: "missing ConfigMap", ref: "ns/other", expectErr: true},
+		{name: "invalid reference", ref: "watches", expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := NewConfigMapSource(reader, tc.ref)
+			var data []byte
+			if err == nil {
+				data, err = src.Read(context.TODO())
+			}
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("Expected error for %q", tc.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(data) != tc.expected {
+				t.Fatalf("Unexpected data %q, expected %q", data, tc.expected)
+			}
+		})
+	}
+}
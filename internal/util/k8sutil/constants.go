@@ -23,4 +23,12 @@ const (
 	// which is the namespace where the watch activity happens.
 	// this value is empty if the operator is running with clusterScope.
 	WatchNamespaceEnvVar = "WATCH_NAMESPACE"
+
+	// OperatorNamespaceEnvVar is the constant for env variable OPERATOR_NAMESPACE
+	// which is the namespace the operator runs in. If unset, the namespace of the
+	// service account of the pod is used.
+	OperatorNamespaceEnvVar = "OPERATOR_NAMESPACE"
+
+	// serviceAccountNamespaceFile holds the namespace of the service account of a pod.
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 )
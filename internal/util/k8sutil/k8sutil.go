@@ -19,6 +19,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"regexp"
 	"strings"
 	"unicode"
@@ -156,3 +158,19 @@ func SupportsOwnerReference(restMapper meta.RESTMapper, owner, dependent runtime
 	// Both owner and dependent are namespace-scoped and in the same namespace.
 	return true, nil
 }
+
+// GetOperatorNamespace returns the namespace the operator runs in, from the env variable
+// OPERATOR_NAMESPACE or else from the namespace of the service account of the pod.
+func GetOperatorNamespace() (string, error) {
+	if ns, ok := os.LookupEnv(OperatorNamespaceEnvVar); ok && ns != "" {
+		return ns, nil
+	}
+	b, err := ioutil.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("namespace not found for current environment, set %s", OperatorNamespaceEnvVar)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2021 The Operator-SDK Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint provides the pieces shared by the validation of the watches files of the
+// Ansible and Helm operators: problems found at a line of a file, and the lookup of the
+// YAML nodes problems are reported at.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem - a problem found in a file. Line is 0 if the problem is not at a particular
+// line of the file.
+type Problem struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+}
+
+// Problems - the problems found in one or more files.
+type Problems []Problem
+
+// Add - adds a problem found at line of file.
+func (ps *Problems) Add(file string, line int, format string, args ...interface{}) {
+	*ps = append(*ps, Problem{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// Sort - sorts the problems by file and line, keeping the order of the problems found at
+// the same line.
+func (ps Problems) Sort() {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].File != ps[j].File {
+			return ps[i].File < ps[j].File
+		}
+		return ps[i].Line < ps[j].Line
+	})
+}
+
+// Print - writes the problems to w, one per line.
+func (ps Problems) Print(w io.Writer) error {
+	var b strings.Builder
+	for _, p := range ps {
+		b.WriteString(p.String() + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// ParseFile - parses the YAML file at path, and returns the root node of its first
+// document, or nil if the file is empty. A file that cannot be read or parsed is
+// reported as a problem.
+func ParseFile(path string, problems *Problems) *yaml.Node {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		problems.Add(path, 0, "%v", err)
+		return nil
+	}
+	return Parse(path, b, problems)
+}
+
+// Parse - parses the YAML data b of the file path, like ParseFile does.
+func Parse(path string, b []byte, problems *Problems) *yaml.Node {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(b, doc); err != nil {
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			problems.Add(path, line, "invalid YAML: %s", m[2])
+		} else {
+			problems.Add(path, 0, "invalid YAML: %s", strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+		return nil
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// Fields - the keys a YAML mapping may have. Each key maps to the Fields of its value,
+// which is nil if the value is a scalar or a mapping with arbitrary keys. The Fields of a
+// sequence apply to each of its items.
+type Fields map[string]Fields
+
+// CheckKeys - reports the keys of node and the mappings nested in it that are not in
+// fields, e.g. misspelled keys that would be ignored. path is the key path of node used
+// in the messages.
+func CheckKeys(file string, node *yaml.Node, fields Fields, path string, problems *Problems) {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			CheckKeys(file, item, fields, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := key.Value
+			if path != "" {
+				keyPath = path + "." + key.Value
+			}
+			nested, ok := fields[key.Value]
+			if !ok {
+				problems.Add(file, key.Line, "unknown field %q", keyPath)
+				continue
+			}
+			if nested != nil {
+				CheckKeys(file, value, nested, keyPath, problems)
+			}
+		}
+	}
+}
+
+// Line - returns the line of the value at path in node, where each element of path is a
+// key of a mapping or the index of an item of a sequence. If path is not found in node,
+// the line of the closest value found is returned. The line of a value in a mapping is the
+// line of its key.
+func Line(node *yaml.Node, path ...string) int {
+	if node == nil {
+		return 0
+	}
+	line := node.Line
+	for _, elem := range path {
+		switch node.Kind {
+		case yaml.MappingNode:
+			found := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == elem {
+					line, node, found = node.Content[i].Line, node.Content[i+1], true
+					break
+				}
+			}
+			if !found {
+				return line
+			}
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return line
+			}
+			node = node.Content[i]
+			line = node.Line
+		default:
+			return line
+		}
+	}
+	return line
+}
+
+// SchemaFields - returns the Fields of the items of the JSON schema of a list of objects,
+// e.g. to verify that the Fields used to check the keys of a file match its published
+// schema.
+func SchemaFields(schema []byte) (Fields, error) {
+	s := &jsonSchema{}
+	if err := json.Unmarshal(schema, s); err != nil {
+		return nil, err
+	}
+	if s.Items == nil {
+		return nil, fmt.Errorf("schema has no items")
+	}
+	return s.Items.fields(), nil
+}
+
+// jsonSchema - the parts of a JSON schema that define the keys of objects.
+type jsonSchema struct {
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+}
+
+func (s *jsonSchema) fields() Fields {
+	if s.Items != nil {
+		return s.Items.fields()
+	}
+	if len(s.Properties) == 0 {
+		return nil
+	}
+	fields := Fields{}
+	for key, value := range s.Properties {
+		fields[key] = value.fields()
+	}
+	return fields
+}